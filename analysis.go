@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// analysisVersion is the version of the analysis file format written by writeAnalysis.
+const analysisVersion = 1
+
+// Analysis is the editable result of analyzing a HAR file: the calls with their HAR entries and the named
+// chained values with every usage. "chainer analyze" writes it and "chainer render" exports it, so that a
+// variable name, a path or a spurious chain can be fixed by hand without analyzing the HAR again.
+// Setting include to false on a call or a value leaves it out of the rendered output. The file is always JSON,
+// the format of the HAR entries it embeds.
+type Analysis struct {
+	Version int             `json:"version"`
+	Calls   []AnalysisCall  `json:"calls"`
+	Values  []AnalysisValue `json:"values"`
+
+	// Dropped lists the candidate values that were not chained. It is informational and only shown in reports.
+	Dropped []AnalysisDropped `json:"dropped,omitempty"`
+}
+
+// AnalysisCall is a call of the analysis. Usages refer to calls by their index in Analysis.Calls.
+type AnalysisCall struct {
+	Name    string `json:"name"`
+	Include bool   `json:"include"`
+	Entry   *Entry `json:"entry"`
+
+	// JarCookies names the recorded cookies left to the client's cookie jar, which exporters do not send.
+	JarCookies []string `json:"jar_cookies,omitempty"`
+}
+
+// AnalysisValue is a chained value. Its first response usage is the source the value is extracted from.
+type AnalysisValue struct {
+	Name         string          `json:"name"`
+	Include      bool            `json:"include"`
+	Value        string          `json:"value"`
+	External     bool            `json:"external,omitempty"`
+	InitScript   string          `json:"initializer,omitempty"`
+	OriginalPath string          `json:"original_path,omitempty"`
+	Usages       []AnalysisUsage `json:"usages"`
+}
+
+// AnalysisUsage is a ValueReference, with its call as an index and its enums as names.
+type AnalysisUsage struct {
+	Call         int            `json:"call"`
+	Type         string         `json:"type"`
+	Location     string         `json:"location"`
+	Path         string         `json:"path"`
+	Value        any            `json:"value,omitempty"`
+	HeaderName   string         `json:"header_name,omitempty"`
+	CookieName   string         `json:"cookie_name,omitempty"`
+	UrlLocation  int            `json:"url_location,omitempty"`
+	MatchOffset  int            `json:"match_offset,omitempty"`
+	MatchLength  int            `json:"match_length,omitempty"`
+	Transform    string         `json:"transform,omitempty"`
+	JWTClaimPath string         `json:"jwt_claim_path,omitempty"`
+	JWTParent    *AnalysisUsage `json:"jwt_parent,omitempty"`
+}
+
+// AnalysisDropped is a DroppedValue.
+type AnalysisDropped struct {
+	Value  string          `json:"value"`
+	Reason string          `json:"reason"`
+	Usages []AnalysisUsage `json:"usages,omitempty"`
+}
+
+var sourceTypeNames = map[SourceType]string{
+	SourceTypeRequest:  "request",
+	SourceTypeResponse: "response",
+}
+
+var sourceLocationNames = map[SourceLocation]string{
+	SourceLocationHeader:   "header",
+	SourceLocationBodyJson: "body_json",
+	SourceLocationBodyForm: "body_form",
+	SourceLocationUrl:      "url",
+	SourceLocationCookie:   "cookie",
+}
+
+// buildAnalysis converts the analyzed calls, chained values and dropped values into an Analysis.
+func buildAnalysis(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue) *Analysis {
+	analysis := &Analysis{Version: analysisVersion}
+	callIndex := make(map[*CallDetails]int)
+	for _, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		callIndex[callDetails] = len(analysis.Calls)
+		analysis.Calls = append(analysis.Calls, AnalysisCall{Name: callDetails.Name, Include: true, Entry: callDetails.Entry, JarCookies: callDetails.JarCookies})
+	}
+	for _, cv := range chainedValues {
+		value := AnalysisValue{
+			Name:         cv.VariableName,
+			Include:      true,
+			Value:        cv.Value,
+			External:     cv.ExternalSource,
+			InitScript:   cv.InitScript,
+			OriginalPath: cv.OriginalPath,
+		}
+		for _, usage := range cv.AllUsages {
+			value.Usages = append(value.Usages, analysisUsage(usage, callIndex))
+		}
+		analysis.Values = append(analysis.Values, value)
+	}
+	for _, d := range dropped {
+		entry := AnalysisDropped{Value: d.Value, Reason: d.Reason}
+		for _, usage := range d.Usages {
+			entry.Usages = append(entry.Usages, analysisUsage(usage, callIndex))
+		}
+		analysis.Dropped = append(analysis.Dropped, entry)
+	}
+	return analysis
+}
+
+func analysisUsage(ref *ValueReference, callIndex map[*CallDetails]int) AnalysisUsage {
+	call, ok := callIndex[ref.Source]
+	if !ok {
+		call = -1
+	}
+	usage := AnalysisUsage{
+		Call:         call,
+		Type:         sourceTypeNames[ref.SourceType],
+		Location:     sourceLocationNames[ref.SourceLocation],
+		Path:         ref.ReferencePath,
+		Value:        ref.Value,
+		HeaderName:   ref.HeaderName,
+		CookieName:   ref.CookieName,
+		UrlLocation:  ref.UrlLocation,
+		MatchOffset:  ref.MatchOffset,
+		MatchLength:  ref.MatchLength,
+		Transform:    ref.Transform,
+		JWTClaimPath: ref.JWTClaimPath,
+	}
+	if ref.JWTParent != nil {
+		parent := analysisUsage(ref.JWTParent, callIndex)
+		usage.JWTParent = &parent
+	}
+	return usage
+}
+
+// writeAnalysis writes the analysis file.
+func writeAnalysis(path string, analysis *Analysis) error {
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling analysis: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing analysis file: %w", err)
+	}
+	return nil
+}
+
+// readAnalysis reads an analysis file, from standard input if path is "-".
+func readAnalysis(path string) (*Analysis, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading analysis file: %w", err)
+	}
+	return parseAnalysis(data, path)
+}
+
+// parseAnalysis unmarshals an analysis file read from path and checks its version.
+func parseAnalysis(data []byte, path string) (*Analysis, error) {
+	var analysis Analysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("error parsing analysis file %s: %w", path, err)
+	}
+	if analysis.Version != analysisVersion {
+		return nil, fmt.Errorf("unsupported analysis file version %d in %s (expected %d)", analysis.Version, path, analysisVersion)
+	}
+	return &analysis, nil
+}
+
+// restore rebuilds the included calls and chained values of the analysis, linked as after repopulateCallDetails,
+// and the dropped values. Usages of excluded calls are left out, as are values no longer returned by any
+// included call.
+func (a *Analysis) restore() ([]*CallDetails, []*ChainedValueContext, []*DroppedValue, error) {
+	calls := make([]*CallDetails, len(a.Calls))
+	var callDetailsList []*CallDetails
+	for i, call := range a.Calls {
+		if call.Entry == nil {
+			return nil, nil, nil, fmt.Errorf("call %d has no entry", i)
+		}
+		if !call.Include {
+			continue
+		}
+		calls[i] = &CallDetails{Name: call.Name, Entry: call.Entry, JarCookies: call.JarCookies}
+		callDetailsList = append(callDetailsList, calls[i])
+	}
+
+	var chainedValues []*ChainedValueContext
+	for _, value := range a.Values {
+		if !value.Include {
+			continue
+		}
+		if value.Name == "" {
+			return nil, nil, nil, fmt.Errorf("chained value %q has no name", value.Value)
+		}
+		cv := &ChainedValueContext{
+			Value:          value.Value,
+			VariableName:   value.Name,
+			ExternalSource: value.External,
+			InitScript:     value.InitScript,
+			OriginalPath:   value.OriginalPath,
+		}
+		hasSource, hasUse := false, false
+		for _, usage := range value.Usages {
+			ref, err := usage.restore(calls)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("chained value %s: %w", value.Name, err)
+			}
+			if ref.Source == nil {
+				continue
+			}
+			hasSource = hasSource || ref.SourceType == SourceTypeResponse
+			hasUse = hasUse || ref.SourceType == SourceTypeRequest
+			cv.AllUsages = append(cv.AllUsages, ref)
+		}
+		if !hasUse || (!hasSource && !cv.ExternalSource) {
+			log.Printf("Leaving out %s: no included call returns and uses it", value.Name)
+			continue
+		}
+		chainedValues = append(chainedValues, cv)
+	}
+	repopulateCallDetails(chainedValues)
+
+	var dropped []*DroppedValue
+	for _, d := range a.Dropped {
+		entry := &DroppedValue{Value: d.Value, Reason: d.Reason}
+		for _, usage := range d.Usages {
+			if ref, err := usage.restore(calls); err == nil && ref.Source != nil {
+				entry.Usages = append(entry.Usages, ref)
+			}
+		}
+		dropped = append(dropped, entry)
+	}
+	return callDetailsList, chainedValues, dropped, nil
+}
+
+// restore converts the usage back to a ValueReference. Its Source is nil if the call is excluded.
+func (u AnalysisUsage) restore(calls []*CallDetails) (*ValueReference, error) {
+	if u.Call < 0 || u.Call >= len(calls) {
+		return nil, fmt.Errorf("usage %s refers to unknown call %d", u.Path, u.Call)
+	}
+	ref := &ValueReference{
+		Value:         u.Value,
+		ReferencePath: u.Path,
+		HeaderName:    u.HeaderName,
+		CookieName:    u.CookieName,
+		UrlLocation:   u.UrlLocation,
+		MatchOffset:   u.MatchOffset,
+		MatchLength:   u.MatchLength,
+		Transform:     u.Transform,
+		JWTClaimPath:  u.JWTClaimPath,
+		Source:        calls[u.Call],
+	}
+	var ok bool
+	if ref.SourceType, ok = lookupName(sourceTypeNames, u.Type); !ok {
+		return nil, fmt.Errorf("usage %s has unknown type %q", u.Path, u.Type)
+	}
+	if ref.SourceLocation, ok = lookupName(sourceLocationNames, u.Location); !ok {
+		return nil, fmt.Errorf("usage %s has unknown location %q", u.Path, u.Location)
+	}
+	if u.JWTParent != nil {
+		parent, err := u.JWTParent.restore(calls)
+		if err != nil {
+			return nil, err
+		}
+		ref.JWTParent = parent
+	}
+	return ref, nil
+}
+
+// lookupName returns the key of names whose value is name.
+func lookupName[K comparable](names map[K]string, name string) (K, bool) {
+	for k, v := range names {
+		if v == name {
+			return k, true
+		}
+	}
+	var zero K
+	return zero, false
+}
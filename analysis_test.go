@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// roundTripHar covers the features the analysis file has to carry: a JWT claim, a cookie, a refined
+// predicate path, an embedded path segment, an encoded query value, a form body and a predefined variable.
+func roundTripHar() HAR {
+	segment := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	token := segment(`{"alg":"HS256"}`) + "." + segment(`{"sub":"user-7781","tenant":"acme-eu-1"}`) + ".c2lnbmF0dXJl"
+
+	login := jsonEntry("POST", "https://api.example.com/v1/auth/login", "application/json", `{"username": "alice"}`,
+		`{"access_token": "`+token+`"}`)
+	login.Response.Headers = []Header{{Name: "Set-Cookie", Value: "SESSIONID=s3ss10nXYZ987; Path=/; HttpOnly"}}
+	order := jsonEntry("POST", "https://api.example.com/v1/orders", "application/json", `{"customer": "user-7781"}`,
+		`{"order": {"id": "ORD7731"}, "travelers": [{"type": "CHD", "id": "t-4412"}, {"type": "ADT", "id": "t-9087"}], "note": "a b/c&d=1 x99"}`)
+	order.Request.Headers = []Header{
+		{Name: "Authorization", Value: "Bearer " + token},
+		{Name: "Cookie", Value: "SESSIONID=s3ss10nXYZ987; theme=dark"},
+	}
+	details := jsonEntry("GET", "https://api.example.com/v1/orders/ref-ORD7731?traveler=t-9087&note=a+b%2Fc%26d%3D1+x99", "", "", `{}`)
+	details.Request.Headers = []Header{{Name: "X-Tenant", Value: "acme-eu-1"}}
+	search := jsonEntry("POST", "https://api.example.com/v1/search", "application/x-www-form-urlencoded", "q=ORD7731&user=alice", `{}`)
+	return HAR{Log: Log{Entries: []Entry{login, order, details, search}}}
+}
+
+func TestAnalysisRoundTrip(t *testing.T) {
+	for _, cookieMode := range []string{CookieModeVars, CookieModeJar} {
+		t.Run(cookieMode, func(t *testing.T) {
+			previous := log.Writer()
+			log.SetOutput(io.Discard)
+			t.Cleanup(func() { log.SetOutput(previous) })
+
+			callDetailsList := processHar(roundTripHar())
+			chainedValues, dropped := findChainedValues(callDetailsList)
+			chainedValues = extractPredefinedVars(callDetailsList, []varsInput{{Name: "username", SearchValue: "alice"}}, chainedValues)
+			chainedValues = applyCookieMode(cookieMode, chainedValues)
+			repopulateCallDetails(chainedValues)
+			updateComplexPaths(context.Background(), chainedValues, false)
+			assignNames(context.Background(), NamingModeHeuristic, callDetailsList, chainedValues)
+
+			analysisPath := filepath.Join(t.TempDir(), "analysis.json")
+			if err := writeAnalysis(analysisPath, buildAnalysis(callDetailsList, chainedValues, dropped)); err != nil {
+				t.Fatal(err)
+			}
+			restoredCalls, restoredValues, restoredDropped, err := loadAnalysis(analysisPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cookieMode == CookieModeJar && !reflect.DeepEqual(restoredCalls[1].JarCookies, []string{"SESSIONID"}) {
+				t.Errorf("restored jar cookies = %v, want [SESSIONID]", restoredCalls[1].JarCookies)
+			}
+
+			for _, exporter := range exporters {
+				t.Run(exporter.Name(), func(t *testing.T) {
+					// Both outputs share the directory name, which some exporters use, e.g. as the Go package.
+					converted := outputPathIn(t, exporter)
+					rendered := outputPathIn(t, exporter)
+					if err := exportWith(exporter, callDetailsList, chainedValues, dropped, converted); err != nil {
+						t.Fatal(err)
+					}
+					if err := exportWith(exporter, restoredCalls, restoredValues, restoredDropped, rendered); err != nil {
+						t.Fatal(err)
+					}
+					compareOutputTrees(t, filepath.Dir(converted), filepath.Dir(rendered))
+				})
+			}
+		})
+	}
+}
+
+// outputPathIn returns the default output path of exporter in a new "out" directory.
+func outputPathIn(t *testing.T, exporter Exporter) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, exporter.DefaultOutput())
+}
+
+// compareOutputTrees reports the files that differ between two output directories.
+func compareOutputTrees(t *testing.T, want string, got string) {
+	t.Helper()
+	wantFiles := readOutputTree(t, want)
+	gotFiles := readOutputTree(t, got)
+	if len(wantFiles) == 0 {
+		t.Fatalf("no output written to %s", want)
+	}
+	for name, content := range wantFiles {
+		rendered, ok := gotFiles[name]
+		if !ok {
+			t.Errorf("rendering the analysis did not write %s", name)
+			continue
+		}
+		if !bytes.Equal(content, rendered) {
+			t.Errorf("%s differs after the round trip:\n--- convert\n%s\n--- render\n%s", name, content, rendered)
+		}
+	}
+	for name := range gotFiles {
+		if _, ok := wantFiles[name]; !ok {
+			t.Errorf("rendering the analysis wrote the extra file %s", name)
+		}
+	}
+}
+
+// readOutputTree returns the contents of the files below root by their relative path.
+func readOutputTree(t *testing.T, root string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestAnalysisRestore(t *testing.T) {
+	// keepUsages keeps the usages of the named value that have the given type.
+	keepUsages := func(a *Analysis, name string, usageType string) {
+		for i, value := range a.Values {
+			if value.Name != name {
+				continue
+			}
+			var kept []AnalysisUsage
+			for _, usage := range value.Usages {
+				if usage.Type == usageType {
+					kept = append(kept, usage)
+				}
+			}
+			a.Values[i].Usages = kept
+		}
+	}
+	tests := []struct {
+		name       string
+		edit       func(a *Analysis)
+		wantCalls  int
+		wantValues []string
+	}{
+		{"unchanged", func(a *Analysis) {}, 3, []string{"dataToken", "orderId"}},
+		{"excluded source call", func(a *Analysis) { a.Calls[0].Include = false }, 2, []string{"orderId"}},
+		{"excluded using call", func(a *Analysis) { a.Calls[2].Include = false }, 2, []string{"dataToken"}},
+		{"excluded value", func(a *Analysis) { a.Values[0].Include = false }, 3, []string{"orderId"}},
+		{"value without a source", func(a *Analysis) { keepUsages(a, "orderId", "request") }, 3, []string{"dataToken"}},
+		{"value without a use", func(a *Analysis) { keepUsages(a, "orderId", "response") }, 3, []string{"dataToken"}},
+		{"external value without a source", func(a *Analysis) {
+			keepUsages(a, "orderId", "request")
+			a.Values[1].External = true
+		}, 3, []string{"dataToken", "orderId"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := chainTestAnalysis(t)
+			tt.edit(analysis)
+			callDetailsList, chainedValues, _, err := analysis.restore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(callDetailsList) != tt.wantCalls {
+				t.Errorf("%d calls restored, want %d", len(callDetailsList), tt.wantCalls)
+			}
+			var names []string
+			for _, cv := range chainedValues {
+				names = append(names, cv.VariableName)
+			}
+			if !reflect.DeepEqual(names, tt.wantValues) {
+				t.Errorf("restored values %v, want %v", names, tt.wantValues)
+			}
+			for _, callDetails := range callDetailsList {
+				for _, ref := range append(callDetails.RequestChainedValues, callDetails.ResponseChainedValues...) {
+					if ref.Source != callDetails {
+						t.Errorf("usage %s of %s is linked to another call", ref.ReferencePath, ref.Context.VariableName)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAnalysisRestoreErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(a *Analysis)
+	}{
+		{"call without an entry", func(a *Analysis) { a.Calls[1].Entry = nil }},
+		{"value without a name", func(a *Analysis) { a.Values[0].Name = "" }},
+		{"unknown call", func(a *Analysis) { a.Values[0].Usages[0].Call = 3 }},
+		{"negative call", func(a *Analysis) { a.Values[0].Usages[0].Call = -1 }},
+		{"unknown type", func(a *Analysis) { a.Values[0].Usages[0].Type = "trailer" }},
+		{"unknown location", func(a *Analysis) { a.Values[1].Usages[0].Location = "body_xml" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := chainTestAnalysis(t)
+			tt.edit(analysis)
+			if _, _, _, err := analysis.restore(); err == nil {
+				t.Error("restore accepted the edited analysis")
+			}
+		})
+	}
+}
+
+func TestParseAnalysisVersion(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr bool
+	}{
+		{`{"version": 1, "calls": [], "values": []}`, false},
+		{`{"version": 2, "calls": [], "values": []}`, true},
+		{`{"calls": [], "values": []}`, true},
+		{`version: 1`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			if _, err := parseAnalysis([]byte(tt.data), "analysis.json"); (err != nil) != tt.wantErr {
+				t.Errorf("parseAnalysis = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// chainTestAnalysis returns the analysis of chainTestHar, to be edited by hand.
+func chainTestAnalysis(t *testing.T) *Analysis {
+	t.Helper()
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	return buildAnalysis(callDetailsList, chainedValues, nil)
+}
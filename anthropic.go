@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AnthropicMessage represents a single message for the Anthropic Messages API.
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// AnthropicRequest is the request body sent to the Anthropic Messages API.
+type AnthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []AnthropicMessage `json:"messages"`
+}
+
+// AnthropicContentBlock is one block of the content returned by the Messages API.
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// AnthropicResponse represents the response from the Anthropic Messages API.
+type AnthropicResponse struct {
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+// anthropicProvider talks to the Anthropic Messages API.
+type anthropicProvider struct {
+	endpoint  string
+	model     string
+	apiKey    string
+	maxTokens int
+}
+
+func (p *anthropicProvider) Name() string  { return LLMProviderAnthropic }
+func (p *anthropicProvider) Model() string { return p.model }
+
+func (p *anthropicProvider) Endpoint() string { return p.endpoint }
+
+// Complete sends the messages to the Messages API and returns the concatenated text blocks.
+// Consecutive messages with the same role are merged, since the API expects alternating turns.
+func (p *anthropicProvider) Complete(ctx context.Context, messages []LLMMessage) (string, error) {
+	apiKey := resolveAPIKey(p.apiKey, "ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return "", markPermanent(fmt.Errorf("ANTHROPIC_API_KEY environment variable is not set"))
+	}
+
+	reqBody := AnthropicRequest{
+		Model:     p.model,
+		MaxTokens: p.maxTokens,
+	}
+	for _, m := range messages {
+		if m.Role == "system" {
+			reqBody.System = strings.TrimSpace(reqBody.System + "\n\n" + m.Content)
+			continue
+		}
+		last := len(reqBody.Messages) - 1
+		if last >= 0 && reqBody.Messages[last].Role == m.Role {
+			reqBody.Messages[last].Content += "\n\n" + m.Content
+			continue
+		}
+		reqBody.Messages = append(reqBody.Messages, AnthropicMessage{Role: m.Role, Content: m.Content})
+	}
+
+	headers := map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+
+	var anthropicResponse AnthropicResponse
+	if err := postLLMRequest(ctx, p.endpoint, headers, reqBody, &anthropicResponse); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, block := range anthropicResponse.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("no text content returned from Anthropic")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// brunoDialect is the res/bru API of Bruno scripts. Bruno has no cookie accessor, so cookies are
+// parsed from the Set-Cookie headers by a helper.
+var brunoDialect = scriptDialect{
+	parseBody: "var responseJson = res.getBody();",
+	header: func(name string) string {
+		return fmt.Sprintf("res.getHeader(%q)", strings.ToLower(name))
+	},
+	cookie: func(name string) string {
+		return fmt.Sprintf("getResponseCookie(%q)", name)
+	},
+	cookieHelper: []string{
+		"function getResponseCookie(name) {",
+		"  var cookies = [].concat(res.getHeader(\"set-cookie\") || []);",
+		"  for (var i = 0; i < cookies.length; i++) {",
+		"    var pair = cookies[i].split(\";\")[0];",
+		"    var eq = pair.indexOf(\"=\");",
+		"    if (pair.slice(0, eq).trim() === name) {",
+		"      return pair.slice(eq + 1).trim();",
+		"    }",
+		"  }",
+		"  return undefined;",
+		"}",
+	},
+	setVar: func(name string, expr string) string {
+		return fmt.Sprintf("bru.setVar(%q, %s);", name, expr)
+	},
+}
+
+// brunoMethods are the HTTP methods a .bru file can express.
+var brunoMethods = map[string]bool{
+	"get": true, "post": true, "put": true, "delete": true, "patch": true, "options": true, "head": true,
+}
+
+// brunoUnsafeFileChars matches characters that are not allowed or awkward in .bru file names.
+var brunoUnsafeFileChars = regexp.MustCompile(`[/\\:*?"<>|{}]+`)
+
+// BrunoConfig is the bruno.json file that marks a directory as a Bruno collection.
+type BrunoConfig struct {
+	Version string   `json:"version"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Ignore  []string `json:"ignore"`
+}
+
+// brunoExporter writes a Bruno collection: a directory with a bruno.json, one .bru file per call ordered
+// with seq, and a Default environment for pre-defined variables. Chained values are extracted with
+// post-response scripts that call bru.setVar.
+type brunoExporter struct{}
+
+func (brunoExporter) Name() string          { return "bruno" }
+func (brunoExporter) Description() string   { return "Bruno collection directory of .bru files" }
+func (brunoExporter) DefaultOutput() string { return "bruno-collection" }
+func (brunoExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	files := BuildBrunoCollection(callDetailsList, chainedValues)
+	for name, content := range files {
+		fullPath := filepath.Join(outputPath, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("error creating Bruno collection directory: %w", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("error writing Bruno collection: %w", err)
+		}
+	}
+	return nil
+}
+
+// BuildBrunoCollection returns the files of a Bruno collection, keyed by their path relative to the collection directory.
+func BuildBrunoCollection(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) map[string]string {
+	files := make(map[string]string)
+
+	usesJSONPath := false
+	seq := 0
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		seq++
+		var initScript []string
+		if i == 0 {
+			initScript = brunoInitScript(chainedValues)
+		}
+		content := buildBruFile(callDetails, seq, initScript)
+		if strings.Contains(content, "jsonpath.query(") {
+			usesJSONPath = true
+		}
+		files[brunoFileName(callDetails, seq)] = content
+	}
+
+	config, _ := json.MarshalIndent(BrunoConfig{
+		Version: "1",
+		Name:    "Generated Collection",
+		Type:    "collection",
+		Ignore:  []string{"node_modules", ".git"},
+	}, "", "  ")
+	files["bruno.json"] = string(config) + "\n"
+
+	if usesJSONPath {
+		// Scripts load the jsonpath module for predicate paths; Bruno installs it from package.json.
+		files["package.json"] = "{\n  \"name\": \"generated-collection\",\n  \"private\": true,\n  \"dependencies\": {\n    \"jsonpath\": \"^1.1.1\"\n  }\n}\n"
+	}
+
+	var envVars [][2]string
+	for _, cv := range chainedValues {
+		if cv.ExternalSource {
+			envVars = append(envVars, [2]string{cv.VariableName, cv.Value})
+		}
+	}
+	if len(envVars) > 0 {
+		files[filepath.Join("environments", "Default.bru")] = brunoDictBlock("vars", envVars)
+	}
+	return files
+}
+
+// brunoFileName returns the file name of a call's .bru file, prefixed with its sequence number so that
+// the files sort in call order.
+func brunoFileName(callDetails *CallDetails, seq int) string {
+	name := strings.TrimSpace(brunoUnsafeFileChars.ReplaceAllString(callDetails.Name, " "))
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		name = strings.ToLower(callDetails.Entry.Request.Method)
+	}
+	return fmt.Sprintf("%03d %s.bru", seq, name)
+}
+
+// buildBruFile renders a single request in Bruno's .bru format.
+func buildBruFile(callDetails *CallDetails, seq int, initScript []string) string {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	name := callDetails.Name
+	if name == "" {
+		name = request.Method + " " + request.URL
+	}
+	method := strings.ToLower(request.Method)
+	if !brunoMethods[method] {
+		log.Printf("Bruno cannot express the %s method; %q is exported as GET", request.Method, name)
+		method = "get"
+	}
+
+	var bodyMode, bodyBlock string
+	if request.PostData != nil && request.PostData.Text != "" {
+		text := ReplaceValuesInString(request.PostData.Text, refs)
+		switch request.PostData.MimeType {
+		case "application/json":
+			bodyMode, bodyBlock = "json", brunoTextBlock("body:json", text)
+		case "application/x-www-form-urlencoded":
+			bodyMode, bodyBlock = "formUrlEncoded", brunoDictBlock("body:form-urlencoded", brunoFormPairs(request.PostData.Text, refs))
+		default:
+			bodyMode, bodyBlock = "text", brunoTextBlock("body:text", text)
+		}
+	} else {
+		bodyMode = "none"
+	}
+
+	var blocks []string
+	blocks = append(blocks, brunoDictBlock("meta", [][2]string{{"name", name}, {"type", "http"}, {"seq", fmt.Sprint(seq)}}))
+	blocks = append(blocks, brunoDictBlock(method, [][2]string{
+		{"url", ReplaceValuesInString(request.URL, refs)},
+		{"body", bodyMode},
+		{"auth", "none"},
+	}))
+
+	var headers [][2]string
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) {
+			continue
+		}
+		headers = append(headers, [2]string{header.Name, ReplaceValuesInString(header.Value, refs)})
+	}
+	if len(headers) > 0 {
+		blocks = append(blocks, brunoDictBlock("headers", headers))
+	}
+	if bodyBlock != "" {
+		blocks = append(blocks, bodyBlock)
+	}
+	if len(initScript) > 0 {
+		blocks = append(blocks, brunoTextBlock("script:pre-request", strings.Join(initScript, "\n")))
+	}
+	if script := brunoPostResponseScript(callDetails.ResponseChainedValues); script != nil {
+		blocks = append(blocks, brunoTextBlock("script:post-response", strings.Join(script, "\n")))
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// brunoPostResponseScript returns the script extracting the response-chained values of a call, or nil if there are none.
+func brunoPostResponseScript(chainedValues []*ValueReference) []string {
+	hasResponseValues := false
+	for _, ref := range chainedValues {
+		if ref.SourceType == SourceTypeResponse && ref.Context != nil {
+			hasResponseValues = true
+			break
+		}
+	}
+	if !hasResponseValues {
+		return nil
+	}
+	lines := brunoDialect.responseScriptLines(chainedValues)
+	for _, line := range lines {
+		if strings.Contains(line, "jsonpath.query(") {
+			lines = append([]string{"var jsonpath = require(\"jsonpath\");"}, lines...)
+			break
+		}
+	}
+	return lines
+}
+
+// brunoInitScript returns the pre-request script computing pre-defined variables that have an initializer.
+func brunoInitScript(chainedValues []*ChainedValueContext) []string {
+	var scriptLines []string
+	for _, cv := range chainedValues {
+		if cv.InitScript == "" {
+			continue
+		}
+		scriptLines = append(scriptLines,
+			"try {",
+			"  var result = {};",
+			cv.InitScript,
+			"  "+brunoDialect.setVar(cv.VariableName, "result"),
+			"} catch (e) {",
+			fmt.Sprintf("  console.error('Error initializing variable %s:', e);", cv.VariableName),
+			"}",
+		)
+	}
+	return scriptLines
+}
+
+// brunoFormPairs decodes a URL-encoded form body into key/value pairs with chained values replaced.
+// Bruno encodes form values itself, so substitution happens on the decoded values.
+func brunoFormPairs(body string, refs []*ValueReference) [][2]string {
+	pairs := decodeFormBody(body)
+	for i := range pairs {
+		pairs[i][1] = ReplaceValuesInString(pairs[i][1], refs)
+	}
+	return pairs
+}
+
+// brunoDictBlock renders a .bru block of "key: value" lines.
+func brunoDictBlock(name string, pairs [][2]string) string {
+	var sb strings.Builder
+	sb.WriteString(name + " {\n")
+	for _, pair := range pairs {
+		fmt.Fprintf(&sb, "  %s: %s\n", pair[0], pair[1])
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// brunoTextBlock renders a .bru block holding free text such as a body or script, indented by two spaces.
+func brunoTextBlock(name string, text string) string {
+	var sb strings.Builder
+	sb.WriteString(name + " {\n")
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString("  " + line + "\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
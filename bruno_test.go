@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildBrunoCollection(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	files := BuildBrunoCollection(callDetailsList, chainedValues)
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"001 ", []string{"var dataToken = responseJson.data.token;", `bru.setVar("dataToken", dataToken);`}},
+		{"002 ", []string{"Authorization: Bearer {{dataToken}}", "var orderId = responseJson.order.id;", `bru.setVar("orderId", orderId);`}},
+		{"003 ", []string{"url: https://api.example.com/v1/orders/{{orderId}}", "Authorization: Bearer {{dataToken}}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content := brunoFileWithPrefix(t, files, tt.file)
+			for _, want := range tt.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("missing %q in:\n%s", want, content)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildBrunoCollectionUnsupportedMethod(t *testing.T) {
+	har := chainTestHar()
+	har.Log.Entries[2].Request.Method = "PROPFIND"
+	callDetailsList, chainedValues := analyzeTestHar(t, har)
+	logged := captureLog(t)
+
+	content := brunoFileWithPrefix(t, BuildBrunoCollection(callDetailsList, chainedValues), "003 ")
+	if !strings.Contains(content, "\nget {\n") {
+		t.Errorf("PROPFIND request is not exported as GET:\n%s", content)
+	}
+	if !strings.Contains(logged.String(), "Bruno cannot express the PROPFIND method") {
+		t.Errorf("fallback to GET was not logged: %q", logged.String())
+	}
+}
+
+// brunoFileWithPrefix returns the content of the collection file whose name starts with prefix.
+func brunoFileWithPrefix(t *testing.T, files map[string]string, prefix string) string {
+	t.Helper()
+	for name, content := range files {
+		if strings.HasPrefix(name, prefix) {
+			return content
+		}
+	}
+	t.Fatalf("no file starting with %q in %d files", prefix, len(files))
+	return ""
+}
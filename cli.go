@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Exit statuses of chainer.
+const (
+	exitProcessingError = 1
+	exitUsageError      = 2
+)
+
+// The commands of chainer. commandConvert runs when none is given, for compatibility with flag-only invocations.
+const (
+	commandConvert  = "convert"
+	commandAnalyze  = "analyze"
+	commandRender   = "render"
+	commandExplain  = "explain"
+	commandValidate = "validate"
+	commandDiff     = "diff"
+)
+
+// usageError is an error in the command line, as opposed to an error processing the input.
+type usageError struct {
+	// command is the command whose flags or arguments are wrong, or "" for the global ones.
+	command string
+	err     error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// command is a subcommand of chainer.
+type command struct {
+	name string
+	// args is the synopsis of the positional arguments, if the command takes any.
+	args    string
+	summary string
+	run     func(ctx context.Context, cmd *command, args []string) error
+}
+
+var commands = []*command{
+	{name: commandConvert, summary: "Analyze a HAR file and write it in an output format", run: runConvert},
+	{name: commandAnalyze, summary: "Analyze a HAR file and write an editable JSON analysis file", run: runAnalyze},
+	{name: commandRender, summary: "Write an analysis file in an output format", run: runRender},
+	{name: commandExplain, summary: "Describe how each chained value is extracted and where it is used", run: runExplain},
+	{name: commandValidate, summary: "Check an analysis file for inconsistencies", run: runValidate},
+	{name: commandDiff, args: "<old analysis> <new analysis>", summary: "Compare the calls and chained values of two analysis files", run: runDiff},
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func main() {
+	err := run(os.Args[1:])
+	status := exitStatus(err)
+	if status == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		help := "chainer -help"
+		if usageErr.command != "" {
+			help = "chainer " + usageErr.command + " -help"
+		}
+		fmt.Fprintf(os.Stderr, "Run '%s' for usage.\n", help)
+	}
+	os.Exit(status)
+}
+
+// exitStatus returns the exit status for the error returned by run.
+func exitStatus(err error) int {
+	var usageErr *usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		return exitUsageError
+	}
+	return exitProcessingError
+}
+
+// run runs the command given by the command-line arguments, without the program name.
+func run(args []string) error {
+	// Cancel outstanding LLM requests and retries on Ctrl-C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	args, err := parseGlobalFlags(args)
+	if err != nil {
+		return err
+	}
+	name := commandConvert
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		return runHelp(args)
+	}
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		return &usageError{err: fmt.Errorf("unknown command %q", name)}
+	}
+	err = cmd.run(ctx, cmd, args)
+	if errors.Is(err, errNotHAR) {
+		// The wrong kind of file was passed to -file.
+		return &usageError{command: cmd.name, err: err}
+	}
+	return err
+}
+
+// registerGlobalFlags adds the flags accepted before the command and by every command.
+func registerGlobalFlags(fs *flag.FlagSet, quiet *bool) {
+	fs.BoolVar(quiet, "quiet", false, "Suppress progress and diagnostic output; results and errors are still reported")
+}
+
+// applyGlobalFlags applies the global flags.
+func applyGlobalFlags(quiet bool) {
+	if quiet {
+		log.SetOutput(io.Discard)
+	}
+}
+
+// parseGlobalFlags parses the global flags preceding the command and returns the remaining arguments.
+// Parsing stops at the first other argument, so that "chainer -file=x.har" still runs convert.
+func parseGlobalFlags(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("chainer", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	var quiet bool
+	registerGlobalFlags(fs, &quiet)
+
+	n := 0
+	for n < len(args) && isFlagOf(fs, args[n]) {
+		n++
+	}
+	if err := fs.Parse(args[:n]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout)
+			return nil, err
+		}
+		return nil, &usageError{err: err}
+	}
+	applyGlobalFlags(quiet)
+	return args[n:], nil
+}
+
+// isFlagOf reports whether arg is one of the flags of fs or a request for help.
+func isFlagOf(fs *flag.FlagSet, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+		return false
+	}
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	return name == "h" || name == "help" || fs.Lookup(name) != nil
+}
+
+// printUsage prints the overview of the commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: chainer [global flags] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "chainer finds the values that chain the calls of a HAR recording together, such as tokens and ids")
+	fmt.Fprintln(w, "returned by one call and sent by later ones, and exports the calls as a collection, test or script")
+	fmt.Fprintln(w, "that extracts and reuses them.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "  %-9s %s\n", "help", "Show the usage of a command")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Without a command, chainer runs %s.\n", commandConvert)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	fs := flag.NewFlagSet("chainer", flag.ContinueOnError)
+	fs.SetOutput(w)
+	registerGlobalFlags(fs, new(bool))
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "The exit status is 0 on success, %d if processing fails and %d for command-line errors.\n", exitProcessingError, exitUsageError)
+	fmt.Fprintln(w, "Run 'chainer <command> -help' for the flags of a command.")
+}
+
+// runHelp prints the usage of the named command, or the overview.
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return nil
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		return &usageError{err: fmt.Errorf("unknown command %q", args[0])}
+	}
+	// The command prints its usage, with its flags, when asked for help.
+	err := cmd.run(context.Background(), cmd, []string{"-help"})
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// flagSet returns the flag set of the command, with the global flags registered into f.
+func (cmd *command) flagSet(f *flags) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	// Errors are reported by main; the usage is only printed when asked for.
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	registerGlobalFlags(fs, &f.quiet)
+	return fs
+}
+
+// parse parses the flags of the command and validates them with checks. It returns flag.ErrHelp after printing
+// the usage if asked for help, and a usageError for invalid flags or unexpected arguments.
+func (cmd *command) parse(fs *flag.FlagSet, args []string, f *flags, checks ...func() error) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			cmd.printUsage(os.Stdout, fs)
+			return err
+		}
+		return &usageError{command: cmd.name, err: err}
+	}
+	if cmd.args == "" && fs.NArg() > 0 {
+		return &usageError{command: cmd.name, err: fmt.Errorf("unexpected argument %q", fs.Arg(0))}
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			return &usageError{command: cmd.name, err: err}
+		}
+	}
+	applyGlobalFlags(f.quiet)
+	return nil
+}
+
+func (cmd *command) printUsage(w io.Writer, fs *flag.FlagSet) {
+	synopsis := "chainer " + cmd.name + " [flags]"
+	if cmd.args != "" {
+		synopsis += " " + cmd.args
+	}
+	fmt.Fprintln(w, "Usage: "+synopsis)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, cmd.summary+".")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+}
+
+// registerHarFlags adds the flags of the commands that analyze a HAR file.
+func (f *flags) registerHarFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.harFilePath, "file", "", "Path to the HAR file, or - to read it from standard input")
+	fs.StringVar(&f.varsFilePath, "vars", "", "Path to the JSON file with pre-defined variables")
+	fs.StringVar(&f.namingMode, "naming", NamingModeAI, "How to name variables and calls: ai (uses the LLM) or heuristic (offline, deterministic)")
+	fs.StringVar(&f.cookieMode, "cookies", CookieModeVars, "How to chain cookies: vars (read from responses into variables) or jar (leave them to the client's cookie jar)")
+	fs.StringVar(&f.llm.Provider, "llm", envOrDefault("CHAINER_LLM_PROVIDER", LLMProviderOpenAI), "LLM provider: openai, azure, anthropic or local (OpenAI-compatible server)")
+	fs.StringVar(&f.llm.Model, "llm-model", os.Getenv("CHAINER_LLM_MODEL"), "Model name (deployment name for azure); defaults depend on the provider")
+	fs.StringVar(&f.llm.BaseURL, "llm-url", os.Getenv("CHAINER_LLM_URL"), "Override the LLM endpoint (Azure resource endpoint or OpenAI-compatible base URL)")
+	fs.StringVar(&f.llm.APIVersion, "llm-api-version", os.Getenv("CHAINER_LLM_API_VERSION"), "API version for the azure provider")
+	fs.StringVar(&f.llm.APIKey, "llm-api-key", "", "API key, overriding CHAINER_LLM_API_KEY and the provider's variable; the only key sent to a local server")
+	fs.StringVar(&f.cacheMode, "cache", CacheModeOn, "LLM response cache: on, off (bypass) or refresh (ignore cached responses and overwrite them)")
+	fs.StringVar(&f.cacheDir, "cache-dir", defaultLLMCacheDir(), "Directory holding cached LLM responses")
+	fs.DurationVar(&f.cachePrune, "cache-prune", 0, "Remove cached LLM responses unused for longer than this duration (e.g. 720h) before running")
+}
+
+// checkHarFlags validates the flags added by registerHarFlags.
+func (f *flags) checkHarFlags() error {
+	if f.harFilePath == "" {
+		return errors.New("missing HAR file path (-file)")
+	}
+	if f.namingMode != NamingModeAI && f.namingMode != NamingModeHeuristic {
+		return fmt.Errorf("invalid -naming value %q (expected %s or %s)", f.namingMode, NamingModeAI, NamingModeHeuristic)
+	}
+	if f.cookieMode != CookieModeVars && f.cookieMode != CookieModeJar {
+		return fmt.Errorf("invalid -cookies value %q (expected %s or %s)", f.cookieMode, CookieModeVars, CookieModeJar)
+	}
+	if f.cacheMode != CacheModeOn && f.cacheMode != CacheModeOff && f.cacheMode != CacheModeRefresh {
+		return fmt.Errorf("invalid -cache value %q (expected %s, %s or %s)", f.cacheMode, CacheModeOn, CacheModeOff, CacheModeRefresh)
+	}
+	return nil
+}
+
+// registerOutputFlags adds the flags of the commands that write an output format.
+func (f *flags) registerOutputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.format, "format", exporters[0].Name(), exporterUsage())
+	fs.StringVar(&f.outputPath, "output", "", "Output path (default depends on -format, e.g. collection.json for postman)")
+	fs.StringVar(&f.goPackage, "go-package", "", "Package name of the generated Go test (default: the name of the output directory)")
+}
+
+// checkOutputFlags validates the flags added by registerOutputFlags and defaults the output path.
+func (f *flags) checkOutputFlags() error {
+	exporter := lookupExporter(f.format)
+	if exporter == nil {
+		return fmt.Errorf("invalid -format value %q", f.format)
+	}
+	if f.outputPath == "" {
+		f.outputPath = exporter.DefaultOutput()
+	}
+	return configureGoTest(f.goPackage)
+}
+
+// readInput reads the file at path, or standard input if path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
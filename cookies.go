@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Cookie handling modes for the generated collection.
+const (
+	// CookieModeVars chains cookies like any other value: the generated code reads them from the response
+	// into variables and requests send them through those variables in the Cookie header.
+	CookieModeVars = "vars"
+
+	// CookieModeJar relies on the client's cookie jar: cookies set by an earlier response are left out of
+	// later Cookie headers and are not turned into variables.
+	CookieModeJar = "jar"
+)
+
+// Cookie represents a cookie as recorded in the HAR "cookies" arrays of requests and responses.
+type Cookie struct {
+	// Name is the name of the cookie.
+	Name string `json:"name"`
+	// Value is the value of the cookie.
+	Value string `json:"value"`
+	// Path is the path attribute (responses only).
+	Path string `json:"path,omitempty"`
+	// Domain is the domain attribute (responses only).
+	Domain string `json:"domain,omitempty"`
+	// Expires is the expiry time in ISO 8601 format (responses only).
+	Expires string `json:"expires,omitempty"`
+	// HTTPOnly is set if the cookie is flagged HttpOnly (responses only).
+	HTTPOnly bool `json:"httpOnly,omitempty"`
+	// Secure is set if the cookie is flagged Secure (responses only).
+	Secure bool `json:"secure,omitempty"`
+}
+
+// isCookieHeader reports whether a header carries cookies, which are processed per cookie instead of as a whole.
+func isCookieHeader(name string) bool {
+	return strings.EqualFold(name, "Cookie") || strings.EqualFold(name, "Set-Cookie")
+}
+
+// processCookies converts the cookies of a request or response into one ValueReference per cookie.
+// Cookies are read from the Cookie or Set-Cookie headers (attributes such as Path are dropped) and from
+// the HAR cookies array; a cookie present in both is reported once.
+func processCookies(headers []Header, harCookies []Cookie, sourceType SourceType) []*ValueReference {
+	header := make(http.Header)
+	for _, h := range headers {
+		if isCookieHeader(h.Name) {
+			header.Add(http.CanonicalHeaderKey(h.Name), h.Value)
+		}
+	}
+	var parsed []*http.Cookie
+	if sourceType == SourceTypeRequest {
+		parsed = (&http.Request{Header: header}).Cookies()
+	} else {
+		parsed = (&http.Response{Header: header}).Cookies()
+	}
+
+	var refs []*ValueReference
+	seen := make(map[string]bool)
+	addCookie := func(name string, value string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		refs = append(refs, &ValueReference{
+			Value:          value,
+			ReferencePath:  "cookie." + name,
+			CookieName:     name,
+			SourceLocation: SourceLocationCookie,
+		})
+	}
+	for _, c := range parsed {
+		addCookie(c.Name, c.Value)
+	}
+	for _, c := range harCookies {
+		addCookie(c.Name, c.Value)
+	}
+	return refs
+}
+
+// applyCookieMode adjusts the chained values for the selected cookie mode. In jar mode, cookie usages of
+// values that a response set as a cookie are dropped, and the requests record the names of those cookies
+// in JarCookies so that exporters leave them out of the recorded Cookie headers: the client's cookie jar
+// sends them. Values that are also used outside cookies remain chained. In vars mode the chained values
+// are returned unchanged.
+func applyCookieMode(mode string, chainedValues []*ChainedValueContext) []*ChainedValueContext {
+	if mode != CookieModeJar {
+		return chainedValues
+	}
+
+	var result []*ChainedValueContext
+	for _, cv := range chainedValues {
+		if !setAsCookie(cv) {
+			result = append(result, cv)
+			continue
+		}
+		var usages []*ValueReference
+		requestUsages := 0
+		for _, usage := range cv.AllUsages {
+			if usage.SourceType == SourceTypeRequest && usage.SourceLocation == SourceLocationCookie {
+				usage.Source.JarCookies = appendUnique(usage.Source.JarCookies, usage.CookieName)
+				continue
+			}
+			if usage.SourceType == SourceTypeRequest {
+				requestUsages++
+			}
+			usages = append(usages, usage)
+		}
+		if requestUsages > 0 {
+			cv.AllUsages = usages
+			result = append(result, cv)
+		}
+	}
+	return result
+}
+
+// setAsCookie reports whether the first response providing a chained value set it as a cookie.
+func setAsCookie(cv *ChainedValueContext) bool {
+	for _, usage := range cv.AllUsages {
+		if usage.SourceType == SourceTypeResponse {
+			return usage.SourceLocation == SourceLocationCookie
+		}
+	}
+	return false
+}
+
+// appendUnique appends s to list unless it is already there.
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// requestHeaders returns the recorded headers of a call as exporters send them: the cookies left to the
+// cookie jar are removed from the Cookie headers, and headers that end up empty are dropped. The recorded
+// entry itself is not modified.
+func requestHeaders(callDetails *CallDetails) []Header {
+	headers := callDetails.Entry.Request.Headers
+	if len(callDetails.JarCookies) == 0 {
+		return headers
+	}
+	jar := make(map[string]bool)
+	for _, name := range callDetails.JarCookies {
+		jar[name] = true
+	}
+
+	var result []Header
+	for _, h := range headers {
+		if !strings.EqualFold(h.Name, "Cookie") {
+			result = append(result, h)
+			continue
+		}
+		var pairs []string
+		for _, pair := range strings.Split(h.Value, ";") {
+			pair = strings.TrimSpace(pair)
+			name, _, _ := strings.Cut(pair, "=")
+			if pair == "" || jar[strings.TrimSpace(name)] {
+				continue
+			}
+			pairs = append(pairs, pair)
+		}
+		if len(pairs) > 0 {
+			h.Value = strings.Join(pairs, "; ")
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// hasChainedCookie reports whether any of the request references is a cookie sent as a chained value.
+func hasChainedCookie(refs []*ValueReference) bool {
+	for _, ref := range refs {
+		if ref.SourceLocation == SourceLocationCookie && ref.Context != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		jar    []string
+		want   []Header
+	}{
+		{"no jar cookies", "SESSIONID=abc; theme=dark", nil, []Header{{Name: "Accept", Value: "*/*"}, {Name: "Cookie", Value: "SESSIONID=abc; theme=dark"}}},
+		{"one jar cookie", "SESSIONID=abc; theme=dark", []string{"SESSIONID"}, []Header{{Name: "Accept", Value: "*/*"}, {Name: "Cookie", Value: "theme=dark"}}},
+		{"name prefix is not a match", "SESSIONID2=abc;SESSIONID=def", []string{"SESSIONID"}, []Header{{Name: "Accept", Value: "*/*"}, {Name: "Cookie", Value: "SESSIONID2=abc"}}},
+		{"all jar cookies", "SESSIONID=abc; theme=dark", []string{"SESSIONID", "theme"}, []Header{{Name: "Accept", Value: "*/*"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorded := []Header{{Name: "Accept", Value: "*/*"}, {Name: "Cookie", Value: tt.cookie}}
+			callDetails := &CallDetails{Entry: &Entry{Request: Request{Headers: recorded}}, JarCookies: tt.jar}
+			if got := requestHeaders(callDetails); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requestHeaders = %+v, want %+v", got, tt.want)
+			}
+			if recorded[1].Value != tt.cookie {
+				t.Errorf("recorded Cookie header changed to %q", recorded[1].Value)
+			}
+		})
+	}
+}
+
+func TestApplyCookieModeJarKeepsRecording(t *testing.T) {
+	login := jsonEntry("POST", "https://api.example.com/login", "", "", `{}`)
+	login.Response.Headers = []Header{{Name: "Set-Cookie", Value: "SESSIONID=abcDEF123456xyz; Path=/"}}
+	profile := jsonEntry("GET", "https://api.example.com/profile", "", "", `{}`)
+	profile.Request.Headers = []Header{{Name: "Cookie", Value: "SESSIONID=abcDEF123456xyz; theme=dark"}}
+	har := HAR{Log: Log{Entries: []Entry{login, profile}}}
+
+	calls, values := analyzeTestHar(t, har)
+	values = applyCookieMode(CookieModeJar, values)
+	if len(values) != 0 {
+		t.Errorf("jar mode kept %d chained values, want 0", len(values))
+	}
+	if got := calls[1].Entry.Request.Headers[0].Value; got != "SESSIONID=abcDEF123456xyz; theme=dark" {
+		t.Errorf("recorded Cookie header = %q, want it unchanged", got)
+	}
+	if want := []Header{{Name: "Cookie", Value: "theme=dark"}}; !reflect.DeepEqual(requestHeaders(calls[1]), want) {
+		t.Errorf("rendered headers = %+v, want %+v", requestHeaders(calls[1]), want)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// curlExporter writes a bash script replaying the calls with curl. Each response is saved to temporary
+// files, chained values are read from them with jq and helper functions into shell variables, and later
+// requests reference the variables as ${name}.
+type curlExporter struct{}
+
+func (curlExporter) Name() string          { return "curl" }
+func (curlExporter) Description() string   { return "Bash script of curl commands" }
+func (curlExporter) DefaultOutput() string { return "chain.sh" }
+func (curlExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	script := BuildCurlScript(callDetailsList, chainedValues)
+	if err := os.WriteFile(outputPath, []byte(script), 0755); err != nil {
+		return fmt.Errorf("error writing curl script: %w", err)
+	}
+	return nil
+}
+
+// curlScriptHeader sets up the temporary files shared by all calls and the helpers reading responses.
+const curlScriptHeader = `set -euo pipefail
+
+resp_body=$(mktemp)
+resp_headers=$(mktemp)
+cookie_jar=$(mktemp)
+trap 'rm -f "$resp_body" "$resp_headers" "$cookie_jar"' EXIT
+
+# expect_status CODE NAME fails if the last call returned another status.
+expect_status() {
+  if [ "$resp_status" != "$1" ]; then
+    echo "$2: expected status $1, got $resp_status" >&2
+    cat "$resp_body" >&2
+    exit 1
+  fi
+}
+
+# response_header NAME prints the first value of a header of the last response.
+response_header() {
+  tr -d '\r' < "$resp_headers" | grep -i "^$1:" | head -n 1 | sed 's/^[^:]*:[[:space:]]*//'
+}
+
+# response_cookie NAME prints the value of a cookie set by the last response.
+response_cookie() {
+  tr -d '\r' < "$resp_headers" | grep -i '^set-cookie:' | sed 's/^[^:]*:[[:space:]]*//' | grep "^$1=" | head -n 1 | sed 's/^[^=]*=//; s/;.*//'
+}
+
+# jwt_part TOKEN INDEX prints the decoded header (0) or payload (1) of a JWT.
+jwt_part() {
+  local part
+  part=$(printf '%s' "${1#Bearer }" | cut -d. -f$(($2 + 1)) | tr '_-' '/+')
+  while [ $((${#part} % 4)) -ne 0 ]; do part="$part="; done
+  printf '%s' "$part" | base64 -d
+}
+`
+
+// BuildCurlScript renders the calls as a bash script. It requires curl and jq.
+func BuildCurlScript(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) string {
+	var lines []string
+	lines = append(lines, "#!/usr/bin/env bash", "# Replays a recorded HAR flow with curl. Requires curl and jq.", curlScriptHeader)
+
+	var predefined []string
+	for _, cv := range chainedValues {
+		if !cv.ExternalSource {
+			continue
+		}
+		if cv.InitScript != "" {
+			log.Printf("curl: the initializer of %s is not run; set the %s environment variable instead", cv.VariableName, cv.VariableName)
+		}
+		predefined = append(predefined, fmt.Sprintf("%s=${%s-%s}", cv.VariableName, cv.VariableName, shellQuote(cv.Value)))
+	}
+	if len(predefined) > 0 {
+		lines = append(lines, "# Pre-defined variables; override them from the environment.")
+		lines = append(lines, predefined...)
+		lines = append(lines, "")
+	}
+
+	extracted := make(map[string]bool)
+	for _, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		lines = append(lines, curlCall(callDetails, extracted)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// curlCall renders the curl command of one call followed by the status check and the extraction of its
+// response-chained values.
+func curlCall(callDetails *CallDetails, extracted map[string]bool) []string {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	name := callDetails.Name
+	if name == "" {
+		name = request.Method + " " + request.URL
+	}
+	name = strings.ReplaceAll(name, "\n", " ")
+
+	args := []string{"curl -sS"}
+	switch request.Method {
+	case "GET":
+	case "HEAD":
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X "+shellQuote(request.Method))
+	}
+	args = append(args, shellTemplate(request.URL, refs))
+
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") || clientManagedHeaders[http.CanonicalHeaderKey(header.Name)] {
+			continue
+		}
+		args = append(args, "-H "+shellTemplate(header.Name+": "+header.Value, refs))
+	}
+	// Chained cookies are sent in the recorded Cookie header, so the jar would send them twice.
+	if !hasChainedCookie(refs) {
+		args = append(args, `-b "$cookie_jar"`)
+	}
+	args = append(args, `-c "$cookie_jar"`)
+
+	if request.PostData != nil && request.PostData.Text != "" {
+		if request.PostData.MimeType == "application/x-www-form-urlencoded" {
+			// curl encodes the fields itself, so chained values are substituted in the decoded values.
+			for _, pair := range decodeFormBody(request.PostData.Text) {
+				args = append(args, "--data-urlencode "+shellTemplate(pair[0]+"="+pair[1], refs))
+			}
+		} else {
+			args = append(args, "--data-raw "+shellTemplate(request.PostData.Text, refs))
+		}
+	}
+	args = append(args, `-o "$resp_body" -D "$resp_headers" -w '%{http_code}'`)
+
+	lines := []string{"# " + name, "echo " + shellQuote("==> "+name) + " >&2"}
+	lines = append(lines, "resp_status=$("+strings.Join(args, " \\\n  ")+")")
+	if status := callDetails.Entry.Response.Status; status != 0 {
+		lines = append(lines, fmt.Sprintf("expect_status %d %s", status, shellQuote(name)))
+	}
+
+	for _, ref := range callDetails.ResponseChainedValues {
+		if ref.SourceType != SourceTypeResponse || ref.Context == nil || extracted[ref.Context.VariableName] {
+			continue
+		}
+		cv := ref.Context
+		extracted[cv.VariableName] = true
+		command, err := curlExtraction(ref)
+		if err != nil {
+			log.Printf("curl: %s keeps its recorded value because %s cannot be read: %v", cv.VariableName, ref.ReferencePath, err)
+			lines = append(lines, fmt.Sprintf("%s=%s", cv.VariableName, shellQuote(cv.Value)))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s=$(%s)", cv.VariableName, command))
+		}
+		for _, t := range usedTransforms(cv) {
+			lines = append(lines, fmt.Sprintf("%s=$(%s)", cv.VariableName+t.VariableSuffix(), shellTransform(t.Name(), cv.VariableName)))
+		}
+	}
+	return append(lines, "")
+}
+
+// curlExtraction returns the shell command printing a value of the last response.
+func curlExtraction(ref *ValueReference) (string, error) {
+	switch {
+	case ref.JWTParent != nil:
+		token, err := curlExtraction(ref.JWTParent)
+		if err != nil {
+			return "", err
+		}
+		expr, err := jwtClaimExpr(ref.JWTClaimPath)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("jwt_part \"$(%s)\" %d | jq -er %s", token, jwtPartIndex(ref.JWTClaimPath), shellQuote(expr.JQExpression())), nil
+	case ref.SourceLocation == SourceLocationCookie:
+		return "response_cookie " + shellQuote(ref.CookieName), nil
+	case ref.SourceLocation == SourceLocationHeader:
+		if strings.EqualFold(ref.HeaderName, "Authorization") {
+			return fmt.Sprintf("response_header %s | sed 's/^Bearer //'", shellQuote(ref.HeaderName)), nil
+		}
+		return "response_header " + shellQuote(ref.HeaderName), nil
+	}
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("jq -er %s \"$resp_body\"", shellQuote(expr.JQExpression())), nil
+}
+
+// shellTransform returns the command printing the variable name encoded with a value transform.
+func shellTransform(transform string, name string) string {
+	switch transform {
+	case "urlencoded":
+		return fmt.Sprintf("jq -rn --arg v \"$%s\" '$v | @uri'", name)
+	case "base64":
+		return fmt.Sprintf("printf '%%s' \"$%s\" | base64 | tr -d '\\n'", name)
+	case "base64url":
+		return fmt.Sprintf("printf '%%s' \"$%s\" | base64 | tr -d '\\n=' | tr '+/' '-_'", name)
+	case "json_escaped":
+		return fmt.Sprintf("jq -rn --arg v \"$%s\" '$v | tojson | .[1:-1]'", name)
+	}
+	return fmt.Sprintf("printf '%%s' \"$%s\"", name)
+}
+
+// shellTemplate renders a recorded string as a shell word. Strings without chained values are single-quoted;
+// others are double-quoted with the chained values expanded as ${name}.
+func shellTemplate(s string, refs []*ValueReference) string {
+	segments := splitChainedValues(s, refs)
+	hasRefs := false
+	for _, segment := range segments {
+		if segment.ref != nil {
+			hasRefs = true
+			break
+		}
+	}
+	if !hasRefs {
+		return shellQuote(s)
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	var sb strings.Builder
+	sb.WriteString(`"`)
+	for _, segment := range segments {
+		if segment.ref == nil {
+			sb.WriteString(escaper.Replace(segment.literal))
+			continue
+		}
+		sb.WriteString("${" + transformedVariableName(segment.ref) + "}")
+	}
+	sb.WriteString(`"`)
+	return sb.String()
+}
+
+// shellSafeWord matches words that need no quoting in the shell.
+var shellSafeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote renders s as a shell word, single-quoted unless it needs no quoting.
+func shellQuote(s string) string {
+	if shellSafeWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
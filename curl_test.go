@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellQuoting(t *testing.T) {
+	refs := []*ValueReference{{Value: "abc123", Context: &ChainedValueContext{VariableName: "token"}}}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"word", shellQuote(trickyText), "'a'\\''b\"c $HOME `id` \\ ${x}\n\tabc123'"},
+		{"safe word", shellQuote("https://api.example.com/v1/a-b_c?x=1,2"), "'https://api.example.com/v1/a-b_c?x=1,2'"},
+		{"plain word", shellQuote("a-b_c/1.2"), "a-b_c/1.2"},
+		{"empty word", shellQuote(""), "''"},
+		{"template", shellTemplate(trickyText, refs), "\"a'b\\\"c \\$HOME \\`id\\` \\\\ \\${x}\n\t${token}\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCurlScript(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	script := BuildCurlScript(callDetailsList, chainedValues)
+	for _, want := range []string{
+		`dataToken=$(jq -er .data.token "$resp_body")`,
+		`-H "Authorization: Bearer ${dataToken}"`,
+		`orderId=$(jq -er .order.id "$resp_body")`,
+		`"https://api.example.com/v1/orders/${orderId}"`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("missing %q in:\n%s", want, script)
+		}
+	}
+}
+
+func TestBuildCurlScriptUnreadablePath(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	callDetailsList[1].ResponseChainedValues[0].ReferencePath = "order["
+	logged := captureLog(t)
+
+	script := BuildCurlScript(callDetailsList, chainedValues)
+	if want := "\norderId=ord55123\n"; !strings.Contains(script, want) {
+		t.Errorf("missing the recorded value %q in:\n%s", want, script)
+	}
+	if !strings.Contains(logged.String(), "curl: orderId keeps its recorded value") {
+		t.Errorf("fallback to the recorded value was not logged: %q", logged.String())
+	}
+}
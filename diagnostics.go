@@ -0,0 +1,22 @@
+package main
+
+// DroppedValue is a candidate chained value that the analysis discarded, with the reason why.
+type DroppedValue struct {
+	Value  string
+	Reason string
+	Usages []*ValueReference
+}
+
+// returnedBeforeUse reports whether a value appears in a response before one of its request usages,
+// that is, whether it has the shape of a chained value.
+func returnedBeforeUse(refs []*ValueReference) bool {
+	seenResponse := false
+	for _, ref := range refs {
+		if ref.SourceType == SourceTypeResponse {
+			seenResponse = true
+		} else if seenResponse {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dotExporter writes a Graphviz DOT graph of the dependencies between calls. Calls and chained variables
+// are nodes; an edge runs from the call producing each variable to the variable and from the variable to
+// every request using it, labeled with the reference path. Calls with no dependencies are highlighted.
+type dotExporter struct{}
+
+func (dotExporter) Name() string          { return "dot" }
+func (dotExporter) Description() string   { return "Graphviz DOT dependency graph" }
+func (dotExporter) DefaultOutput() string { return "chain.dot" }
+func (dotExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	if err := os.WriteFile(outputPath, []byte(BuildDOTGraph(callDetailsList, chainedValues)), 0644); err != nil {
+		return fmt.Errorf("error writing DOT graph: %w", err)
+	}
+	return nil
+}
+
+// BuildDOTGraph renders the calls and chained values as a DOT digraph.
+func BuildDOTGraph(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) string {
+	callIDs := make(map[*CallDetails]string)
+	for i, callDetails := range callDetailsList {
+		if callDetails != nil {
+			callIDs[callDetails] = fmt.Sprintf("call%d", i+1)
+		}
+	}
+
+	var edges []string
+	connected := make(map[string]bool)
+	seenEdges := make(map[string]bool)
+	addEdge := func(from string, to string, label string) {
+		edge := fmt.Sprintf("  %s -> %s [label=%s];", from, to, dotString(label))
+		if seenEdges[edge] {
+			return
+		}
+		seenEdges[edge] = true
+		connected[from], connected[to] = true, true
+		edges = append(edges, edge)
+	}
+
+	var variableNodes []string
+	for i, cv := range chainedValues {
+		varID := fmt.Sprintf("var%d", i+1)
+		attrs := "shape=ellipse"
+		if cv.ExternalSource {
+			attrs += ", style=dashed"
+		}
+		variableNodes = append(variableNodes, fmt.Sprintf("  %s [%s, label=%s];", varID, attrs, dotString(cv.VariableName)))
+
+		if source := cv.ValueSource; source != nil && callIDs[source.Source] != "" {
+			addEdge(callIDs[source.Source], varID, dotReferenceLabel(source))
+		}
+		for _, usage := range cv.AllUsages {
+			if usage.SourceType == SourceTypeRequest && callIDs[usage.Source] != "" {
+				addEdge(varID, callIDs[usage.Source], dotReferenceLabel(usage))
+			}
+		}
+	}
+
+	lines := []string{
+		"digraph chain {",
+		"  rankdir=LR;",
+		"  node [fontname=\"Helvetica\"];",
+		"  edge [fontname=\"Helvetica\", fontsize=10];",
+	}
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		callID := callIDs[callDetails]
+		name := callDetails.Name
+		if name == "" {
+			name = callDetails.Entry.Request.Method + " " + callDetails.Entry.Request.URL
+		}
+		attrs := "shape=box"
+		if !connected[callID] {
+			// Orphan calls neither produce nor use a chained value.
+			attrs += ", style=\"filled,dashed\", fillcolor=\"#ffe0e0\", color=\"#cc0000\""
+		}
+		lines = append(lines, fmt.Sprintf("  %s [%s, label=%s];", callID, attrs, dotString(fmt.Sprintf("%02d %s", i+1, name))))
+	}
+	lines = append(lines, variableNodes...)
+	lines = append(lines, edges...)
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// dotReferenceLabel labels an edge with the reference path of a usage and the encoding it appears under.
+func dotReferenceLabel(ref *ValueReference) string {
+	label := ref.ReferencePath
+	if ref.JWTParent != nil {
+		label = ref.JWTParent.ReferencePath + " → " + ref.JWTClaimPath
+	}
+	if ref.Transform != "" {
+		label += " (" + ref.Transform + ")"
+	}
+	return label
+}
+
+// dotString renders s as a double-quoted DOT string.
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
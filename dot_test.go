@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestBuildDOTGraph(t *testing.T) {
+	har := chainTestHar()
+	har.Log.Entries = append(har.Log.Entries, jsonEntry("GET", "https://api.example.com/v1/health", "", "", `{"status": "up"}`))
+	callDetailsList, chainedValues := analyzeTestHar(t, har)
+	want := `digraph chain {
+  rankdir=LR;
+  node [fontname="Helvetica"];
+  edge [fontname="Helvetica", fontsize=10];
+  call1 [shape=box, label="01 POST /v1/login"];
+  call2 [shape=box, label="02 POST /v1/orders"];
+  call3 [shape=box, label="03 GET /v1/orders/{orderId}"];
+  call4 [shape=box, style="filled,dashed", fillcolor="#ffe0e0", color="#cc0000", label="04 GET /v1/health"];
+  var1 [shape=ellipse, label="dataToken"];
+  var2 [shape=ellipse, label="orderId"];
+  call1 -> var1 [label="data.token"];
+  var1 -> call2 [label="Authorization"];
+  var1 -> call3 [label="Authorization"];
+  call2 -> var2 [label="order.id"];
+  var2 -> call3 [label="path[3]"];
+}
+`
+	if got := BuildDOTGraph(callDetailsList, chainedValues); got != want {
+		t.Errorf("BuildDOTGraph =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDOTString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"01 GET /v1/items", `"01 GET /v1/items"`},
+		{`say "hi" \ bye`, `"say \"hi\" \\ bye"`},
+		{"two\nlines", `"two\nlines"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := dotString(tt.in); got != tt.want {
+				t.Errorf("dotString(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
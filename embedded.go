@@ -0,0 +1,188 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Guards for embedded matching. Short or repetitive values (e.g. "1000", "true") would be found inside
+// almost any string, so candidates must be long enough, varied enough, and either contain a digit
+// or be long enough to be a token rather than a word.
+const (
+	minEmbeddedLength     = 5
+	minEmbeddedEntropy    = 2.0
+	minEmbeddedWordLength = 12
+)
+
+// embeddedCandidate is a form in which a response value may appear in a request: either the value itself
+// (empty transform) or its encoding under one of the valueTransforms.
+type embeddedCandidate struct {
+	needle    string
+	value     string
+	transform string
+}
+
+// embeddedMatch is an occurrence of a candidate inside a larger string.
+type embeddedMatch struct {
+	embeddedCandidate
+	offset int
+}
+
+// embeddedMatcher finds previously seen response values in later request values, either encoded
+// (URL-encoded, base64, JSON-escaped) or embedded in a larger string, such as an id inside a URL,
+// a composite key like "ORD-12345-A" or a text field.
+type embeddedMatcher struct {
+	candidates []embeddedCandidate
+	encoded    map[string]embeddedCandidate
+	seen       map[string]bool
+}
+
+func newEmbeddedMatcher() *embeddedMatcher {
+	return &embeddedMatcher{
+		encoded: make(map[string]embeddedCandidate),
+		seen:    make(map[string]bool),
+	}
+}
+
+// add registers a response value and its encodings. Encoded forms always participate in exact matching;
+// embedded matching additionally requires the value to pass the length and entropy guards.
+func (m *embeddedMatcher) add(value string) {
+	if m.seen[value] {
+		return
+	}
+	m.seen[value] = true
+
+	embeddable := isEmbeddableValue(value)
+	if embeddable {
+		m.candidates = append(m.candidates, embeddedCandidate{needle: value, value: value})
+	}
+	for _, t := range valueTransforms {
+		encoded := t.Encode(value)
+		if encoded == value {
+			continue
+		}
+		c := embeddedCandidate{needle: encoded, value: value, transform: t.Name()}
+		if _, exists := m.encoded[encoded]; !exists {
+			m.encoded[encoded] = c
+		}
+		if embeddable {
+			m.candidates = append(m.candidates, c)
+		}
+	}
+}
+
+// exactEncoded reports whether s is exactly the encoded form of a registered value.
+func (m *embeddedMatcher) exactEncoded(s string) (embeddedCandidate, bool) {
+	c, ok := m.encoded[s]
+	return c, ok
+}
+
+// exactQueryDecoded reports whether s, a percent-encoded query value, decodes to a registered value. It
+// covers encodings that differ from encodeURIComponent, such as "+" for spaces, which exactEncoded misses.
+func (m *embeddedMatcher) exactQueryDecoded(s string) (embeddedCandidate, bool) {
+	decoded, err := url.QueryUnescape(s)
+	if err != nil || decoded == s || !m.seen[decoded] {
+		return embeddedCandidate{}, false
+	}
+	return embeddedCandidate{needle: s, value: decoded, transform: urlEncodeTransform{}.Name()}, true
+}
+
+// find returns the candidates embedded in s at delimiter boundaries. Where matches overlap, the longest wins.
+// Each candidate is reported once, at its first boundary-aligned occurrence. Exact matches are not reported.
+func (m *embeddedMatcher) find(s string) []embeddedMatch {
+	var matches []embeddedMatch
+	for _, c := range m.candidates {
+		if len(c.needle) >= len(s) {
+			continue
+		}
+		if offset := indexAtBoundary(s, c.needle); offset >= 0 {
+			matches = append(matches, embeddedMatch{embeddedCandidate: c, offset: offset})
+		}
+	}
+	if len(matches) < 2 {
+		return matches
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return len(matches[i].needle) > len(matches[j].needle)
+	})
+	var accepted []embeddedMatch
+	for _, candidate := range matches {
+		overlaps := false
+		for _, a := range accepted {
+			if candidate.offset < a.offset+len(a.needle) && a.offset < candidate.offset+len(candidate.needle) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			accepted = append(accepted, candidate)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].offset < accepted[j].offset
+	})
+	return accepted
+}
+
+// indexAtBoundary returns the offset of the first occurrence of sub in s that is not part of a longer
+// alphanumeric run, or -1.
+func indexAtBoundary(s string, sub string) int {
+	for start := 0; start <= len(s)-len(sub); {
+		idx := strings.Index(s[start:], sub)
+		if idx < 0 {
+			return -1
+		}
+		idx += start
+		end := idx + len(sub)
+		if isDelimiterBefore(s, idx) && isDelimiterAfter(s, end) {
+			return idx
+		}
+		start = idx + 1
+	}
+	return -1
+}
+
+func isDelimiterBefore(s string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:idx])
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func isDelimiterAfter(s string, idx int) bool {
+	if idx >= len(s) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(s[idx:])
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// isEmbeddableValue applies the minimum-length and entropy guards to a candidate value.
+func isEmbeddableValue(s string) bool {
+	if len(s) < minEmbeddedLength || shannonEntropy(s) < minEmbeddedEntropy {
+		return false
+	}
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0 || len(s) >= minEmbeddedWordLength
+}
+
+// shannonEntropy returns the entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValueTransform is an encoding under which a response value may reappear in a later request,
+// such as URL encoding in a query string or base64 in a header. Matching encodes candidate values with
+// every registered transform, and the generated scripts apply the same transform before substitution.
+type ValueTransform interface {
+	// Name identifies the transform in ValueReference.Transform.
+	Name() string
+
+	// VariableSuffix is appended to the variable name of the chained value to name the encoded variant.
+	VariableSuffix() string
+
+	// Encode applies the transform to a value.
+	Encode(value string) string
+
+	// JSExpression returns JavaScript that applies the transform to the expression expr.
+	JSExpression(expr string) string
+}
+
+// valueTransforms lists the transforms tried when matching. Add new encodings here.
+var valueTransforms = []ValueTransform{
+	urlEncodeTransform{},
+	base64Transform{},
+	base64URLTransform{},
+	jsonEscapeTransform{},
+}
+
+// lookupTransform returns the registered transform with the given name, or nil.
+func lookupTransform(name string) ValueTransform {
+	for _, t := range valueTransforms {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// transformedVariableName returns the name of the variable holding the encoded form of a chained value.
+func transformedVariableName(ref *ValueReference) string {
+	name := ref.Context.VariableName
+	if t := lookupTransform(ref.Transform); t != nil {
+		name += t.VariableSuffix()
+	}
+	return name
+}
+
+// usedTransforms returns the transforms applied to a chained value by its request usages, in first-use order.
+func usedTransforms(cv *ChainedValueContext) []ValueTransform {
+	var result []ValueTransform
+	seen := make(map[string]bool)
+	for _, usage := range cv.AllUsages {
+		if usage.SourceType != SourceTypeRequest || usage.Transform == "" || seen[usage.Transform] {
+			continue
+		}
+		if t := lookupTransform(usage.Transform); t != nil {
+			seen[usage.Transform] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// urlEncodeTransform matches values percent-encoded as by JavaScript's encodeURIComponent.
+type urlEncodeTransform struct{}
+
+func (urlEncodeTransform) Name() string           { return "urlencoded" }
+func (urlEncodeTransform) VariableSuffix() string { return "UrlEncoded" }
+func (urlEncodeTransform) Encode(value string) string {
+	const unreserved = "-_.!~*'()"
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte(unreserved, c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+func (urlEncodeTransform) JSExpression(expr string) string {
+	return fmt.Sprintf("encodeURIComponent(%s)", expr)
+}
+
+// base64Transform matches values encoded with standard, padded base64.
+type base64Transform struct{}
+
+func (base64Transform) Name() string           { return "base64" }
+func (base64Transform) VariableSuffix() string { return "Base64" }
+func (base64Transform) Encode(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+func (base64Transform) JSExpression(expr string) string {
+	return fmt.Sprintf("btoa(%s)", expr)
+}
+
+// base64URLTransform matches values encoded with unpadded, URL-safe base64.
+type base64URLTransform struct{}
+
+func (base64URLTransform) Name() string           { return "base64url" }
+func (base64URLTransform) VariableSuffix() string { return "Base64Url" }
+func (base64URLTransform) Encode(value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+func (base64URLTransform) JSExpression(expr string) string {
+	return fmt.Sprintf("btoa(%s).replace(/\\+/g, '-').replace(/\\//g, '_').replace(/=+$/, '')", expr)
+}
+
+// jsonEscapeTransform matches values escaped for inclusion in a JSON string, as found in stringified bodies.
+type jsonEscapeTransform struct{}
+
+func (jsonEscapeTransform) Name() string           { return "json_escaped" }
+func (jsonEscapeTransform) VariableSuffix() string { return "JsonEscaped" }
+func (jsonEscapeTransform) Encode(value string) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(value)
+	quoted := strings.TrimSuffix(sb.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+func (jsonEscapeTransform) JSExpression(expr string) string {
+	return fmt.Sprintf("JSON.stringify(String(%s)).slice(1, -1)", expr)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValueTransforms(t *testing.T) {
+	tests := []struct {
+		transform string
+		value     string
+		want      string
+	}{
+		{"urlencoded", "a b/c&d=1 x99", "a%20b%2Fc%26d%3D1%20x99"},
+		{"urlencoded", "-_.!~*'()", "-_.!~*'()"},
+		{"urlencoded", "é+", "%C3%A9%2B"},
+		{"base64", "user:pa55", "dXNlcjpwYTU1"},
+		{"base64", "ab?", "YWI/"},
+		{"base64url", "ab?", "YWI_"},
+		{"base64url", "a", "YQ"},
+		{"json_escaped", `say "hi"\n`, `say \"hi\"\\n`},
+		{"json_escaped", "<tab>\t", `<tab>\t`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transform+" "+tt.value, func(t *testing.T) {
+			transform := lookupTransform(tt.transform)
+			if transform == nil {
+				t.Fatalf("transform %q is not registered", tt.transform)
+			}
+			if got := transform.Encode(tt.value); got != tt.want {
+				t.Errorf("Encode(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodedQueryValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"encodeURIComponent", "q=a%20b%2Fc%26d%3D1%20x99&page=2", "q={{redirectUrlEncoded}}&page=2"},
+		{"plus for spaces", "page=2&q=a+b%2Fc%26d%3D1+x99", "page=2&q={{redirectUrlEncoded}}"},
+		{"lowercase escapes", "q=a%20b%2fc%26d%3d1%20x99", "q={{redirectUrlEncoded}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			har := HAR{Log: Log{Entries: []Entry{
+				jsonEntry("GET", "https://api.example.com/next", "", "", `{"redirect": "a b/c&d=1 x99"}`),
+				jsonEntry("GET", "https://api.example.com/search?"+tt.query, "", "", `{}`),
+			}}}
+			calls, values := analyzeTestHar(t, har)
+			if len(values) != 1 {
+				t.Fatalf("found %d chained values, want 1", len(values))
+			}
+			usages := calls[1].RequestChainedValues
+			if len(usages) != 1 || usages[0].Transform != "urlencoded" {
+				t.Fatalf("request usages = %+v, want one urlencoded usage", usages)
+			}
+			got := replaceChainedValues(har.Log.Entries[1].Request.URL, usages, postmanPlaceholder)
+			if want := "https://api.example.com/search?" + tt.want; got != want {
+				t.Errorf("substituted URL = %s, want %s", got, want)
+			}
+			if transforms := usedTransforms(values[0]); len(transforms) != 1 || transforms[0].Name() != "urlencoded" {
+				t.Errorf("used transforms = %v, want urlencoded", transforms)
+			}
+		})
+	}
+}
+
+func TestEncodedFormValues(t *testing.T) {
+	har := HAR{Log: Log{Entries: []Entry{
+		jsonEntry("GET", "https://api.example.com/next", "", "", `{"redirect": "a b/c&d=1 x99"}`),
+		jsonEntry("POST", "https://api.example.com/search", "application/x-www-form-urlencoded", "q=a%20b%2Fc%26d%3D1%20x99&page=2", `{}`),
+	}}}
+	calls, _ := analyzeTestHar(t, har)
+	body := ReplaceChainedValuesInRequest(calls[1]).Body
+	if body.Mode != "urlencoded" || len(body.Urlencoded) != 2 {
+		t.Fatalf("Postman body = %+v, want two urlencoded fields", body)
+	}
+	if got := body.Urlencoded[0].Value; got != "{{redirect}}" {
+		t.Errorf("Postman form field q = %s, want {{redirect}}", got)
+	}
+}
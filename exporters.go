@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exporter writes the processed calls and their chained values in one output format, such as a Postman
+// collection. The analysis is the same for every format; exporters only differ in how they express the
+// extraction of response values and their substitution into later requests.
+type Exporter interface {
+	// Name is the value selecting the exporter with the -format flag.
+	Name() string
+
+	// Description is shown in the usage message.
+	Description() string
+
+	// DefaultOutput is the output path used when -output is not given.
+	DefaultOutput() string
+
+	// Export writes the output to outputPath.
+	Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error
+}
+
+// droppedValuesExporter is implemented by exporters that also show the candidate values that were not chained.
+type droppedValuesExporter interface {
+	ExportWithDropped(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue, outputPath string) error
+}
+
+// exportWith writes the output of exporter, passing the dropped values to exporters that show them.
+func exportWith(exporter Exporter, callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue, outputPath string) error {
+	if e, ok := exporter.(droppedValuesExporter); ok {
+		return e.ExportWithDropped(callDetailsList, chainedValues, dropped, outputPath)
+	}
+	return exporter.Export(callDetailsList, chainedValues, outputPath)
+}
+
+// exporters lists the available output formats. The first one is the default.
+var exporters = []Exporter{
+	postmanExporter{},
+	insomniaExporter{},
+	brunoExporter{},
+	k6Exporter{},
+	jmeterExporter{},
+	goTestExporter{},
+	curlExporter{},
+	pythonExporter{},
+	httpFileExporter{},
+	hurlExporter{},
+	openAPIExporter{},
+	mermaidExporter{},
+	dotExporter{},
+	reportExporter{},
+}
+
+// clientManagedHeaders are request headers that HTTP clients such as net/http and curl set themselves, so
+// generated code does not copy them from the recording. A recorded Accept-Encoding would in particular
+// make the server compress responses that the generated code then fails to decode.
+var clientManagedHeaders = map[string]bool{
+	"Accept-Encoding": true,
+	"Connection":      true,
+	"Content-Length":  true,
+	"Host":            true,
+}
+
+// lookupExporter returns the exporter with the given name, or nil.
+func lookupExporter(name string) Exporter {
+	for _, e := range exporters {
+		if e.Name() == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// exporterUsage describes the available formats for the -format flag.
+func exporterUsage() string {
+	var formats []string
+	for _, e := range exporters {
+		formats = append(formats, fmt.Sprintf("%s (%s)", e.Name(), e.Description()))
+	}
+	return "Output format: " + strings.Join(formats, ", ")
+}
+
+// referenceJSONPath returns the JSONPath of a response body value, for formats that extract values with JSONPath.
+func referenceJSONPath(ref *ValueReference) (string, error) {
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		return "", err
+	}
+	return expr.JSONPath(), nil
+}
+
+// postmanExporter writes a Postman v2.1 collection that extracts chained values with test scripts.
+type postmanExporter struct{}
+
+func (postmanExporter) Name() string          { return "postman" }
+func (postmanExporter) Description() string   { return "Postman v2.1 collection" }
+func (postmanExporter) DefaultOutput() string { return "collection.json" }
+func (postmanExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	collection := BuildPostmanCollection(callDetailsList, chainedValues)
+	if err := WriteCollectionToFile(collection, outputPath); err != nil {
+		return fmt.Errorf("error writing Postman collection: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+// trickyText has the characters that end or expand a string in one of the output languages.
+const trickyText = "a'b\"c $HOME `id` \\ ${x}\n\tabc123"
+
+func TestLookupExporter(t *testing.T) {
+	names := make(map[string]bool)
+	for _, exporter := range exporters {
+		if names[exporter.Name()] {
+			t.Errorf("two exporters are named %s", exporter.Name())
+		}
+		names[exporter.Name()] = true
+		if got := lookupExporter(exporter.Name()); got != exporter {
+			t.Errorf("lookupExporter(%q) = %v, want %v", exporter.Name(), got, exporter)
+		}
+	}
+	if got := lookupExporter("word"); got != nil {
+		t.Errorf("lookupExporter(\"word\") = %v, want nil", got)
+	}
+}
+
+// chainTestHar returns a small chained flow: a login returns a token that authorizes the next two calls,
+// and the order created by the second call is read by the third through its path.
+func chainTestHar() HAR {
+	const token = "tok8f3a9c2e71"
+	login := jsonEntry("POST", "https://api.example.com/v1/login", "application/json", `{"user": "alice"}`, `{"data": {"token": "`+token+`"}}`)
+	order := jsonEntry("POST", "https://api.example.com/v1/orders", "application/json", `{"sku": "sku-1"}`, `{"order": {"id": "ord55123"}}`)
+	order.Request.Headers = []Header{{Name: "Authorization", Value: "Bearer " + token}}
+	details := jsonEntry("GET", "https://api.example.com/v1/orders/ord55123", "", "", `{}`)
+	details.Request.Headers = []Header{{Name: "Authorization", Value: "Bearer " + token}}
+	return HAR{Log: Log{Entries: []Entry{login, order, details}}}
+}
+
+// captureLog returns the log output written during the test, where exporters report what they cannot express.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
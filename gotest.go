@@ -0,0 +1,513 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goTestPackage is the package clause of generated Go tests. Empty means the name of the output directory.
+var goTestPackage string
+
+// configureGoTest sets the package name of generated Go tests.
+func configureGoTest(pkg string) error {
+	if pkg != "" && !isGoIdentifier(pkg) {
+		return fmt.Errorf("invalid Go package name %q", pkg)
+	}
+	goTestPackage = pkg
+	return nil
+}
+
+// goPackageUnsafeChars matches the characters dropped from a directory name to form a package name.
+var goPackageUnsafeChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// goTestExporter writes a Go integration test that replays the calls with net/http. Each call is a subtest;
+// chained values are extracted from the decoded responses into a map of variables and expanded into later
+// requests through {{name}} placeholders.
+type goTestExporter struct{}
+
+func (goTestExporter) Name() string          { return "gotest" }
+func (goTestExporter) Description() string   { return "Go integration test using net/http" }
+func (goTestExporter) DefaultOutput() string { return "flow_test.go" }
+func (goTestExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	pkg := goTestPackage
+	if pkg == "" {
+		pkg = goPackageForPath(outputPath)
+	}
+	source, err := BuildGoTest(callDetailsList, chainedValues, pkg)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, source, 0644); err != nil {
+		return fmt.Errorf("error writing Go test: %w", err)
+	}
+	return nil
+}
+
+// goPackageForPath derives a package name from the directory the test is written to, falling back to "flow".
+func goPackageForPath(outputPath string) string {
+	abs, err := filepath.Abs(outputPath)
+	if err != nil {
+		return "flow"
+	}
+	name := strings.ToLower(filepath.Base(filepath.Dir(abs)))
+	name = goPackageUnsafeChars.ReplaceAllString(name, "")
+	if !isGoIdentifier(name) {
+		return "flow"
+	}
+	return name
+}
+
+// isGoIdentifier reports whether s can be used as a package name.
+func isGoIdentifier(s string) bool {
+	if s == "" || token.IsKeyword(s) || unicode.IsDigit(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildGoTest renders the calls as a gofmt-formatted Go test file.
+func BuildGoTest(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, pkg string) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString("// Code generated by chainer from a HAR recording. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&sb, "package %s\n\n", pkg)
+	sb.WriteString(goTestImports)
+	sb.WriteString("\n// TestRecordedFlow replays the recorded calls in order, threading the chained values from responses into later requests.\n")
+	sb.WriteString("func TestRecordedFlow(t *testing.T) {\n")
+	sb.WriteString("jar, _ := cookiejar.New(nil)\n")
+	sb.WriteString("client := &http.Client{Jar: jar, Timeout: 30 * time.Second}\n")
+	sb.WriteString("vars := map[string]string{\n")
+	for _, cv := range chainedValues {
+		if !cv.ExternalSource {
+			continue
+		}
+		if cv.InitScript != "" {
+			log.Printf("Go test: the initializer of %s is not run; set the %s environment variable instead", cv.VariableName, cv.VariableName)
+		}
+		fmt.Fprintf(&sb, "%s: envOr(%s, %s),\n", strconv.Quote(cv.VariableName), strconv.Quote(cv.VariableName), goString(cv.Value))
+	}
+	sb.WriteString("}\n")
+
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		writeGoTestStep(&sb, i+1, callDetails)
+	}
+	sb.WriteString("}\n")
+	sb.WriteString(goTestHelpers)
+
+	source, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated Go test does not parse: %w", err)
+	}
+	return source, nil
+}
+
+// writeGoTestStep renders one call as a subtest. The test stops at the first failing step,
+// since later steps depend on the values it extracts.
+func writeGoTestStep(sb *strings.Builder, step int, callDetails *CallDetails) {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	name := callDetails.Name
+	if name == "" {
+		name = request.Method + " " + request.URL
+	}
+	fmt.Fprintf(sb, "\nif !t.Run(%s, func(t *testing.T) {\n", strconv.Quote(fmt.Sprintf("%02d %s", step, name)))
+
+	body := `""`
+	if request.PostData != nil && request.PostData.Text != "" {
+		if request.PostData.MimeType == "application/x-www-form-urlencoded" {
+			// Chained values appear decoded in form fields, so the fields are substituted and encoded again.
+			var fields []string
+			for _, pair := range decodeFormBody(request.PostData.Text) {
+				fields = append(fields, fmt.Sprintf("[2]string{%s, %s}", strconv.Quote(pair[0]), goExpand(ReplaceValuesInString(pair[1], refs))))
+			}
+			body = "form(" + strings.Join(fields, ", ") + ")"
+		} else {
+			body = goExpand(ReplaceValuesInString(request.PostData.Text, refs))
+		}
+	}
+	sb.WriteString("headers := [][2]string{\n")
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") || clientManagedHeaders[http.CanonicalHeaderKey(header.Name)] {
+			continue
+		}
+		fmt.Fprintf(sb, "{%s, %s},\n", strconv.Quote(header.Name), goExpand(ReplaceValuesInString(header.Value, refs)))
+	}
+	sb.WriteString("}\n")
+	fmt.Fprintf(sb, "resp, body := doRequest(t, client, %s, %s, %s, headers)\n",
+		strconv.Quote(request.Method), goExpand(ReplaceValuesInString(request.URL, refs)), body)
+	if status := callDetails.Entry.Response.Status; status != 0 {
+		fmt.Fprintf(sb, "if resp.StatusCode != %d {\nt.Fatalf(\"expected status %d, got %%d: %%s\", resp.StatusCode, body)\n}\n", status, status)
+	} else {
+		sb.WriteString("_ = resp\n")
+	}
+
+	extracted := make(map[string]bool)
+	for _, ref := range callDetails.ResponseChainedValues {
+		if ref.SourceType != SourceTypeResponse || ref.Context == nil || extracted[ref.Context.VariableName] {
+			continue
+		}
+		cv := ref.Context
+		extracted[cv.VariableName] = true
+		expr, err := goExtraction(ref)
+		if err != nil {
+			log.Printf("Go test: %s keeps its recorded value because %s cannot be read: %v", cv.VariableName, ref.ReferencePath, err)
+			expr = goString(cv.Value)
+		}
+		fmt.Fprintf(sb, "vars[%s] = %s\n", strconv.Quote(cv.VariableName), expr)
+		for _, t := range usedTransforms(cv) {
+			fmt.Fprintf(sb, "vars[%s] = encode(%s, vars[%s])\n", strconv.Quote(cv.VariableName+t.VariableSuffix()), strconv.Quote(t.Name()), strconv.Quote(cv.VariableName))
+		}
+	}
+	if len(extracted) == 0 {
+		sb.WriteString("_ = body\n")
+	}
+	sb.WriteString("}) {\nreturn\n}\n")
+}
+
+// goExtraction returns the Go expression extracting a response value in a generated step,
+// where resp and body hold the response and its body.
+func goExtraction(ref *ValueReference) (string, error) {
+	switch {
+	case ref.JWTParent != nil:
+		token, err := goExtraction(ref.JWTParent)
+		if err != nil {
+			return "", err
+		}
+		expr, err := jwtClaimExpr(ref.JWTClaimPath)
+		if err != nil {
+			return "", err
+		}
+		steps, err := goPathSteps(expr)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("lookup(t, jwtPart(t, %s, %d)%s)", token, jwtPartIndex(ref.JWTClaimPath), steps), nil
+	case ref.SourceLocation == SourceLocationCookie:
+		return fmt.Sprintf("cookie(t, resp, %s)", strconv.Quote(ref.CookieName)), nil
+	case ref.SourceLocation == SourceLocationHeader:
+		if strings.EqualFold(ref.HeaderName, "Authorization") {
+			return fmt.Sprintf("strings.TrimPrefix(resp.Header.Get(%s), \"Bearer \")", strconv.Quote(ref.HeaderName)), nil
+		}
+		return fmt.Sprintf("resp.Header.Get(%s)", strconv.Quote(ref.HeaderName)), nil
+	}
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		return "", err
+	}
+	steps, err := goPathSteps(expr)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("lookup(t, decodeJSON(t, body)%s)", steps), nil
+}
+
+// goPathSteps renders the steps of an expression as arguments of the generated lookup helper: strings for
+// keys, ints for indices and where{...} for filters. Only equality filters on direct fields are supported.
+func goPathSteps(expr *ReferenceExpr) (string, error) {
+	if expr.Query && expr.QueryIndex != 0 {
+		return "", fmt.Errorf("only the first query result can be selected, not [%d]", expr.QueryIndex)
+	}
+	var sb strings.Builder
+	for _, st := range expr.Steps {
+		switch st.kind {
+		case stepKey:
+			sb.WriteString(", " + strconv.Quote(st.key))
+		case stepIndex:
+			fmt.Fprintf(&sb, ", %d", st.index)
+		case stepFilter:
+			var fields []string
+			for _, cond := range st.filter {
+				if cond.op != "==" || len(cond.path) != 1 {
+					return "", fmt.Errorf("filter conditions must compare a direct field for equality")
+				}
+				fields = append(fields, fmt.Sprintf("%s: %s", strconv.Quote(cond.path[0]), goLiteral(cond.value)))
+			}
+			sb.WriteString(", where{" + strings.Join(fields, ", ") + "}")
+		default:
+			return "", fmt.Errorf("wildcards and recursive descent are not supported")
+		}
+	}
+	return sb.String(), nil
+}
+
+// goLiteral renders a decoded JSON scalar as a Go literal of the type encoding/json decodes it to.
+func goLiteral(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val)
+	case float64:
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(val, 'f', -1, 64))
+	case nil:
+		return "nil"
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// goExpand renders s as a Go string expression, expanding the variables of the generated test if it has placeholders.
+func goExpand(s string) string {
+	if !strings.Contains(s, "{{") {
+		return goString(s)
+	}
+	return fmt.Sprintf("expand(%s, vars)", goString(s))
+}
+
+// goString renders s as a Go string literal, preferring a raw string for text with quotes or backslashes,
+// such as JSON bodies.
+func goString(s string) string {
+	if strings.ContainsAny(s, "\"\\") && !strings.ContainsAny(s, "`\r") && strconv.CanBackquote(strings.ReplaceAll(s, "\n", "")) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+const goTestImports = `import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+`
+
+// goTestHelpers are the helper functions included in every generated test, so that it has no dependencies.
+const goTestHelpers = `
+// where selects the first array element whose fields equal the given values.
+type where map[string]any
+
+var placeholder = regexp.MustCompile(` + "`" + `\{\{([^{}]+)\}\}` + "`" + `)
+
+// expand replaces {{name}} placeholders with the values of the variables.
+func expand(s string, vars map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := vars[m[2:len(m)-2]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// envOr returns the value of the environment variable, or def if it is unset.
+func envOr(name string, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+// doRequest sends a request through the client and returns the response with its body read.
+func doRequest(t *testing.T, client *http.Client, method string, rawURL string, body string, headers [][2]string) (*http.Response, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	for _, h := range headers {
+		req.Header.Add(h[0], h[1])
+	}
+	// Chained cookies are sent in the recorded Cookie header; the jar must not send them a second time, but
+	// still sends the cookies that the header does not set.
+	c := client
+	if client.Jar != nil && len(req.Cookies()) > 0 {
+		for _, cookie := range cookiesNotIn(client.Jar.Cookies(req.URL), req.Cookies()) {
+			req.AddCookie(cookie)
+		}
+		noJar := *client
+		noJar.Jar = nil
+		c = &noJar
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, rawURL, err)
+	}
+	if c != client {
+		client.Jar.SetCookies(req.URL, resp.Cookies())
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return resp, data
+}
+
+// form encodes form fields in the given order.
+func form(fields ...[2]string) string {
+	pairs := make([]string, len(fields))
+	for i, f := range fields {
+		pairs[i] = url.QueryEscape(f[0]) + "=" + url.QueryEscape(f[1])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// cookiesNotIn returns the cookies whose names are not among the given cookies.
+func cookiesNotIn(cookies []*http.Cookie, set []*http.Cookie) []*http.Cookie {
+	var missing []*http.Cookie
+	for _, cookie := range cookies {
+		found := false
+		for _, s := range set {
+			if s.Name == cookie.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, cookie)
+		}
+	}
+	return missing
+}
+
+func decodeJSON(t *testing.T, body []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return v
+}
+
+// lookup walks path from node (string keys, int indices and where filters) and returns the value found as a string.
+func lookup(t *testing.T, node any, path ...any) string {
+	t.Helper()
+	for _, step := range path {
+		switch s := step.(type) {
+		case string:
+			m, ok := node.(map[string]any)
+			if !ok {
+				t.Fatalf("expected an object for key %q", s)
+			}
+			if node, ok = m[s]; !ok {
+				t.Fatalf("key %q not found", s)
+			}
+		case int:
+			a, ok := node.([]any)
+			if !ok || s >= len(a) {
+				t.Fatalf("index %d not found", s)
+			}
+			node = a[s]
+		case where:
+			a, ok := node.([]any)
+			if !ok {
+				t.Fatalf("expected an array for %v", s)
+			}
+			found := false
+			for _, el := range a {
+				if m, ok := el.(map[string]any); ok && matches(m, s) {
+					node, found = el, true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("no element matches %v", s)
+			}
+		}
+	}
+	switch v := node.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil, bool:
+		return fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(node)
+	return string(data)
+}
+
+func matches(m map[string]any, w where) bool {
+	for k, v := range w {
+		if m[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func cookie(t *testing.T, resp *http.Response, name string) string {
+	t.Helper()
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	t.Fatalf("cookie %q not set", name)
+	return ""
+}
+
+// jwtPart decodes the header (0) or payload (1) of a JWT.
+func jwtPart(t *testing.T, token string, index int) any {
+	t.Helper()
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) < 2 {
+		t.Fatalf("not a JWT: %q", token)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[index], "="))
+	if err != nil {
+		t.Fatalf("decoding JWT: %v", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("decoding JWT: %v", err)
+	}
+	return v
+}
+
+// encode applies the encoding under which a value was recorded in a later request.
+func encode(transform string, v string) string {
+	switch transform {
+	case "urlencoded":
+		var sb strings.Builder
+		for _, c := range []byte(v) {
+			if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.!~*'()", c) >= 0 {
+				sb.WriteByte(c)
+			} else {
+				fmt.Fprintf(&sb, "%%%02X", c)
+			}
+		}
+		return sb.String()
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(v))
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString([]byte(v))
+	case "json_escaped":
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(v)
+		quoted := strings.TrimSpace(buf.String())
+		return quoted[1 : len(quoted)-1]
+	}
+	return v
+}
+`
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGoStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"string", goString(trickyText), `"a'b\"c $HOME ` + "`id`" + ` \\ ${x}\n\tabc123"`},
+		{"raw string", goString(`{"a": "b\\n"}`), "`{\"a\": \"b\\\\n\"}`"},
+		{"expand", goExpand("x {{token}}"), `expand("x {{token}}", vars)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+	for _, s := range []string{trickyText, `{"a": "b\\n"}`, "line\r\nbreak", "\x00\ue000\u2028", ""} {
+		if got, err := strconv.Unquote(goString(s)); err != nil || got != s {
+			t.Errorf("goString(%q) = %s, which Go reads as %q (%v)", s, goString(s), got, err)
+		}
+	}
+}
+
+func TestBuildGoTest(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	source, err := BuildGoTest(callDetailsList, chainedValues, "flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package flow",
+		`vars["dataToken"] = lookup(t, decodeJSON(t, body), "data", "token")`,
+		`{"Authorization", expand("Bearer {{dataToken}}", vars)},`,
+		`vars["orderId"] = lookup(t, decodeJSON(t, body), "order", "id")`,
+		`expand("https://api.example.com/v1/orders/{{orderId}}", vars)`,
+	} {
+		if !strings.Contains(string(source), want) {
+			t.Errorf("missing %q in:\n%s", want, source)
+		}
+	}
+}
+
+func TestBuildGoTestUnreadablePath(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	callDetailsList[1].ResponseChainedValues[0].ReferencePath = "order["
+	logged := captureLog(t)
+
+	source, err := BuildGoTest(callDetailsList, chainedValues, "flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `vars["orderId"] = "ord55123"`; !strings.Contains(string(source), want) {
+		t.Errorf("missing the recorded value %q in:\n%s", want, source)
+	}
+	if !strings.Contains(logged.String(), "Go test: orderId keeps its recorded value") {
+		t.Errorf("fallback to the recorded value was not logged: %q", logged.String())
+	}
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
-	"os"
 	"path"
+	"sort"
 	"strings"
+	"time"
 )
 
 // HAR represents the root structure of a HAR (HTTP Archive) file.
@@ -27,6 +29,10 @@ type Log struct {
 // Entry represents a single HTTP transaction as recorded in a HAR file.
 // It includes both the HTTP request and response details.
 type Entry struct {
+	// StartedDateTime is the time the request was started, in ISO 8601 format.
+	StartedDateTime string `json:"startedDateTime,omitempty"`
+	// Time is the total duration of the request in milliseconds.
+	Time float64 `json:"time,omitempty"`
 	// Request contains the details of the HTTP request.
 	Request Request `json:"request"`
 	// Response contains the details of the HTTP response.
@@ -44,6 +50,8 @@ type Request struct {
 	PostData *PostData `json:"postData,omitempty"`
 	// Headers is a list of HTTP headers sent with the request.
 	Headers []Header `json:"headers,omitempty"` // Optional: To handle headers if needed
+	// Cookies is the list of cookies sent with the request.
+	Cookies []Cookie `json:"cookies,omitempty"`
 	// Additional fields can be added as needed.
 }
 
@@ -90,6 +98,8 @@ type Response struct {
 	RedirectURL string `json:"redirectURL,omitempty"`
 	// Headers is a list of HTTP headers included in the response.
 	Headers []Header `json:"headers,omitempty"` // Optional: To handle headers if needed
+	// Cookies is the list of cookies set by the response.
+	Cookies []Cookie `json:"cookies,omitempty"`
 	// Additional fields can be added as needed.
 }
 
@@ -124,7 +134,9 @@ func flatten(prefix string, ancestors []interface{}, data interface{}) []*ValueR
 	case map[string]interface{}:
 		// Append a copy of the current map to the ancestors.
 		newAncestors := append(append([]interface{}{}, ancestors...), v)
-		for key, value := range v {
+		// Walk keys in sorted order so that the output is deterministic.
+		for _, key := range sortedKeys(v) {
+			value := v[key]
 			fullKey := key
 			if prefix != "" {
 				fullKey = prefix + "." + key
@@ -154,6 +166,16 @@ func flatten(prefix string, ancestors []interface{}, data interface{}) []*ValueR
 	return valueRefs
 }
 
+// urlPathSegments splits a URL path into the segments that path[i] reference paths index. The path is
+// cleaned first, so that repeated slashes and dot segments do not shift the indices.
+func urlPathSegments(p string) []string {
+	cleaned := path.Clean(p)
+	if cleaned == "." {
+		cleaned = ""
+	}
+	return strings.Split(cleaned, "/")
+}
+
 // ExtractURLStrings parses a raw URL string to extract components such as host, path segments,
 // and query parameter values. Each component is converted into a ValueReference with an appropriate reference path.
 func ExtractURLStrings(rawURL string) ([]*ValueReference, error) {
@@ -172,8 +194,7 @@ func ExtractURLStrings(rawURL string) ([]*ValueReference, error) {
 	valueRefs = append(valueRefs, &valueRef)
 
 	// Extract path segments
-	cleanPath := path.Clean(parsedURL.Path)
-	segments := strings.Split(cleanPath, "/")
+	segments := urlPathSegments(parsedURL.Path)
 	for i, segment := range segments {
 		if segment != "" {
 			valueRef := ValueReference{
@@ -186,23 +207,46 @@ func ExtractURLStrings(rawURL string) ([]*ValueReference, error) {
 		}
 	}
 
-	// Extract query parameter values
+	// Extract query parameter values. Values are kept as recorded, percent-encoded, so that they can be
+	// substituted in the raw URL; findChainedValues matches them against decoded response values.
 	queryIndex := len(segments) // Offset for query parameters
-	for key, values := range parsedURL.Query() {
-		for j, value := range values {
-			valueRef := ValueReference{
-				Value:         value,
-				ReferencePath: fmt.Sprintf("query.%s[%d]", key, j),
-				UrlLocation:   queryIndex,
-			}
-			valueRefs = append(valueRefs, &valueRef)
-			queryIndex++
+	counts := make(map[string]int)
+	for _, pair := range rawQueryPairs(parsedURL.RawQuery) {
+		key := pair[0]
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
 		}
+		valueRef := ValueReference{
+			Value:         pair[1],
+			ReferencePath: fmt.Sprintf("query.%s[%d]", key, counts[key]),
+			UrlLocation:   queryIndex,
+		}
+		valueRefs = append(valueRefs, &valueRef)
+		counts[key]++
+		queryIndex++
 	}
 
 	return valueRefs, nil
 }
 
+// rawQueryPairs splits a raw query string into its key/value pairs in order, without decoding them.
+func rawQueryPairs(rawQuery string) [][2]string {
+	var pairs [][2]string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs
+}
+
+// isQueryReference reports whether a request value is a query parameter, recorded percent-encoded.
+func isQueryReference(ref *ValueReference) bool {
+	return ref.SourceLocation == SourceLocationUrl && strings.HasPrefix(ref.ReferencePath, "query.")
+}
+
 // processBody processes the body of an HTTP request or response.
 // It assumes the body is in JSON format (or form data) and flattens it into ValueReference instances.
 func processBody(body string, contentType string) ([]*ValueReference, error) {
@@ -228,7 +272,8 @@ func processBody(body string, contentType string) ([]*ValueReference, error) {
 		}
 
 		var valueRefs []*ValueReference
-		for key, values := range formValues {
+		for _, key := range sortedKeys(formValues) {
+			values := formValues[key]
 			for i, value := range values {
 				valueRef := ValueReference{
 					Value:          value,
@@ -261,6 +306,10 @@ func processHeaders(headers []Header) []*ValueReference {
 		if _, found := blacklist[strings.ToLower(header.Name)]; found {
 			continue
 		}
+		// Cookies are split into individual values by processCookies.
+		if isCookieHeader(header.Name) {
+			continue
+		}
 
 		headerRef := ValueReference{
 			Value:          header.Value,
@@ -309,6 +358,7 @@ func processHar(har HAR) []*CallDetails {
 		}
 		reqHeaderDetails := processHeaders(entry.Request.Headers)
 		reqDetails = append(reqDetails, reqHeaderDetails...)
+		reqDetails = append(reqDetails, processCookies(entry.Request.Headers, entry.Request.Cookies, SourceTypeRequest)...)
 		for j := range reqDetails {
 			reqDetails[j].Source = &callDetails
 			reqDetails[j].SourceType = SourceTypeRequest
@@ -324,6 +374,8 @@ func processHar(har HAR) []*CallDetails {
 		}
 		respHeaderDetails := processHeaders(entry.Response.Headers)
 		respDetails = append(respDetails, respHeaderDetails...)
+		respDetails = append(respDetails, processCookies(entry.Response.Headers, entry.Response.Cookies, SourceTypeResponse)...)
+		respDetails = append(respDetails, expandJWTs(respDetails)...)
 		for j := range respDetails {
 			respDetails[j].Source = &callDetails
 			respDetails[j].SourceType = SourceTypeResponse
@@ -350,19 +402,92 @@ func processHar(har HAR) []*CallDetails {
 	return callDetailsList
 }
 
+// decodeFormBody splits a URL-encoded form body into decoded key/value pairs, keeping their order.
+// Pairs that cannot be decoded are kept as recorded.
+func decodeFormBody(body string) [][2]string {
+	var pairs [][2]string
+	for _, pair := range strings.Split(body, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs
+}
+
+// minThinkTime is the shortest recorded pause between calls that generated load tests reproduce.
+// Shorter gaps are the browser's own overhead rather than user think time.
+const minThinkTime = 100 * time.Millisecond
+
+// thinkTime returns the pause between the end of one recorded call and the start of the next,
+// or zero if the HAR file lacks timings or the calls overlap.
+func thinkTime(entry *Entry, next *Entry) time.Duration {
+	start, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+	if err != nil {
+		return 0
+	}
+	nextStart, err := time.Parse(time.RFC3339Nano, next.StartedDateTime)
+	if err != nil {
+		return 0
+	}
+	end := start.Add(time.Duration(entry.Time * float64(time.Millisecond)))
+	if pause := nextStart.Sub(end); pause > 0 {
+		return pause.Round(time.Millisecond)
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // readHar reads the HAR file from the specified path.
 // It unmarshals the JSON content into a HAR struct and returns any errors encountered.
 func readHar(harFilePath string) (HAR, error) {
-	// Read the HAR file
-	harData, err := os.ReadFile(harFilePath)
+	// Read the HAR file, from standard input for "-"
+	harData, err := readInput(harFilePath)
 	if err != nil {
-		log.Fatalf("Error reading HAR file: %v", err)
+		return HAR{}, err
 	}
+	return parseHar(harData, harFilePath)
+}
 
-	// Unmarshal the HAR data
+// parseHar unmarshals HAR data read from harFilePath. Input without entries is reported as errNotHAR.
+func parseHar(harData []byte, harFilePath string) (HAR, error) {
 	var har HAR
 	if err := json.Unmarshal(harData, &har); err != nil {
-		log.Fatalf("Error parsing HAR file: %v", err)
+		return HAR{}, fmt.Errorf("%s is not valid JSON: %w", harFilePath, err)
+	}
+	if len(har.Log.Entries) == 0 {
+		if isAnalysisFile(harData) {
+			return HAR{}, fmt.Errorf("%s: %w (it is an analysis file; read it with render, explain or validate)", harFilePath, errNotHAR)
+		}
+		return HAR{}, fmt.Errorf("%s: %w", harFilePath, errNotHAR)
 	}
-	return har, err
+	return har, nil
 }
+
+// isAnalysisFile reports whether data is an analysis file written by the analyze command rather than a HAR file.
+func isAnalysisFile(data []byte) bool {
+	var probe struct {
+		Version int             `json:"version"`
+		Log     json.RawMessage `json:"log"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Version != 0 && probe.Log == nil
+}
+
+// errNotHAR is returned by readHar for JSON input without HAR entries. The commands report it as a usage error.
+var errNotHAR = errors.New("not a HAR file: log.entries is missing or empty")
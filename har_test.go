@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadHar(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantCalls int
+		notHAR    bool
+		wantErr   bool
+	}{
+		{"HAR", `{"log": {"entries": [{"request": {"method": "GET", "url": "https://example.com/"}, "response": {"status": 200}}]}}`, 1, false, false},
+		{"empty object", `{}`, 0, true, true},
+		{"no entries", `{"log": {"entries": []}}`, 0, true, true},
+		{"analysis file", `{"version": 1, "calls": [{"name": "GET /", "include": true, "entry": {}}], "values": []}`, 0, true, true},
+		{"invalid JSON", `{"log":`, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "input.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			har, err := readHar(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readHar error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errNotHAR) != tt.notHAR {
+				t.Errorf("readHar error = %v, want errNotHAR %v", err, tt.notHAR)
+			}
+			if len(har.Log.Entries) != tt.wantCalls {
+				t.Errorf("readHar returned %d entries, want %d", len(har.Log.Entries), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsAnalysisFile(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{`{"version": 1, "calls": [], "values": []}`, true},
+		{`{"version": 2, "calls": [{"name": "GET /"}]}`, true},
+		{`{"log": {"version": "1.2", "entries": []}}`, false},
+		{`{"version": 1, "log": {"entries": []}}`, false},
+		{`{"calls": []}`, false},
+		{`[1, 2]`, false},
+		{`{"version":`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			if got := isAnalysisFile([]byte(tt.content)); got != tt.want {
+				t.Errorf("isAnalysisFile(%s) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
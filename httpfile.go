@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// httpFileEnvironment is the environment of http-client.env.json holding the pre-defined variables.
+const httpFileEnvironment = "default"
+
+// httpFileDialect is the response handler API of the JetBrains HTTP Client. response.body is already parsed
+// for JSON responses, and there is no cookie accessor, so cookies are parsed from the Set-Cookie headers.
+var httpFileDialect = scriptDialect{
+	parseBody: "var responseJson = response.body;",
+	header: func(name string) string {
+		return fmt.Sprintf("response.headers.valueOf(%q)", name)
+	},
+	cookie: func(name string) string {
+		return fmt.Sprintf("getResponseCookie(%q)", name)
+	},
+	cookieHelper: []string{
+		"function getResponseCookie(name) {",
+		"  var cookies = response.headers.valuesOf(\"Set-Cookie\");",
+		"  for (var i = 0; i < cookies.length; i++) {",
+		"    var pair = cookies[i].split(\";\")[0];",
+		"    var eq = pair.indexOf(\"=\");",
+		"    if (pair.slice(0, eq).trim() === name) {",
+		"      return pair.slice(eq + 1).trim();",
+		"    }",
+		"  }",
+		"  return undefined;",
+		"}",
+	},
+	setVar: func(name string, expr string) string {
+		return fmt.Sprintf("client.global.set(%q, %s);", name, expr)
+	},
+	native: true,
+}
+
+// httpFileExporter writes an .http file for the HTTP clients of JetBrains IDEs and compatible editor
+// extensions. Requests are separated by ### lines, chained values are stored with client.global.set in
+// response handlers and used as {{name}} placeholders, and pre-defined variables go into an
+// http-client.env.json file next to it.
+type httpFileExporter struct{}
+
+func (httpFileExporter) Name() string          { return "http" }
+func (httpFileExporter) Description() string   { return "HTTP client .http file" }
+func (httpFileExporter) DefaultOutput() string { return "requests.http" }
+func (httpFileExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	if err := os.WriteFile(outputPath, []byte(BuildHTTPFile(callDetailsList, chainedValues)), 0644); err != nil {
+		return fmt.Errorf("error writing .http file: %w", err)
+	}
+	env := BuildHTTPClientEnv(chainedValues)
+	if env == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling http-client.env.json: %w", err)
+	}
+	envPath := filepath.Join(filepath.Dir(outputPath), "http-client.env.json")
+	if err := os.WriteFile(envPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing http-client.env.json: %w", err)
+	}
+	return nil
+}
+
+// BuildHTTPFile renders the calls as an .http file.
+func BuildHTTPFile(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) string {
+	var blocks []string
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		var initScript []string
+		if i == 0 {
+			initScript = httpFileInitScript(chainedValues)
+		}
+		blocks = append(blocks, buildHTTPFileRequest(callDetails, initScript))
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// BuildHTTPClientEnv returns the contents of http-client.env.json with the pre-defined variables,
+// or nil if there are none.
+func BuildHTTPClientEnv(chainedValues []*ChainedValueContext) map[string]map[string]string {
+	vars := make(map[string]string)
+	for _, cv := range chainedValues {
+		if cv.ExternalSource {
+			vars[cv.VariableName] = cv.Value
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	return map[string]map[string]string{httpFileEnvironment: vars}
+}
+
+// buildHTTPFileRequest renders one request block: the ### separator naming it, an optional pre-request
+// script, the request line, headers and body, and the response handler extracting its chained values.
+func buildHTTPFileRequest(callDetails *CallDetails, initScript []string) string {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	name := callDetails.Name
+	if name == "" {
+		name = request.Method + " " + request.URL
+	}
+
+	var lines []string
+	lines = append(lines, "### "+strings.ReplaceAll(name, "\n", " "))
+	if len(initScript) > 0 {
+		lines = append(lines, "< {%")
+		lines = append(lines, indentLines(initScript, "  ")...)
+		lines = append(lines, "%}")
+	}
+	lines = append(lines, request.Method+" "+ReplaceValuesInString(request.URL, refs))
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		lines = append(lines, header.Name+": "+ReplaceValuesInString(header.Value, refs))
+	}
+	if request.PostData != nil && request.PostData.Text != "" {
+		body := ReplaceValuesInString(request.PostData.Text, refs)
+		if request.PostData.MimeType == "application/x-www-form-urlencoded" {
+			body = httpFileFormBody(request.PostData.Text, refs)
+		}
+		lines = append(lines, "", strings.TrimRight(body, "\n"))
+	}
+
+	hasResponseValues := false
+	for _, ref := range callDetails.ResponseChainedValues {
+		if ref.SourceType == SourceTypeResponse && ref.Context != nil {
+			hasResponseValues = true
+			break
+		}
+	}
+	if hasResponseValues {
+		lines = append(lines, "", "> {%")
+		lines = append(lines, indentLines(httpFileDialect.responseScriptLines(callDetails.ResponseChainedValues), "  ")...)
+		lines = append(lines, "%}")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// httpFileFormBody re-encodes a URL-encoded form body with the chained values of the decoded fields as
+// placeholders. The client sends placeholders unencoded, so values are expected to need no encoding.
+func httpFileFormBody(body string, refs []*ValueReference) string {
+	var pairs []string
+	for _, pair := range decodeFormBody(body) {
+		var sb strings.Builder
+		sb.WriteString(url.QueryEscape(pair[0]) + "=")
+		for _, segment := range splitChainedValues(pair[1], refs) {
+			if segment.ref == nil {
+				sb.WriteString(url.QueryEscape(segment.literal))
+			} else {
+				sb.WriteString("{{" + transformedVariableName(segment.ref) + "}}")
+			}
+		}
+		pairs = append(pairs, sb.String())
+	}
+	return strings.Join(pairs, "&")
+}
+
+// httpFileInitScript returns the pre-request script computing pre-defined variables that have an initializer.
+func httpFileInitScript(chainedValues []*ChainedValueContext) []string {
+	var scriptLines []string
+	for _, cv := range chainedValues {
+		if cv.InitScript == "" {
+			continue
+		}
+		scriptLines = append(scriptLines,
+			"try {",
+			"  var result = {};",
+			cv.InitScript,
+			"  "+httpFileDialect.setVar(cv.VariableName, "result"),
+			"} catch (e) {",
+			fmt.Sprintf("  console.error('Error initializing variable %s:', e);", cv.VariableName),
+			"}",
+		)
+	}
+	return scriptLines
+}
+
+// indentLines prefixes every non-empty line with indent.
+func indentLines(lines []string, indent string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if line != "" {
+			line = indent + line
+		}
+		result[i] = line
+	}
+	return result
+}
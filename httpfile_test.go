@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildHTTPFile(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	file := BuildHTTPFile(callDetailsList, chainedValues)
+	for _, want := range []string{
+		"var dataToken = responseJson.data.token;",
+		`client.global.set("dataToken", dataToken);`,
+		"POST https://api.example.com/v1/orders\nAuthorization: Bearer {{dataToken}}\n",
+		`client.global.set("orderId", orderId);`,
+		"GET https://api.example.com/v1/orders/{{orderId}}\nAuthorization: Bearer {{dataToken}}\n",
+	} {
+		if !strings.Contains(file, want) {
+			t.Errorf("missing %q in:\n%s", want, file)
+		}
+	}
+	if got := strings.Count(file, "### "); got != 3 {
+		t.Errorf("%d request separators, want 3", got)
+	}
+}
+
+func TestBuildHTTPClientEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		chainedValues []*ChainedValueContext
+		want          map[string]map[string]string
+	}{
+		{"chained values only", []*ChainedValueContext{{VariableName: "orderId", Value: "ord55123"}}, nil},
+		{"pre-defined variable", []*ChainedValueContext{
+			{VariableName: "orderId", Value: "ord55123"},
+			{VariableName: "username", Value: "alice", ExternalSource: true},
+		}, map[string]map[string]string{"default": {"username": "alice"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildHTTPClientEnv(tt.chainedValues); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildHTTPClientEnv = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
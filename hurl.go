@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hurlTransformFilters are the Hurl filters applying a value transform to a capture.
+// Hurl has no filter for JSON string escaping.
+var hurlTransformFilters = map[string]string{
+	"urlencoded": "urlEncode",
+	"base64":     "base64Encode",
+	"base64url":  "base64UrlSafeEncode",
+}
+
+// hurlExporter writes a Hurl file. Each call is an entry asserting the recorded status, response-chained
+// values are captured with jsonpath, header and cookie queries, and later entries use them as {{name}}
+// templates. Pre-defined variables go into a variables file next to it, passed with --variables-file.
+type hurlExporter struct{}
+
+func (hurlExporter) Name() string          { return "hurl" }
+func (hurlExporter) Description() string   { return "Hurl file" }
+func (hurlExporter) DefaultOutput() string { return "flow.hurl" }
+func (hurlExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	varsPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".env"
+	vars := BuildHurlVariables(chainedValues)
+	if vars == "" {
+		varsPath = ""
+	}
+	if err := os.WriteFile(outputPath, []byte(BuildHurlFile(callDetailsList, filepath.Base(outputPath), varsPath)), 0644); err != nil {
+		return fmt.Errorf("error writing Hurl file: %w", err)
+	}
+	if vars != "" {
+		if err := os.WriteFile(varsPath, []byte(vars), 0644); err != nil {
+			return fmt.Errorf("error writing Hurl variables file: %w", err)
+		}
+	}
+	return nil
+}
+
+// BuildHurlVariables returns the Hurl variables file with the pre-defined variables, or "" if there are none.
+func BuildHurlVariables(chainedValues []*ChainedValueContext) string {
+	var sb strings.Builder
+	for _, cv := range chainedValues {
+		if !cv.ExternalSource {
+			continue
+		}
+		if cv.InitScript != "" {
+			log.Printf("Hurl: the initializer of %s is not run; pass it with --variable %s=...", cv.VariableName, cv.VariableName)
+		}
+		fmt.Fprintf(&sb, "%s=%s\n", cv.VariableName, cv.Value)
+	}
+	return sb.String()
+}
+
+// BuildHurlFile renders the calls as Hurl entries, headed by the command running the file fileName with
+// the variables file varsPath, if set.
+func BuildHurlFile(callDetailsList []*CallDetails, fileName string, varsPath string) string {
+	run := "hurl --test"
+	if varsPath != "" {
+		run += " --variables-file " + filepath.Base(varsPath)
+	}
+	entries := []string{"# Replays a recorded HAR flow. Run with: " + run + " " + fileName + "\n"}
+	captured := make(map[string]bool)
+	for _, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		entries = append(entries, buildHurlEntry(callDetails, captured))
+	}
+	return strings.Join(entries, "\n")
+}
+
+// buildHurlEntry renders one call: the request with its headers and body, the status assertion and the captures.
+func buildHurlEntry(callDetails *CallDetails, captured map[string]bool) string {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	name := callDetails.Name
+	if name == "" {
+		name = request.Method + " " + request.URL
+	}
+
+	lines := []string{"# " + strings.ReplaceAll(name, "\n", " ")}
+	lines = append(lines, request.Method+" "+ReplaceValuesInString(request.URL, refs))
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") || clientManagedHeaders[http.CanonicalHeaderKey(header.Name)] {
+			continue
+		}
+		lines = append(lines, header.Name+": "+ReplaceValuesInString(header.Value, refs))
+	}
+	if request.PostData != nil && request.PostData.Text != "" {
+		lines = append(lines, hurlBody(request.PostData, refs)...)
+	}
+
+	status := "*"
+	if callDetails.Entry.Response.Status != 0 {
+		status = fmt.Sprint(callDetails.Entry.Response.Status)
+	}
+	lines = append(lines, "HTTP "+status)
+
+	var captures []string
+	for _, ref := range callDetails.ResponseChainedValues {
+		if ref.SourceType != SourceTypeResponse || ref.Context == nil || captured[ref.Context.VariableName] {
+			continue
+		}
+		cv := ref.Context
+		captured[cv.VariableName] = true
+		query, err := hurlQuery(ref)
+		if err != nil {
+			log.Printf("Hurl: %s is not captured because %s cannot be read: %v", cv.VariableName, ref.ReferencePath, err)
+			continue
+		}
+		captures = append(captures, fmt.Sprintf("%s: %s", cv.VariableName, query))
+		for _, t := range usedTransforms(cv) {
+			filter, ok := hurlTransformFilters[t.Name()]
+			if !ok {
+				log.Printf("Hurl: %s has no %s filter; the raw value is used", cv.VariableName+t.VariableSuffix(), t.Name())
+				filter = ""
+			}
+			captures = append(captures, strings.TrimSpace(fmt.Sprintf("%s: %s %s", cv.VariableName+t.VariableSuffix(), query, filter)))
+		}
+	}
+	if len(captures) > 0 {
+		lines = append(lines, "[Captures]")
+		lines = append(lines, captures...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// hurlBody renders a request body: JSON bodies verbatim, form bodies as a [FormParams] section so that Hurl
+// encodes the substituted values, and other bodies as a string.
+func hurlBody(postData *PostData, refs []*ValueReference) []string {
+	switch {
+	case postData.MimeType == "application/x-www-form-urlencoded":
+		lines := []string{"[FormParams]"}
+		for _, pair := range decodeFormBody(postData.Text) {
+			lines = append(lines, hurlKey(pair[0])+": "+ReplaceValuesInString(pair[1], refs))
+		}
+		return lines
+	case json.Valid([]byte(postData.Text)) && strings.ContainsAny(strings.TrimSpace(postData.Text)[:1], "{["):
+		return []string{ReplaceValuesInString(postData.Text, refs)}
+	}
+	body := ReplaceValuesInString(postData.Text, refs)
+	if !strings.ContainsAny(body, "`\n") {
+		return []string{"`" + body + "`"}
+	}
+	return []string{"```", strings.TrimSuffix(body, "\n"), "```"}
+}
+
+// hurlQuery returns the Hurl query, with filters, that captures a response value. JWT claims are decoded
+// with the base64UrlSafeDecode filter, which needs Hurl 6 or later.
+func hurlQuery(ref *ValueReference) (string, error) {
+	switch {
+	case ref.JWTParent != nil:
+		token, err := hurlQuery(ref.JWTParent)
+		if err != nil {
+			return "", err
+		}
+		expr, err := jwtClaimExpr(ref.JWTClaimPath)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s split \".\" nth %d base64UrlSafeDecode decode \"utf-8\" jsonpath %s%s", token, jwtPartIndex(ref.JWTClaimPath), hurlString(expr.JSONPath()), hurlJSONPathSuffix(expr)), nil
+	case ref.SourceLocation == SourceLocationCookie:
+		return "cookie " + hurlString(ref.CookieName), nil
+	case ref.SourceLocation == SourceLocationHeader:
+		if strings.EqualFold(ref.HeaderName, "Authorization") {
+			return fmt.Sprintf("header %s regex %s", hurlString(ref.HeaderName), hurlString(`^(?:Bearer )?(.*)$`)), nil
+		}
+		return "header " + hurlString(ref.HeaderName), nil
+	}
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		return "", err
+	}
+	return "jsonpath " + hurlString(expr.JSONPath()) + hurlJSONPathSuffix(expr), nil
+}
+
+// hurlJSONPathSuffix returns the filter selecting one match of an expression that may match several nodes,
+// as Hurl's jsonpath then yields a list.
+func hurlJSONPathSuffix(expr *ReferenceExpr) string {
+	if !expr.Query && expr.Definite() {
+		return ""
+	}
+	if expr.Query && expr.QueryIndex < 0 {
+		return ""
+	}
+	idx := expr.QueryIndex
+	if idx < 0 {
+		idx = 0
+	}
+	return fmt.Sprintf(" nth %d", idx)
+}
+
+// hurlKey renders a key of a Hurl section, quoting it if it contains characters that end a key.
+func hurlKey(key string) string {
+	if key == "" || strings.ContainsAny(key, ": \t#\"") {
+		return hurlString(key)
+	}
+	return key
+}
+
+// hurlString renders s as a double-quoted Hurl string.
+func hurlString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHurlStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"string", hurlString(trickyText), `"a'b\"c $HOME ` + "`id`" + ` \\ ${x}\n\tabc123"`},
+		{"key", hurlKey("a:b"), `"a:b"`},
+		{"plain key", hurlKey("plain"), "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildHurlFile(t *testing.T) {
+	callDetailsList, _ := analyzeTestHar(t, chainTestHar())
+	file := BuildHurlFile(callDetailsList, "flow.hurl", "vars.env")
+	for _, want := range []string{
+		"--variables-file vars.env flow.hurl",
+		"[Captures]\ndataToken: jsonpath \"$.data.token\"\n",
+		"POST https://api.example.com/v1/orders\nAuthorization: Bearer {{dataToken}}\n",
+		"[Captures]\norderId: jsonpath \"$.order.id\"\n",
+		"GET https://api.example.com/v1/orders/{{orderId}}\n",
+	} {
+		if !strings.Contains(file, want) {
+			t.Errorf("missing %q in:\n%s", want, file)
+		}
+	}
+}
+
+func TestBuildHurlFileUnreadablePath(t *testing.T) {
+	callDetailsList, _ := analyzeTestHar(t, chainTestHar())
+	callDetailsList[1].ResponseChainedValues[0].ReferencePath = "order["
+	logged := captureLog(t)
+
+	file := BuildHurlFile(callDetailsList, "flow.hurl", "vars.env")
+	if strings.Contains(file, "orderId:") {
+		t.Errorf("the unreadable orderId is captured:\n%s", file)
+	}
+	if !strings.Contains(logged.String(), "Hurl: orderId is not captured") {
+		t.Errorf("the missing capture was not logged: %q", logged.String())
+	}
+}
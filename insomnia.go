@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Insomnia resource ids. Requests are numbered in call order so that response tags can refer to them.
+const (
+	insomniaWorkspaceID   = "wrk_chainer"
+	insomniaEnvironmentID = "env_chainer_base"
+)
+
+// InsomniaExport is the root of an Insomnia v4 export file.
+type InsomniaExport struct {
+	Type         string             `json:"_type"`
+	ExportFormat int                `json:"__export_format"`
+	ExportDate   string             `json:"__export_date"`
+	ExportSource string             `json:"__export_source"`
+	Resources    []InsomniaResource `json:"resources"`
+}
+
+// InsomniaResource is a workspace, environment or request in an Insomnia export.
+// Only the fields relevant to the resource type are set.
+type InsomniaResource struct {
+	ID          string            `json:"_id"`
+	Type        string            `json:"_type"`
+	ParentID    *string           `json:"parentId"`
+	Name        string            `json:"name"`
+	Scope       string            `json:"scope,omitempty"`
+	Data        map[string]string `json:"data,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Body        *InsomniaBody     `json:"body,omitempty"`
+	Headers     []InsomniaPair    `json:"headers,omitempty"`
+	MetaSortKey int               `json:"metaSortKey,omitempty"`
+}
+
+// InsomniaBody is the body of an Insomnia request.
+type InsomniaBody struct {
+	MimeType string         `json:"mimeType,omitempty"`
+	Text     string         `json:"text,omitempty"`
+	Params   []InsomniaPair `json:"params,omitempty"`
+}
+
+// InsomniaPair is a header or form field of an Insomnia request.
+type InsomniaPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// insomniaExporter writes an Insomnia v4 export. Instead of scripts, later requests read chained values
+// from earlier responses with response template tags, e.g. {% response 'body', 'req_chainer_001', '$.id' %}.
+type insomniaExporter struct{}
+
+func (insomniaExporter) Name() string          { return "insomnia" }
+func (insomniaExporter) Description() string   { return "Insomnia v4 export with response tags" }
+func (insomniaExporter) DefaultOutput() string { return "insomnia.json" }
+func (insomniaExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	export := BuildInsomniaExport(callDetailsList, chainedValues)
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing Insomnia export: %w", err)
+	}
+	return nil
+}
+
+// BuildInsomniaExport assembles an Insomnia export with one request per call. Pre-defined variables are
+// placed in the base environment; values chained from responses are read with response tags.
+func BuildInsomniaExport(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) InsomniaExport {
+	workspaceID := insomniaWorkspaceID
+	resources := []InsomniaResource{{
+		ID:    insomniaWorkspaceID,
+		Type:  "workspace",
+		Name:  "Generated Collection",
+		Scope: "collection",
+	}}
+
+	environment := make(map[string]string)
+	for _, cv := range chainedValues {
+		if !cv.ExternalSource {
+			continue
+		}
+		environment[cv.VariableName] = cv.Value
+		if cv.InitScript != "" {
+			log.Printf("Insomnia exports have no scripts; variable %s keeps its recorded value", cv.VariableName)
+		}
+	}
+	resources = append(resources, InsomniaResource{
+		ID:       insomniaEnvironmentID,
+		Type:     "environment",
+		ParentID: &workspaceID,
+		Name:     "Base Environment",
+		Data:     environment,
+	})
+
+	requestIDs := make(map[*CallDetails]string)
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		requestIDs[callDetails] = fmt.Sprintf("req_chainer_%03d", i+1)
+	}
+
+	warned := make(map[string]bool)
+	placeholder := func(ref *ValueReference) string {
+		return insomniaPlaceholder(ref, requestIDs, warned)
+	}
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		request := callDetails.Entry.Request
+		resource := InsomniaResource{
+			ID:          requestIDs[callDetails],
+			Type:        "request",
+			ParentID:    &workspaceID,
+			Name:        callDetails.Name,
+			Method:      request.Method,
+			URL:         replaceChainedValues(request.URL, callDetails.RequestChainedValues, placeholder),
+			MetaSortKey: -(len(callDetailsList) - i),
+		}
+		for _, header := range requestHeaders(callDetails) {
+			if shouldSkipHeader(header) {
+				continue
+			}
+			resource.Headers = append(resource.Headers, InsomniaPair{
+				Name:  header.Name,
+				Value: replaceChainedValues(header.Value, callDetails.RequestChainedValues, placeholder),
+			})
+		}
+		if request.PostData != nil && request.PostData.MimeType == "application/x-www-form-urlencoded" {
+			// Chained values appear decoded in form fields; Insomnia encodes the fields when sending.
+			resource.Body = &InsomniaBody{MimeType: request.PostData.MimeType}
+			for _, pair := range decodeFormBody(request.PostData.Text) {
+				resource.Body.Params = append(resource.Body.Params, InsomniaPair{
+					Name:  pair[0],
+					Value: replaceChainedValues(pair[1], callDetails.RequestChainedValues, placeholder),
+				})
+			}
+		} else if request.PostData != nil {
+			resource.Body = &InsomniaBody{
+				MimeType: request.PostData.MimeType,
+				Text:     replaceChainedValues(request.PostData.Text, callDetails.RequestChainedValues, placeholder),
+			}
+		}
+		resources = append(resources, resource)
+	}
+
+	return InsomniaExport{
+		Type:         "export",
+		ExportFormat: 4,
+		ExportDate:   time.Now().UTC().Format(time.RFC3339),
+		ExportSource: "chainer",
+		Resources:    resources,
+	}
+}
+
+// insomniaPlaceholder returns the template that produces a chained value in a request: an environment
+// variable for pre-defined values, otherwise a response tag reading the value from the earlier response.
+// Values that a response tag cannot express (cookies, JWT claims, encoded forms) keep their recorded value;
+// warned records the variables already reported so that each is logged once.
+func insomniaPlaceholder(ref *ValueReference, requestIDs map[*CallDetails]string, warned map[string]bool) string {
+	cv := ref.Context
+	if cv.ExternalSource {
+		return "{{ _." + cv.VariableName + " }}"
+	}
+	keepRecorded := func(recorded string, reason string) string {
+		if !warned[cv.VariableName+reason] {
+			warned[cv.VariableName+reason] = true
+			log.Printf("Insomnia: %s keeps its recorded value because %s", cv.VariableName, reason)
+		}
+		return recorded
+	}
+	if ref.Transform != "" {
+		recorded := cv.Value
+		if t := lookupTransform(ref.Transform); t != nil {
+			recorded = t.Encode(recorded)
+		}
+		return keepRecorded(recorded, "response tags cannot apply the "+ref.Transform+" encoding")
+	}
+
+	source := cv.ValueSource
+	if source == nil || requestIDs[source.Source] == "" {
+		return cv.Value
+	}
+	requestID := requestIDs[source.Source]
+	switch {
+	case source.JWTParent != nil:
+		return keepRecorded(cv.Value, "response tags cannot decode JWT claims")
+	case source.SourceLocation == SourceLocationCookie:
+		return keepRecorded(cv.Value, "response tags cannot read cookies (use -cookies=jar to rely on Insomnia's cookie jar)")
+	case source.SourceLocation == SourceLocationHeader:
+		return fmt.Sprintf("{%% response 'header', %s, %s %%}", insomniaQuote(requestID), insomniaQuote(source.HeaderName))
+	}
+	path, err := referenceJSONPath(source)
+	if err != nil {
+		return keepRecorded(cv.Value, fmt.Sprintf("path %q cannot be converted: %v", source.ReferencePath, err))
+	}
+	return fmt.Sprintf("{%% response 'body', %s, %s %%}", insomniaQuote(requestID), insomniaQuote(path))
+}
+
+// insomniaQuote quotes a template tag argument.
+func insomniaQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
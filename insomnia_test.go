@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestInsomniaQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"$.data.token", "'$.data.token'"},
+		{`it's a\b`, `'it\'s a\\b'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := insomniaQuote(tt.in); got != tt.want {
+				t.Errorf("insomniaQuote(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildInsomniaExport(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	export := BuildInsomniaExport(callDetailsList, chainedValues)
+
+	requests := make(map[string]InsomniaResource)
+	for _, r := range export.Resources {
+		if r.Type == "request" {
+			requests[r.ID] = r
+		}
+	}
+	token := "Bearer {% response 'body', 'req_chainer_001', '$.data.token' %}"
+	if got := requests["req_chainer_002"].Headers; len(got) != 1 || got[0].Value != token {
+		t.Errorf("second request headers = %v, want Authorization %s", got, token)
+	}
+	wantURL := "https://api.example.com/v1/orders/{% response 'body', 'req_chainer_002', '$.order.id' %}"
+	if got := requests["req_chainer_003"].URL; got != wantURL {
+		t.Errorf("third request URL = %s, want %s", got, wantURL)
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// variableNamePattern matches the variable names that every output format can use as is.
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// runExplain prints how each chained value of a HAR file or an analysis file is extracted and used.
+func runExplain(ctx context.Context, cmd *command, args []string) error {
+	var f flags
+	fs := cmd.flagSet(&f)
+	f.registerHarFlags(fs)
+	fs.Lookup("file").Usage = "Path to the HAR file or the analysis file to explain, or - to read it from standard input"
+	if err := cmd.parse(fs, args, &f, f.checkHarFlags); err != nil {
+		return err
+	}
+
+	// The input is read once, so that either kind of file can come from standard input.
+	data, err := readInput(f.harFilePath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", f.harFilePath, err)
+	}
+	var callDetailsList []*CallDetails
+	var chainedValues []*ChainedValueContext
+	var dropped []*DroppedValue
+	if isAnalysisFile(data) {
+		analysis, err := parseAnalysis(data, f.harFilePath)
+		if err != nil {
+			return err
+		}
+		callDetailsList, chainedValues, dropped, err = restoreAnalysis(analysis, f.harFilePath)
+		if err != nil {
+			return err
+		}
+	} else {
+		har, err := parseHar(data, f.harFilePath)
+		if err != nil {
+			return fmt.Errorf("error reading HAR file: %w", err)
+		}
+		callDetailsList, chainedValues, dropped, err = analyzeEntries(ctx, f, har)
+		if err != nil {
+			return err
+		}
+	}
+	explainChain(os.Stdout, callDetailsList, chainedValues, dropped)
+	return nil
+}
+
+// explainChain writes a description of each chained value: its value, where it is extracted from and every use.
+func explainChain(w io.Writer, callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue) {
+	labels := callLabels(callDetailsList)
+	fmt.Fprintf(w, "%d calls, %d chained values.\n", len(labels), len(chainedValues))
+	for _, cv := range chainedValues {
+		fmt.Fprintln(w)
+		source := cv.ValueSource
+		switch {
+		case cv.ExternalSource:
+			fmt.Fprintf(w, "%s (pre-defined)\n", cv.VariableName)
+		case source != nil:
+			fmt.Fprintf(w, "%s (from %s)\n", cv.VariableName, labels[source.Source])
+		default:
+			fmt.Fprintln(w, cv.VariableName)
+		}
+		fmt.Fprintf(w, "  value:     %s\n", shorten(cv.Value, 60))
+		if source != nil {
+			fmt.Fprintf(w, "  extracted: %s\n", describeReference(source))
+			if cv.OriginalPath != "" && cv.OriginalPath != source.ReferencePath {
+				fmt.Fprintf(w, "             (refined from %s)\n", cv.OriginalPath)
+			}
+		}
+		prefix := "  used by:   "
+		for _, usage := range cv.AllUsages {
+			if usage.SourceType != SourceTypeRequest {
+				continue
+			}
+			fmt.Fprintf(w, "%s%s, %s\n", prefix, labels[usage.Source], describeReference(usage))
+			prefix = "             "
+		}
+	}
+	if len(dropped) > 0 {
+		fmt.Fprintf(w, "\n%d candidate values were not chained:\n", len(dropped))
+		for _, d := range dropped {
+			fmt.Fprintf(w, "  %s: %s\n", shorten(d.Value, 40), d.Reason)
+		}
+	}
+}
+
+// callLabels numbers the calls and labels them with their names, as in the reports.
+func callLabels(callDetailsList []*CallDetails) map[*CallDetails]string {
+	labels := make(map[*CallDetails]string)
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		name := callDetails.Name
+		if name == "" {
+			name = callDetails.Entry.Request.Method + " " + callDetails.Entry.Request.URL
+		}
+		labels[callDetails] = fmt.Sprintf("%02d %s", i+1, name)
+	}
+	return labels
+}
+
+// describeReference describes where a value is found, e.g. "response JSON body order.id".
+func describeReference(ref *ValueReference) string {
+	if ref.JWTParent != nil {
+		return "JWT claim " + ref.JWTClaimPath + " of the " + describeReference(ref.JWTParent)
+	}
+	side := "response"
+	if ref.SourceType == SourceTypeRequest {
+		side = "request"
+	}
+	var location string
+	switch ref.SourceLocation {
+	case SourceLocationHeader:
+		location = "header " + ref.HeaderName
+	case SourceLocationCookie:
+		location = "cookie " + ref.CookieName
+	case SourceLocationBodyJson:
+		location = "JSON body " + ref.ReferencePath
+	case SourceLocationBodyForm:
+		location = "form body " + ref.ReferencePath
+	default:
+		location = "URL " + ref.ReferencePath
+	}
+	description := side + " " + location
+	var notes []string
+	if ref.MatchLength > 0 {
+		notes = append(notes, fmt.Sprintf("embedded at offset %d", ref.MatchOffset))
+	}
+	if ref.Transform != "" {
+		notes = append(notes, ref.Transform)
+	}
+	if len(notes) > 0 {
+		description += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return description
+}
+
+// shorten truncates s to at most n runes, marking the truncation with an ellipsis.
+func shorten(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// runValidate checks an analysis file, typically after editing it by hand, and fails if it has errors.
+func runValidate(_ context.Context, cmd *command, args []string) error {
+	var f flags
+	fs := cmd.flagSet(&f)
+	fs.StringVar(&f.analysisPath, "file", "", "Path to the analysis file, or - to read it from standard input")
+	if err := cmd.parse(fs, args, &f, f.checkAnalysisFlag); err != nil {
+		return err
+	}
+
+	analysis, err := readAnalysis(f.analysisPath)
+	if err != nil {
+		return err
+	}
+	errorCount := 0
+	for _, problem := range validateAnalysis(analysis) {
+		fmt.Println(problem.severity + ": " + problem.message)
+		if problem.severity == severityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("%s has %d errors", f.analysisPath, errorCount)
+	}
+	fmt.Printf("%s is valid.\n", f.analysisPath)
+	return nil
+}
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// validationProblem is an inconsistency found in an analysis file.
+type validationProblem struct {
+	severity string
+	message  string
+}
+
+// validateAnalysis checks that the included values of an analysis are named uniquely, that their usages refer
+// to existing calls, that each is returned before it is used and that the source paths of JSON bodies still
+// yield the value in the recorded response.
+func validateAnalysis(a *Analysis) []validationProblem {
+	var problems []validationProblem
+	report := func(severity string, format string, args ...any) {
+		problems = append(problems, validationProblem{severity: severity, message: fmt.Sprintf(format, args...)})
+	}
+
+	calls := make([]*CallDetails, len(a.Calls))
+	for i, call := range a.Calls {
+		if call.Entry == nil {
+			report(severityError, "call %d has no entry", i)
+			continue
+		}
+		calls[i] = &CallDetails{Name: call.Name, Entry: call.Entry}
+	}
+	labels := callLabels(calls)
+
+	names := make(map[string]int)
+	for _, value := range a.Values {
+		if !value.Include {
+			continue
+		}
+		if value.Name == "" {
+			report(severityError, "value %q has no name", shorten(value.Value, 40))
+			continue
+		}
+		names[value.Name]++
+		if names[value.Name] == 2 {
+			report(severityError, "variable name %s is used by more than one value", value.Name)
+		}
+		if !variableNamePattern.MatchString(value.Name) {
+			report(severityWarning, "variable name %s is not an identifier, which scripts such as the curl and pytest formats need", value.Name)
+		}
+
+		var source *ValueReference
+		var firstUse *ValueReference
+		valid := true
+		for _, usage := range value.Usages {
+			ref, err := usage.restore(calls)
+			if err != nil {
+				report(severityError, "%s: %v", value.Name, err)
+				valid = false
+				continue
+			}
+			if ref.Source == nil || !a.Calls[usage.Call].Include {
+				continue
+			}
+			if ref.SourceType == SourceTypeResponse && source == nil {
+				source = ref
+			}
+			if ref.SourceType == SourceTypeRequest && firstUse == nil {
+				firstUse = ref
+			}
+		}
+		if !valid {
+			continue
+		}
+		switch {
+		case firstUse == nil:
+			report(severityWarning, "%s is not used by any included call and is left out", value.Name)
+		case value.External:
+			// Pre-defined values are not returned by any call.
+		case source == nil:
+			report(severityWarning, "%s is not returned by any included call and is left out", value.Name)
+		case callIndex(firstUse, calls) < callIndex(source, calls):
+			report(severityError, "%s is used by %s before %s returns it", value.Name, labels[firstUse.Source], labels[source.Source])
+		case source.SourceLocation == SourceLocationBodyJson && source.JWTParent == nil:
+			if _, err := validateRefinedPath(source.Source.Entry.Response.Content.Text, source.ReferencePath, value.Value); err != nil {
+				report(severityError, "%s: path %s does not yield the value in the response of %s: %v", value.Name, source.ReferencePath, labels[source.Source], err)
+			}
+		}
+	}
+	return problems
+}
+
+// callIndex returns the index of the call of a restored usage.
+func callIndex(ref *ValueReference, calls []*CallDetails) int {
+	for i, call := range calls {
+		if call == ref.Source {
+			return i
+		}
+	}
+	return -1
+}
+
+// runDiff compares two analysis files, for instance a fresh analysis with a hand-edited one.
+func runDiff(_ context.Context, cmd *command, args []string) error {
+	var f flags
+	fs := cmd.flagSet(&f)
+	if err := cmd.parse(fs, args, &f); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return &usageError{command: cmd.name, err: fmt.Errorf("expected 2 analysis files, got %d", fs.NArg())}
+	}
+
+	oldAnalysis, err := readAnalysis(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newAnalysis, err := readAnalysis(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	differences := diffAnalyses(oldAnalysis, newAnalysis)
+	if len(differences) == 0 {
+		fmt.Println("No differences.")
+	}
+	for _, line := range differences {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// diffAnalyses lists the differences between two analyses: calls are compared by position, values by their
+// literal value. Added items are prefixed with +, removed ones with - and changed ones with ~.
+func diffAnalyses(oldAnalysis, newAnalysis *Analysis) []string {
+	var lines []string
+	for i := 0; i < len(oldAnalysis.Calls) || i < len(newAnalysis.Calls); i++ {
+		switch {
+		case i >= len(newAnalysis.Calls):
+			lines = append(lines, fmt.Sprintf("- call %02d %s", i+1, analysisCallKey(oldAnalysis.Calls[i])))
+		case i >= len(oldAnalysis.Calls):
+			lines = append(lines, fmt.Sprintf("+ call %02d %s", i+1, analysisCallKey(newAnalysis.Calls[i])))
+		default:
+			oldCall, newCall := oldAnalysis.Calls[i], newAnalysis.Calls[i]
+			if oldKey, newKey := analysisCallKey(oldCall), analysisCallKey(newCall); oldKey != newKey {
+				lines = append(lines, fmt.Sprintf("~ call %02d %s is now %s", i+1, oldKey, newKey))
+			}
+			if oldCall.Name != newCall.Name {
+				lines = append(lines, fmt.Sprintf("~ call %02d renamed from %q to %q", i+1, oldCall.Name, newCall.Name))
+			}
+			if oldCall.Include != newCall.Include {
+				lines = append(lines, fmt.Sprintf("~ call %02d %s", i+1, includedWord(newCall.Include)))
+			}
+		}
+	}
+
+	newValues := make(map[string]*AnalysisValue)
+	for i := range newAnalysis.Values {
+		if _, ok := newValues[newAnalysis.Values[i].Value]; !ok {
+			newValues[newAnalysis.Values[i].Value] = &newAnalysis.Values[i]
+		}
+	}
+	seen := make(map[string]bool)
+	for _, oldValue := range oldAnalysis.Values {
+		if seen[oldValue.Value] {
+			continue
+		}
+		seen[oldValue.Value] = true
+		newValue, ok := newValues[oldValue.Value]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("- value %s (%s)", oldValue.Name, shorten(oldValue.Value, 40)))
+			continue
+		}
+		if oldValue.Name != newValue.Name {
+			lines = append(lines, fmt.Sprintf("~ value %s renamed to %s", oldValue.Name, newValue.Name))
+		}
+		if oldValue.Include != newValue.Include {
+			lines = append(lines, fmt.Sprintf("~ value %s %s", newValue.Name, includedWord(newValue.Include)))
+		}
+		if oldPath, newPath := analysisSourcePath(oldValue), analysisSourcePath(*newValue); oldPath != newPath {
+			lines = append(lines, fmt.Sprintf("~ value %s source path %s is now %s", newValue.Name, oldPath, newPath))
+		}
+		if oldUses, newUses := analysisUseCount(oldValue), analysisUseCount(*newValue); oldUses != newUses {
+			lines = append(lines, fmt.Sprintf("~ value %s used %d times instead of %d", newValue.Name, newUses, oldUses))
+		}
+	}
+	for _, newValue := range newAnalysis.Values {
+		if !seen[newValue.Value] {
+			seen[newValue.Value] = true
+			lines = append(lines, fmt.Sprintf("+ value %s (%s)", newValue.Name, shorten(newValue.Value, 40)))
+		}
+	}
+	return lines
+}
+
+func analysisCallKey(call AnalysisCall) string {
+	if call.Entry == nil {
+		return "(no entry)"
+	}
+	return call.Entry.Request.Method + " " + call.Entry.Request.URL
+}
+
+func includedWord(include bool) string {
+	if include {
+		return "included"
+	}
+	return "excluded"
+}
+
+// analysisSourcePath returns the path of the first response usage of a value, or "" for pre-defined values.
+func analysisSourcePath(value AnalysisValue) string {
+	for _, usage := range value.Usages {
+		if usage.Type == sourceTypeNames[SourceTypeResponse] {
+			return usage.Path
+		}
+	}
+	return ""
+}
+
+func analysisUseCount(value AnalysisValue) int {
+	count := 0
+	for _, usage := range value.Usages {
+		if usage.Type == sourceTypeNames[SourceTypeRequest] {
+			count++
+		}
+	}
+	return count
+}
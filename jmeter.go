@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// jmxElement is an XML element of a JMeter test plan. JMX files pair every test element with a following
+// hashTree holding its children, which this small tree renders more simply than encoding/xml structs.
+type jmxElement struct {
+	tag      string
+	attrs    [][2]string
+	text     string
+	children []*jmxElement
+}
+
+func newJMXElement(tag string, attrs ...string) *jmxElement {
+	el := &jmxElement{tag: tag}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		el.attrs = append(el.attrs, [2]string{attrs[i], attrs[i+1]})
+	}
+	return el
+}
+
+// add appends child elements and returns el.
+func (el *jmxElement) add(children ...*jmxElement) *jmxElement {
+	el.children = append(el.children, children...)
+	return el
+}
+
+// addTestElement appends a test element followed by the hashTree holding its children.
+func (el *jmxElement) addTestElement(element *jmxElement, children ...*jmxElement) *jmxElement {
+	return el.add(element, newJMXElement("hashTree").add(children...))
+}
+
+func (el *jmxElement) render(sb *strings.Builder, indent string) {
+	sb.WriteString(indent + "<" + el.tag)
+	for _, attr := range el.attrs {
+		fmt.Fprintf(sb, " %s=\"%s\"", attr[0], xmlEscape(attr[1]))
+	}
+	switch {
+	case len(el.children) > 0:
+		sb.WriteString(">\n")
+		for _, child := range el.children {
+			child.render(sb, indent+"  ")
+		}
+		sb.WriteString(indent + "</" + el.tag + ">\n")
+	case el.text != "":
+		sb.WriteString(">" + xmlEscape(el.text) + "</" + el.tag + ">\n")
+	default:
+		sb.WriteString("/>\n")
+	}
+}
+
+// xmlEscape escapes text and attribute values the way JMeter saves them, keeping newlines in scripts readable.
+var xmlEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace
+
+func stringProp(name string, value string) *jmxElement {
+	el := newJMXElement("stringProp", "name", name)
+	el.text = value
+	return el
+}
+
+func boolProp(name string, value bool) *jmxElement {
+	el := newJMXElement("boolProp", "name", name)
+	el.text = fmt.Sprint(value)
+	return el
+}
+
+// jmeterExporter writes a JMeter test plan: one HTTP sampler per call inside a single-user thread group,
+// JSON extractors (regular expression extractors for headers and cookies) for response-chained values,
+// and User Defined Variables for pre-defined variables.
+type jmeterExporter struct{}
+
+func (jmeterExporter) Name() string          { return "jmeter" }
+func (jmeterExporter) Description() string   { return "JMeter .jmx test plan" }
+func (jmeterExporter) DefaultOutput() string { return "test-plan.jmx" }
+func (jmeterExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	plan := BuildJMeterTestPlan(callDetailsList, chainedValues)
+	if err := os.WriteFile(outputPath, []byte(plan), 0644); err != nil {
+		return fmt.Errorf("error writing JMeter test plan: %w", err)
+	}
+	return nil
+}
+
+// BuildJMeterTestPlan renders the calls as a JMeter test plan.
+func BuildJMeterTestPlan(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) string {
+	testPlan := newJMXElement("TestPlan", "guiclass", "TestPlanGui", "testclass", "TestPlan", "testname", "Generated Test Plan").add(
+		boolProp("TestPlan.functional_mode", false),
+		boolProp("TestPlan.serialize_threadgroups", false),
+		newJMXElement("elementProp", "name", "TestPlan.user_defined_variables", "elementType", "Arguments").add(
+			newJMXElement("collectionProp", "name", "Arguments.arguments"),
+		),
+	)
+
+	var planChildren []*jmxElement
+	if args := jmeterUserDefinedVariables(chainedValues); args != nil {
+		planChildren = append(planChildren, args, newJMXElement("hashTree"))
+	}
+	planChildren = append(planChildren,
+		newJMXElement("CookieManager", "guiclass", "CookiePanel", "testclass", "CookieManager", "testname", "HTTP Cookie Manager").add(
+			newJMXElement("collectionProp", "name", "CookieManager.cookies"),
+			boolProp("CookieManager.clearEachIteration", true),
+		),
+		newJMXElement("hashTree"),
+	)
+
+	threadGroup := newJMXElement("ThreadGroup", "guiclass", "ThreadGroupGui", "testclass", "ThreadGroup", "testname", "Recorded Flow").add(
+		stringProp("ThreadGroup.on_sample_error", "continue"),
+		newJMXElement("elementProp", "name", "ThreadGroup.main_controller", "elementType", "LoopController", "guiclass", "LoopControlPanel", "testclass", "LoopController").add(
+			boolProp("LoopController.continue_forever", false),
+			stringProp("LoopController.loops", "1"),
+		),
+		stringProp("ThreadGroup.num_threads", "1"),
+		stringProp("ThreadGroup.ramp_time", "1"),
+	)
+	var samplers []*jmxElement
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		var previous *Entry
+		if i > 0 && callDetailsList[i-1] != nil {
+			previous = callDetailsList[i-1].Entry
+		}
+		sampler, children := jmeterSampler(callDetails, previous)
+		samplers = append(samplers, sampler, newJMXElement("hashTree").add(children...))
+	}
+	planChildren = append(planChildren, threadGroup, newJMXElement("hashTree").add(samplers...))
+
+	root := newJMXElement("jmeterTestPlan", "version", "1.2", "properties", "5.0", "jmeter", "5.6.3").add(
+		newJMXElement("hashTree").addTestElement(testPlan, planChildren...),
+	)
+	var sb strings.Builder
+	sb.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	root.render(&sb, "")
+	return sb.String()
+}
+
+// jmeterUserDefinedVariables returns the User Defined Variables element holding the pre-defined variables, or nil.
+func jmeterUserDefinedVariables(chainedValues []*ChainedValueContext) *jmxElement {
+	arguments := newJMXElement("collectionProp", "name", "Arguments.arguments")
+	for _, cv := range chainedValues {
+		if !cv.ExternalSource {
+			continue
+		}
+		if cv.InitScript != "" {
+			log.Printf("JMeter: the initializer of %s is not run; it keeps its recorded value", cv.VariableName)
+		}
+		arguments.add(newJMXElement("elementProp", "name", cv.VariableName, "elementType", "Argument").add(
+			stringProp("Argument.name", cv.VariableName),
+			stringProp("Argument.value", jmeterEscape(cv.Value)),
+			stringProp("Argument.metadata", "="),
+		))
+	}
+	if len(arguments.children) == 0 {
+		return nil
+	}
+	return newJMXElement("Arguments", "guiclass", "ArgumentsPanel", "testclass", "Arguments", "testname", "User Defined Variables").add(arguments)
+}
+
+// jmeterSampler returns the HTTP sampler for a call and its children: the header manager, a timer
+// reproducing the pause after the previous call, and the extractors for its response-chained values.
+func jmeterSampler(callDetails *CallDetails, previous *Entry) (*jmxElement, []*jmxElement) {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	name := callDetails.Name
+	if name == "" {
+		name = request.Method + " " + request.URL
+	}
+	parsedURL, err := url.Parse(request.URL)
+	if err != nil {
+		log.Printf("JMeter: unable to parse URL %s: %v", request.URL, err)
+		parsedURL = &url.URL{Path: request.URL}
+	}
+	path := parsedURL.EscapedPath()
+	if parsedURL.RawQuery != "" {
+		path += "?" + parsedURL.RawQuery
+	}
+
+	arguments := newJMXElement("collectionProp", "name", "Arguments.arguments")
+	postBodyRaw := false
+	if request.PostData != nil && request.PostData.Text != "" {
+		if request.PostData.MimeType == "application/x-www-form-urlencoded" {
+			for _, pair := range decodeFormBody(request.PostData.Text) {
+				arguments.add(newJMXElement("elementProp", "name", pair[0], "elementType", "HTTPArgument").add(
+					boolProp("HTTPArgument.always_encode", true),
+					stringProp("Argument.name", pair[0]),
+					stringProp("Argument.value", jmeterTemplate(pair[1], refs)),
+					stringProp("Argument.metadata", "="),
+				))
+			}
+		} else {
+			postBodyRaw = true
+			arguments.add(newJMXElement("elementProp", "name", "", "elementType", "HTTPArgument").add(
+				boolProp("HTTPArgument.always_encode", false),
+				stringProp("Argument.value", jmeterTemplate(request.PostData.Text, refs)),
+				stringProp("Argument.metadata", "="),
+			))
+		}
+	}
+
+	sampler := newJMXElement("HTTPSamplerProxy", "guiclass", "HttpTestSampleGui", "testclass", "HTTPSamplerProxy", "testname", name).add(
+		stringProp("HTTPSampler.domain", jmeterTemplate(parsedURL.Hostname(), refs)),
+		stringProp("HTTPSampler.port", parsedURL.Port()),
+		stringProp("HTTPSampler.protocol", parsedURL.Scheme),
+		stringProp("HTTPSampler.path", jmeterTemplate(path, refs)),
+		stringProp("HTTPSampler.method", request.Method),
+		stringProp("HTTPSampler.contentEncoding", "UTF-8"),
+		boolProp("HTTPSampler.follow_redirects", true),
+		boolProp("HTTPSampler.use_keepalive", true),
+		boolProp("HTTPSampler.postBodyRaw", postBodyRaw),
+		newJMXElement("elementProp", "name", "HTTPsampler.Arguments", "elementType", "Arguments").add(arguments),
+	)
+
+	var children []*jmxElement
+	headers := newJMXElement("collectionProp", "name", "HeaderManager.headers")
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		headers.add(newJMXElement("elementProp", "name", "", "elementType", "Header").add(
+			stringProp("Header.name", header.Name),
+			stringProp("Header.value", jmeterTemplate(header.Value, refs)),
+		))
+	}
+	if len(headers.children) > 0 {
+		children = append(children,
+			newJMXElement("HeaderManager", "guiclass", "HeaderPanel", "testclass", "HeaderManager", "testname", "HTTP Header Manager").add(headers),
+			newJMXElement("hashTree"))
+	}
+
+	if previous != nil {
+		if pause := thinkTime(previous, callDetails.Entry); pause >= minThinkTime {
+			children = append(children,
+				newJMXElement("ConstantTimer", "guiclass", "ConstantTimerGui", "testclass", "ConstantTimer", "testname", "Recorded think time").add(
+					stringProp("ConstantTimer.delay", fmt.Sprint(pause.Milliseconds())),
+				),
+				newJMXElement("hashTree"))
+		}
+	}
+
+	extracted := make(map[string]bool)
+	for _, ref := range callDetails.ResponseChainedValues {
+		if ref.SourceType != SourceTypeResponse || ref.Context == nil || extracted[ref.Context.VariableName] {
+			continue
+		}
+		extracted[ref.Context.VariableName] = true
+		for _, extractor := range jmeterExtractors(ref, ref.Context.VariableName) {
+			children = append(children, extractor, newJMXElement("hashTree"))
+		}
+	}
+	return sampler, children
+}
+
+// jmeterExtractors returns the post-processors storing a response value in the JMeter variable name.
+// JWT claims are decoded by a Groovy post-processor from the token, which is extracted first.
+func jmeterExtractors(ref *ValueReference, name string) []*jmxElement {
+	switch {
+	case ref.JWTParent != nil:
+		tokenVariable := name + "_jwt"
+		decoder, err := jmeterJWTDecoder(ref, tokenVariable, name)
+		if err != nil {
+			log.Printf("JMeter: unable to extract %s from %s: %v", name, ref.ReferencePath, err)
+			return nil
+		}
+		return append(jmeterExtractors(ref.JWTParent, tokenVariable), decoder)
+	case ref.SourceLocation == SourceLocationCookie:
+		return []*jmxElement{jmeterRegexExtractor(name, fmt.Sprintf(`(?im)^Set-Cookie:\s*%s=([^;\r\n]*)`, regexp.QuoteMeta(ref.CookieName)))}
+	case ref.SourceLocation == SourceLocationHeader:
+		prefix := ""
+		if strings.EqualFold(ref.HeaderName, "Authorization") {
+			// processHeaders strips the scheme from authorization values.
+			prefix = `(?:Bearer\s+)?`
+		}
+		return []*jmxElement{jmeterRegexExtractor(name, fmt.Sprintf(`(?im)^%s:\s*%s([^\r\n]*?)\s*$`, regexp.QuoteMeta(ref.HeaderName), prefix))}
+	}
+	path, err := referenceJSONPath(ref)
+	if err != nil {
+		log.Printf("JMeter: unable to extract %s from %s: %v", name, ref.ReferencePath, err)
+		return nil
+	}
+	return []*jmxElement{newJMXElement("JSONPostProcessor", "guiclass", "JSONPostProcessorGui", "testclass", "JSONPostProcessor", "testname", "Extract "+name).add(
+		stringProp("JSONPostProcessor.referenceNames", name),
+		stringProp("JSONPostProcessor.jsonPathExprs", path),
+		stringProp("JSONPostProcessor.match_numbers", "1"),
+		stringProp("JSONPostProcessor.defaultValues", name+"_NOT_FOUND"),
+	)}
+}
+
+// jmeterRegexExtractor returns a regular expression extractor applied to the response headers.
+func jmeterRegexExtractor(name string, regex string) *jmxElement {
+	return newJMXElement("RegexExtractor", "guiclass", "RegexExtractorGui", "testclass", "RegexExtractor", "testname", "Extract "+name).add(
+		stringProp("RegexExtractor.useHeaders", "true"),
+		stringProp("RegexExtractor.refname", name),
+		stringProp("RegexExtractor.regex", regex),
+		stringProp("RegexExtractor.template", "$1$"),
+		stringProp("RegexExtractor.default", name+"_NOT_FOUND"),
+		stringProp("RegexExtractor.match_number", "1"),
+	)
+}
+
+// jmeterJWTDecoder returns a Groovy post-processor that decodes the token in tokenVariable and stores a claim in name.
+func jmeterJWTDecoder(ref *ValueReference, tokenVariable string, name string) (*jmxElement, error) {
+	expr, err := jwtClaimExpr(ref.JWTClaimPath)
+	if err != nil {
+		return nil, err
+	}
+	if !expr.Definite() {
+		return nil, fmt.Errorf("claim path %q is not a plain path", ref.JWTClaimPath)
+	}
+	script := strings.Join([]string{
+		fmt.Sprintf("def token = vars.get(%s).replaceFirst(/^Bearer\\s+/, \"\")", jsString(tokenVariable)),
+		fmt.Sprintf("def part = token.split(\"\\\\.\")[%d].replace(\"=\", \"\")", jwtPartIndex(ref.JWTClaimPath)),
+		"def claims = new groovy.json.JsonSlurper().parse(java.util.Base64.getUrlDecoder().decode(part))",
+		fmt.Sprintf("vars.put(%s, String.valueOf(%s))", jsString(name), expr.JSExpression("claims")),
+	}, "\n")
+	return newJMXElement("JSR223PostProcessor", "guiclass", "TestBeanGUI", "testclass", "JSR223PostProcessor", "testname", "Decode "+name).add(
+		stringProp("scriptLanguage", "groovy"),
+		stringProp("cacheKey", "true"),
+		stringProp("script", script),
+	), nil
+}
+
+// jmeterTemplate renders a recorded string with chained values replaced by JMeter variable references,
+// applying the encoding each usage needs.
+func jmeterTemplate(s string, refs []*ValueReference) string {
+	var sb strings.Builder
+	for _, segment := range splitChainedValues(s, refs) {
+		if segment.ref == nil {
+			sb.WriteString(jmeterEscape(segment.literal))
+			continue
+		}
+		sb.WriteString(jmeterTransform(segment.ref.Transform, segment.ref.Context.VariableName))
+	}
+	return sb.String()
+}
+
+// jmeterTransform returns the JMeter expression producing the variable name under a value transform.
+// Core JMeter only has a URL-encoding function, so the other encodings use Groovy.
+func jmeterTransform(transform string, name string) string {
+	value := fmt.Sprintf("vars.get(%s)", jsString(name))
+	switch transform {
+	case "urlencoded":
+		return fmt.Sprintf("${__urlencode(${%s})}", name)
+	case "base64":
+		return fmt.Sprintf("${__groovy(java.util.Base64.getEncoder().encodeToString(%s.bytes))}", value)
+	case "base64url":
+		return fmt.Sprintf("${__groovy(java.util.Base64.getUrlEncoder().withoutPadding().encodeToString(%s.bytes))}", value)
+	case "json_escaped":
+		return fmt.Sprintf("${__groovy(groovy.json.JsonOutput.toJson(%s)[1..-2])}", value)
+	}
+	return "${" + name + "}"
+}
+
+// jmeterEscape escapes recorded text so that JMeter does not evaluate "${" sequences in it.
+func jmeterEscape(s string) string {
+	return strings.ReplaceAll(s, "${", "\\${")
+}
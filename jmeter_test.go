@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJMeterStrings(t *testing.T) {
+	refs := []*ValueReference{{Value: "abc123", Context: &ChainedValueContext{VariableName: "token"}}}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"template", jmeterTemplate(trickyText, refs), "a'b\"c $HOME `id` \\ \\${x}\n\t${token}"},
+		{"xml", xmlEscape(`<a b="c">&</a>`), "&lt;a b=&quot;c&quot;&gt;&amp;&lt;/a&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildJMeterTestPlan(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	plan := BuildJMeterTestPlan(callDetailsList, chainedValues)
+	for _, want := range []string{
+		`<stringProp name="JSONPostProcessor.referenceNames">dataToken</stringProp>`,
+		`<stringProp name="JSONPostProcessor.jsonPathExprs">$.data.token</stringProp>`,
+		`<stringProp name="Header.value">Bearer ${dataToken}</stringProp>`,
+		`<stringProp name="JSONPostProcessor.jsonPathExprs">$.order.id</stringProp>`,
+		`<stringProp name="HTTPSampler.path">/v1/orders/${orderId}</stringProp>`,
+	} {
+		if !strings.Contains(plan, want) {
+			t.Errorf("missing %q in:\n%s", want, plan)
+		}
+	}
+}
+
+func TestBuildJMeterTestPlanUnreadablePath(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	callDetailsList[1].ResponseChainedValues[0].ReferencePath = "order["
+	logged := captureLog(t)
+
+	plan := BuildJMeterTestPlan(callDetailsList, chainedValues)
+	if strings.Contains(plan, "Extract orderId") {
+		t.Errorf("the unreadable orderId has an extractor:\n%s", plan)
+	}
+	if !strings.Contains(logged.String(), "JMeter: unable to extract orderId") {
+		t.Errorf("the missing extractor was not logged: %q", logged.String())
+	}
+}
@@ -0,0 +1,1005 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// pathStepKind identifies the kind of a single step in a reference path.
+type pathStepKind int
+
+const (
+	// stepKey selects a property of an object: .name or ['name'].
+	stepKey pathStepKind = iota
+
+	// stepIndex selects an array element: [2]. Negative indices count from the end.
+	stepIndex
+
+	// stepWildcard selects every child: .* or [*].
+	stepWildcard
+
+	// stepRecursive selects every descendant with the given key (..name), or every descendant (..*).
+	stepRecursive
+
+	// stepFilter selects the children that satisfy a predicate: [?(@.type=='ADT')].
+	stepFilter
+)
+
+// pathStep is a single step of a parsed reference path.
+type pathStep struct {
+	kind   pathStepKind
+	key    string
+	index  int
+	filter []filterCondition
+}
+
+// filterCondition is one comparison inside a filter; conditions in a filter are joined with &&.
+// An empty op tests only that the property exists.
+type filterCondition struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+// ReferenceExpr is a parsed ValueReference.ReferencePath. It covers the forms the tool produces or accepts:
+// raw flattened paths ("data.items[0].id"), JavaScript accessors rooted at responseJson,
+// JSONPath expressions ("$.items[?(@.type=='ADT')].id") and jsonpath.query(responseJson, '...')[n] calls.
+type ReferenceExpr struct {
+	Steps []pathStep
+
+	// Query is true for jsonpath.query(...) expressions, which evaluate to an array of matches.
+	Query bool
+
+	// QueryIndex is the index applied to the result of jsonpath.query, or -1 if there is none.
+	QueryIndex int
+}
+
+// ParseReferencePath parses any supported reference path form into a ReferenceExpr.
+func ParseReferencePath(expr string) (*ReferenceExpr, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimSpace(strings.TrimSuffix(s, ";"))
+
+	switch {
+	case strings.HasPrefix(s, "jsonpath.query("):
+		return parseJSONPathQuery(s)
+	case strings.HasPrefix(s, "$"):
+		steps, err := parseJSONPath(s)
+		if err != nil {
+			return nil, err
+		}
+		return &ReferenceExpr{Steps: steps, QueryIndex: -1}, nil
+	case s == "responseJson" || strings.HasPrefix(s, "responseJson.") || strings.HasPrefix(s, "responseJson[") || strings.HasPrefix(s, "responseJson?."):
+		steps, err := parseAccessorPath(strings.TrimPrefix(s, "responseJson"), false)
+		if err != nil {
+			return nil, err
+		}
+		return &ReferenceExpr{Steps: steps, QueryIndex: -1}, nil
+	default:
+		// A raw path as produced by flatten: keys may contain any character except '.' and '['.
+		raw := s
+		if raw != "" && !strings.HasPrefix(raw, "[") {
+			raw = "." + raw
+		}
+		steps, err := parseAccessorPath(raw, true)
+		if err != nil {
+			return nil, err
+		}
+		return &ReferenceExpr{Steps: steps, QueryIndex: -1}, nil
+	}
+}
+
+// parseJSONPathQuery parses jsonpath.query(responseJson, '<jsonpath>') with an optional trailing [n].
+func parseJSONPathQuery(s string) (*ReferenceExpr, error) {
+	rest := strings.TrimSpace(strings.TrimPrefix(s, "jsonpath.query("))
+	if !strings.HasPrefix(rest, "responseJson") {
+		return nil, errors.New("jsonpath.query must be applied to responseJson")
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "responseJson"))
+	if !strings.HasPrefix(rest, ",") {
+		return nil, errors.New("expected ',' after responseJson in jsonpath.query")
+	}
+	rest = strings.TrimSpace(rest[1:])
+	path, n, err := readQuoted(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath.query argument: %w", err)
+	}
+	rest = strings.TrimSpace(rest[n:])
+	if !strings.HasPrefix(rest, ")") {
+		return nil, errors.New("expected ')' to close jsonpath.query")
+	}
+	rest = strings.TrimSpace(rest[1:])
+
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReferenceExpr{Steps: steps, Query: true, QueryIndex: -1}
+	if rest == "" {
+		return result, nil
+	}
+	if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+		return nil, fmt.Errorf("unexpected trailing text after jsonpath.query: %q", rest)
+	}
+	idx, err := strconv.Atoi(strings.TrimSpace(rest[1 : len(rest)-1]))
+	if err != nil || idx < 0 {
+		return nil, fmt.Errorf("invalid index after jsonpath.query: %q", rest)
+	}
+	result.QueryIndex = idx
+	return result, nil
+}
+
+// parseAccessorPath parses a chain of JavaScript-style accessors: .key, ?.key, ["key"], ['key'] and [n].
+// In lenient mode dotted keys may contain any character other than '.' and '[', matching flatten's output.
+func parseAccessorPath(s string, lenient bool) ([]pathStep, error) {
+	var steps []pathStep
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "?."):
+			i++
+			fallthrough
+		case s[i] == '.':
+			i++
+			start := i
+			for i < len(s) && s[i] != '.' && s[i] != '[' && (lenient || s[i] != '?') {
+				i++
+			}
+			key := s[start:i]
+			if key == "" {
+				return nil, fmt.Errorf("empty property name at offset %d in %q", start, s)
+			}
+			if !lenient && !isJSIdentifier(key) {
+				return nil, fmt.Errorf("invalid property name %q", key)
+			}
+			steps = append(steps, pathStep{kind: stepKey, key: key})
+		case s[i] == '[':
+			step, n, err := parseBracket(s[i:], false)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			i += n
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d in %q", s[i], i, s)
+		}
+	}
+	return steps, nil
+}
+
+// parseJSONPath parses a JSONPath expression starting with '$'.
+func parseJSONPath(s string) ([]pathStep, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("JSONPath must start with '$': %q", s)
+	}
+	var steps []pathStep
+	for i := 1; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], ".."):
+			i += 2
+			if i < len(s) && s[i] == '*' {
+				steps = append(steps, pathStep{kind: stepRecursive})
+				i++
+				continue
+			}
+			start := i
+			for i < len(s) && s[i] != '.' && s[i] != '[' {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("expected property name after '..' in %q", s)
+			}
+			steps = append(steps, pathStep{kind: stepRecursive, key: s[start:i]})
+		case s[i] == '.':
+			i++
+			if i < len(s) && s[i] == '*' {
+				steps = append(steps, pathStep{kind: stepWildcard})
+				i++
+				continue
+			}
+			start := i
+			for i < len(s) && s[i] != '.' && s[i] != '[' {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("empty property name in %q", s)
+			}
+			steps = append(steps, pathStep{kind: stepKey, key: s[start:i]})
+		case s[i] == '[':
+			step, n, err := parseBracket(s[i:], true)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			i += n
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d in %q", s[i], i, s)
+		}
+	}
+	return steps, nil
+}
+
+// parseBracket parses one bracketed step starting at s[0] == '[' and returns it with the number of bytes consumed.
+// Wildcards and filters are only accepted in JSONPath mode.
+func parseBracket(s string, jsonPath bool) (pathStep, int, error) {
+	inner := strings.TrimLeft(s[1:], " ")
+	offset := len(s) - len(inner)
+
+	switch {
+	case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+		key, n, err := readQuoted(inner)
+		if err != nil {
+			return pathStep{}, 0, err
+		}
+		end, err := closingBracket(s, offset+n)
+		if err != nil {
+			return pathStep{}, 0, err
+		}
+		return pathStep{kind: stepKey, key: key}, end, nil
+	case jsonPath && strings.HasPrefix(inner, "*"):
+		end, err := closingBracket(s, offset+1)
+		if err != nil {
+			return pathStep{}, 0, err
+		}
+		return pathStep{kind: stepWildcard}, end, nil
+	case jsonPath && strings.HasPrefix(inner, "?("):
+		body, n, err := readBalancedParens(inner[1:])
+		if err != nil {
+			return pathStep{}, 0, err
+		}
+		conditions, err := parseFilter(body)
+		if err != nil {
+			return pathStep{}, 0, err
+		}
+		end, err := closingBracket(s, offset+1+n)
+		if err != nil {
+			return pathStep{}, 0, err
+		}
+		return pathStep{kind: stepFilter, filter: conditions}, end, nil
+	default:
+		close := strings.IndexByte(s, ']')
+		if close == -1 {
+			return pathStep{}, 0, fmt.Errorf("missing ']' in %q", s)
+		}
+		idx, err := strconv.Atoi(strings.TrimSpace(s[1:close]))
+		if err != nil {
+			return pathStep{}, 0, fmt.Errorf("unable to parse index %q", s[1:close])
+		}
+		return pathStep{kind: stepIndex, index: idx}, close + 1, nil
+	}
+}
+
+// closingBracket skips spaces from s[from:] and expects ']', returning the offset just past it.
+func closingBracket(s string, from int) (int, error) {
+	i := from
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i >= len(s) || s[i] != ']' {
+		return 0, fmt.Errorf("missing ']' in %q", s)
+	}
+	return i + 1, nil
+}
+
+// readQuoted reads a single- or double-quoted string literal at the start of s,
+// returning the unescaped value and the number of bytes consumed.
+func readQuoted(s string) (string, int, error) {
+	if s == "" || (s[0] != '\'' && s[0] != '"') {
+		return "", 0, errors.New("expected quoted string")
+	}
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			sb.WriteByte(s[i])
+		case c == quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string in %q", s)
+}
+
+// readBalancedParens reads "( ... )" at the start of s, honoring quoted strings,
+// and returns the contents and the number of bytes consumed.
+func readBalancedParens(s string) (string, int, error) {
+	if s == "" || s[0] != '(' {
+		return "", 0, errors.New("expected '('")
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			_, n, err := readQuoted(s[i:])
+			if err != nil {
+				return "", 0, err
+			}
+			i += n - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], i + 1, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("unbalanced parentheses in %q", s)
+}
+
+// parseFilter parses a filter body such as "@.type=='ADT' && @.age > 1" into its conditions.
+// Only equality, inequality and existence tests joined by && are supported.
+func parseFilter(body string) ([]filterCondition, error) {
+	var conditions []filterCondition
+	for _, part := range splitOutsideQuotes(body, "&&") {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "@") {
+			return nil, fmt.Errorf("filter condition must start with '@': %q", part)
+		}
+		op := ""
+		left, right := part, ""
+		// Longer operators first so that "===" is not mistaken for "==".
+		for _, candidate := range []struct{ token, op string }{{"===", "=="}, {"!==", "!="}, {"==", "=="}, {"!=", "!="}} {
+			if idx := indexOutsideQuotes(part, candidate.token); idx != -1 {
+				op = candidate.op
+				left, right = part[:idx], part[idx+len(candidate.token):]
+				break
+			}
+		}
+
+		steps, err := parseAccessorPath(strings.TrimSpace(left)[1:], false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter property %q: %w", left, err)
+		}
+		var keys []string
+		for _, st := range steps {
+			if st.kind != stepKey {
+				return nil, fmt.Errorf("filter properties must be plain keys: %q", left)
+			}
+			keys = append(keys, st.key)
+		}
+
+		cond := filterCondition{path: keys, op: op}
+		if op != "" {
+			cond.value, err = parseLiteral(strings.TrimSpace(right))
+			if err != nil {
+				return nil, err
+			}
+		}
+		conditions = append(conditions, cond)
+	}
+	return conditions, nil
+}
+
+// parseLiteral parses a filter literal: a quoted string, number, true, false or null.
+func parseLiteral(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		v, n, err := readQuoted(s)
+		if err != nil {
+			return nil, err
+		}
+		if n != len(s) {
+			return nil, fmt.Errorf("unexpected text after literal %q", s)
+		}
+		return v, nil
+	}
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported filter literal %q", s)
+	}
+	return f, nil
+}
+
+// splitOutsideQuotes splits s on sep, ignoring separators inside quoted strings.
+func splitOutsideQuotes(s string, sep string) []string {
+	var parts []string
+	for {
+		idx := indexOutsideQuotes(s, sep)
+		if idx == -1 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:idx])
+		s = s[idx+len(sep):]
+	}
+}
+
+// indexOutsideQuotes returns the index of the first occurrence of sub in s that is not inside a quoted string.
+func indexOutsideQuotes(s string, sub string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sub):
+			return i
+		}
+	}
+	return -1
+}
+
+// isJSIdentifier reports whether s can be used after a '.' in JavaScript.
+func isJSIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Definite reports whether the expression can select at most one node, i.e. it only uses keys and indices.
+func (e *ReferenceExpr) Definite() bool {
+	for _, st := range e.Steps {
+		if st.kind != stepKey && st.kind != stepIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate applies the expression to a decoded JSON document, preferably from decodeJSONDocument so that
+// object keys are visited in document order. Plain paths return the single selected value;
+// jsonpath.query expressions return the array of matches, or the indexed match if an index follows the call.
+func (e *ReferenceExpr) Evaluate(root interface{}) (interface{}, error) {
+	nodes := evaluateSteps(root, e.Steps)
+
+	if e.Query {
+		if e.QueryIndex < 0 {
+			result := make([]interface{}, len(nodes))
+			copy(result, nodes)
+			return result, nil
+		}
+		if e.QueryIndex >= len(nodes) {
+			return nil, fmt.Errorf("query matched %d nodes, index %d is out of range", len(nodes), e.QueryIndex)
+		}
+		return nodes[e.QueryIndex], nil
+	}
+
+	if len(nodes) == 0 {
+		return nil, errors.New("path does not match any value")
+	}
+	if e.Definite() && len(nodes) > 1 {
+		return nil, fmt.Errorf("path matched %d values", len(nodes))
+	}
+	return nodes[0], nil
+}
+
+// evaluateSteps applies each step to the current node set in turn.
+func evaluateSteps(root interface{}, steps []pathStep) []interface{} {
+	nodes := []interface{}{root}
+	for _, st := range steps {
+		var next []interface{}
+		for _, node := range nodes {
+			switch st.kind {
+			case stepKey:
+				if _, values, ok := objectEntries(node); ok {
+					if v, exists := values[st.key]; exists {
+						next = append(next, v)
+					}
+				}
+			case stepIndex:
+				if arr, ok := node.([]interface{}); ok {
+					idx := st.index
+					if idx < 0 {
+						idx += len(arr)
+					}
+					if idx >= 0 && idx < len(arr) {
+						next = append(next, arr[idx])
+					}
+				}
+			case stepWildcard:
+				next = append(next, childNodes(node)...)
+			case stepRecursive:
+				next = append(next, descendantsWithKey(node, st.key)...)
+			case stepFilter:
+				for _, child := range childNodes(node) {
+					if matchesFilter(child, st.filter) {
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// childNodes returns the children of an object (in document order) or array.
+func childNodes(node interface{}) []interface{} {
+	if keys, values, ok := objectEntries(node); ok {
+		var children []interface{}
+		for _, key := range keys {
+			children = append(children, values[key])
+		}
+		return children
+	}
+	if arr, ok := node.([]interface{}); ok {
+		return arr
+	}
+	return nil
+}
+
+// descendantsWithKey returns, in document order, the values of every property named key found at or below node.
+// An empty key returns every descendant.
+func descendantsWithKey(node interface{}, key string) []interface{} {
+	var result []interface{}
+	if keys, values, ok := objectEntries(node); ok {
+		for _, k := range keys {
+			if key == "" || k == key {
+				result = append(result, values[k])
+			}
+			result = append(result, descendantsWithKey(values[k], key)...)
+		}
+	} else if arr, ok := node.([]interface{}); ok {
+		for _, child := range arr {
+			if key == "" {
+				result = append(result, child)
+			}
+			result = append(result, descendantsWithKey(child, key)...)
+		}
+	}
+	return result
+}
+
+// jsonObject is a JSON object decoded by decodeJSONDocument, which keeps its keys in document order
+// so that wildcards and recursive descent visit them in the same order as JavaScript and jq.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// objectEntries returns the keys and values of a decoded JSON object. Keys are in document order for a
+// jsonObject, and sorted for a map decoded by encoding/json, whose order is lost.
+func objectEntries(node interface{}) ([]string, map[string]interface{}, bool) {
+	switch v := node.(type) {
+	case *jsonObject:
+		return v.keys, v.values, true
+	case map[string]interface{}:
+		return sortedKeys(v), v, true
+	}
+	return nil, nil, false
+}
+
+// decodeJSONDocument decodes a JSON document like encoding/json, except that objects are *jsonObject values.
+func decodeJSONDocument(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// decodeJSONValue decodes the next value from dec, recursing into objects and arrays.
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{values: make(map[string]interface{})}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := obj.values[key]; !exists {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	}
+	return tok, nil
+}
+
+// matchesFilter reports whether node satisfies every condition.
+func matchesFilter(node interface{}, conditions []filterCondition) bool {
+	for _, cond := range conditions {
+		current := node
+		found := true
+		for _, key := range cond.path {
+			_, values, ok := objectEntries(current)
+			if !ok {
+				found = false
+				break
+			}
+			current, found = values[key]
+			if !found {
+				break
+			}
+		}
+		switch cond.op {
+		case "":
+			if !found {
+				return false
+			}
+		case "==":
+			if !found || !jsonValuesEqual(current, cond.value) {
+				return false
+			}
+		case "!=":
+			if found && jsonValuesEqual(current, cond.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// jsonValuesEqual compares two decoded JSON scalars.
+func jsonValuesEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		return ok && av == bv
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case nil:
+		return b == nil
+	}
+	return false
+}
+
+// JSExpression renders a plain (non-query) expression as a JavaScript accessor chain on root.
+// Query expressions are rendered as jsonpath.query calls.
+func (e *ReferenceExpr) JSExpression(root string) string {
+	if e.Query || !e.Definite() {
+		expr := fmt.Sprintf("jsonpath.query(%s, %s)", root, jsString(e.JSONPath()))
+		if e.Query && e.QueryIndex < 0 {
+			return expr
+		}
+		idx := e.QueryIndex
+		if idx < 0 {
+			idx = 0
+		}
+		return fmt.Sprintf("%s[%d]", expr, idx)
+	}
+	var sb strings.Builder
+	sb.WriteString(root)
+	for _, st := range e.Steps {
+		if st.kind == stepIndex {
+			fmt.Fprintf(&sb, "[%d]", st.index)
+		} else if isJSIdentifier(st.key) {
+			sb.WriteString("." + st.key)
+		} else {
+			fmt.Fprintf(&sb, "[%s]", jsString(st.key))
+		}
+	}
+	return sb.String()
+}
+
+// NativeJSExpression renders the expression as plain JavaScript on root, for runtimes without a JSONPath
+// library: filters become Array.prototype.find calls. Only expressions selecting a single, first match
+// can be rendered; wildcards and recursive descent are rejected.
+func (e *ReferenceExpr) NativeJSExpression(root string) (string, error) {
+	if e.Query && e.QueryIndex != 0 {
+		return "", fmt.Errorf("only the first query result can be selected, not [%d]", e.QueryIndex)
+	}
+	var sb strings.Builder
+	sb.WriteString(root)
+	for _, st := range e.Steps {
+		switch st.kind {
+		case stepKey:
+			sb.WriteString(jsAccessor(st.key))
+		case stepIndex:
+			fmt.Fprintf(&sb, "[%d]", st.index)
+		case stepFilter:
+			var parts []string
+			for _, cond := range st.filter {
+				left := "e"
+				for _, key := range cond.path {
+					left += jsAccessor(key)
+				}
+				switch cond.op {
+				case "":
+					parts = append(parts, left+" !== undefined")
+				case "==":
+					parts = append(parts, left+" === "+jsLiteral(cond.value))
+				case "!=":
+					parts = append(parts, left+" !== "+jsLiteral(cond.value))
+				}
+			}
+			fmt.Fprintf(&sb, ".find((e) => %s)", strings.Join(parts, " && "))
+		default:
+			return "", fmt.Errorf("wildcards and recursive descent cannot be rendered without JSONPath")
+		}
+	}
+	return sb.String(), nil
+}
+
+// jsAccessor renders a property access, using dot notation where possible.
+func jsAccessor(key string) string {
+	if isJSIdentifier(key) {
+		return "." + key
+	}
+	return "[" + jsString(key) + "]"
+}
+
+// jsLiteral renders a decoded JSON scalar as a JavaScript literal.
+func jsLiteral(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return jsString(s)
+	}
+	return filterLiteral(v)
+}
+
+// JSONPath renders the steps as a JSONPath expression.
+func (e *ReferenceExpr) JSONPath() string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, st := range e.Steps {
+		switch st.kind {
+		case stepKey:
+			if isJSIdentifier(st.key) {
+				sb.WriteString("." + st.key)
+			} else {
+				sb.WriteString("[" + jsSingleQuote(st.key) + "]")
+			}
+		case stepIndex:
+			fmt.Fprintf(&sb, "[%d]", st.index)
+		case stepWildcard:
+			sb.WriteString("[*]")
+		case stepRecursive:
+			if st.key == "" {
+				sb.WriteString("..*")
+			} else {
+				sb.WriteString(".." + st.key)
+			}
+		case stepFilter:
+			var parts []string
+			for _, cond := range st.filter {
+				left := "@"
+				for _, key := range cond.path {
+					if isJSIdentifier(key) {
+						left += "." + key
+					} else {
+						left += "[" + jsSingleQuote(key) + "]"
+					}
+				}
+				if cond.op == "" {
+					parts = append(parts, left)
+				} else {
+					parts = append(parts, left+cond.op+filterLiteral(cond.value))
+				}
+			}
+			sb.WriteString("[?(" + strings.Join(parts, " && ") + ")]")
+		}
+	}
+	return sb.String()
+}
+
+// JQExpression renders the expression as a jq filter. Expressions that may match several nodes are wrapped
+// as [...][n], selecting the same match as JSExpression; a query without an index yields the array of matches.
+func (e *ReferenceExpr) JQExpression() string {
+	var parts []string
+	chain := ""
+	flush := func() {
+		if chain != "" {
+			parts = append(parts, chain)
+			chain = ""
+		}
+	}
+	for _, st := range e.Steps {
+		switch st.kind {
+		case stepKey:
+			chain += jqAccessor(st.key)
+		case stepIndex:
+			if chain == "" {
+				chain = "."
+			}
+			chain += fmt.Sprintf("[%d]", st.index)
+		case stepWildcard:
+			if chain == "" {
+				chain = "."
+			}
+			chain += "[]?"
+		case stepRecursive:
+			flush()
+			if st.key == "" {
+				parts = append(parts, "[..][1:][]")
+			} else {
+				parts = append(parts, fmt.Sprintf("(.. | objects | select(has(%s)) | %s)", jsString(st.key), jqAccessor(st.key)))
+			}
+		case stepFilter:
+			if chain == "" {
+				chain = "."
+			}
+			chain += "[]?"
+			flush()
+			var conds []string
+			for _, cond := range st.filter {
+				left := ""
+				for _, key := range cond.path {
+					left += jqAccessor(key)
+				}
+				switch cond.op {
+				case "":
+					conds = append(conds, left+" != null")
+				default:
+					conds = append(conds, left+" "+cond.op+" "+jqLiteral(cond.value))
+				}
+			}
+			parts = append(parts, "select("+strings.Join(conds, " and ")+")")
+		}
+	}
+	flush()
+	expr := strings.Join(parts, " | ")
+	if expr == "" {
+		expr = "."
+	}
+	if !e.Query && e.Definite() {
+		return expr
+	}
+	if e.Query && e.QueryIndex < 0 {
+		return "[" + expr + "]"
+	}
+	idx := e.QueryIndex
+	if idx < 0 {
+		idx = 0
+	}
+	return fmt.Sprintf("[%s][%d]", expr, idx)
+}
+
+// jqAccessor renders a property access in jq, quoting keys that are not identifiers.
+func jqAccessor(key string) string {
+	for i, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return "." + jsString(key)
+		}
+	}
+	if key == "" {
+		return `.""`
+	}
+	return "." + key
+}
+
+// jqLiteral renders a decoded JSON scalar as a jq literal, which is its JSON encoding.
+func jqLiteral(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return jsString(s)
+	}
+	if v == nil {
+		return "null"
+	}
+	return filterLiteral(v)
+}
+
+// filterLiteral renders a filter comparison value.
+func filterLiteral(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return jsSingleQuote(val)
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// jsSingleQuote renders s as a single-quoted string literal.
+func jsSingleQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// jsString renders s as a double-quoted JavaScript (and JSON) string literal without HTML escaping.
+func jsString(s string) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// cleanModelPath strips the decoration models sometimes add around a bare expression,
+// such as Markdown code fences, backticks and surrounding whitespace.
+func cleanModelPath(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if nl := strings.IndexByte(s, '\n'); nl != -1 {
+			s = s[nl+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+	s = strings.Trim(strings.TrimSpace(s), "`")
+	return strings.TrimSpace(s)
+}
+
+// validateRefinedPath checks that path, evaluated against the recorded response body, yields the expected value.
+// It returns the path to store. A jsonpath.query call selecting the first match, with or without a trailing [0],
+// is stored as its JSONPath expression, which exporters render for their own runtime.
+func validateRefinedPath(responseBody string, path string, expected string) (string, error) {
+	root, err := decodeJSONDocument(responseBody)
+	if err != nil {
+		return "", fmt.Errorf("response body is not JSON: %w", err)
+	}
+	expr, err := ParseReferencePath(path)
+	if err != nil {
+		return "", err
+	}
+	value, err := expr.Evaluate(root)
+	if err != nil {
+		return "", err
+	}
+
+	if expr.Query && expr.QueryIndex < 0 {
+		matches, _ := value.([]interface{})
+		if len(matches) == 0 {
+			return "", errors.New("query does not match any value")
+		}
+		if fmt.Sprintf("%v", matches[0]) != expected {
+			return "", fmt.Errorf("query returns %v, expected %q", matches[0], expected)
+		}
+		value = matches[0]
+	}
+
+	if fmt.Sprintf("%v", value) != expected {
+		return "", fmt.Errorf("path returns %v, expected %q", value, expected)
+	}
+	if expr.Query && expr.QueryIndex <= 0 {
+		first := &ReferenceExpr{Steps: expr.Steps, QueryIndex: -1}
+		return first.JSONPath(), nil
+	}
+	return strings.TrimSpace(path), nil
+}
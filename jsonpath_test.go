@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseReferencePath(t *testing.T) {
+	tests := []struct {
+		path       string
+		jsonPath   string
+		query      bool
+		queryIndex int
+		definite   bool
+	}{
+		{"data.items[0].id", "$.data.items[0].id", false, -1, true},
+		{"[1].id", "$[1].id", false, -1, true},
+		{"meta.content-type", "$.meta['content-type']", false, -1, true},
+		{"responseJson.data.id", "$.data.id", false, -1, true},
+		{`responseJson["odd key"][2]`, "$['odd key'][2]", false, -1, true},
+		{"responseJson?.data?.id;", "$.data.id", false, -1, true},
+		{"$.travelers[?(@.type=='ADT')].id", "$.travelers[?(@.type=='ADT')].id", false, -1, false},
+		{"$..id", "$..id", false, -1, false},
+		{"$.items[*].name", "$.items[*].name", false, -1, false},
+		{"$.items[-1]", "$.items[-1]", false, -1, true},
+		{`jsonpath.query(responseJson, "$.a[?(@.k=='v' && @.n==2)].id")[0]`, "$.a[?(@.k=='v' && @.n==2)].id", true, 0, false},
+		{"jsonpath.query(responseJson, '$.a[?(@.flag)].id')", "$.a[?(@.flag)].id", true, -1, false},
+		{"jsonpath.query(responseJson, '$.a[?(@.k!=null)].id')[3]", "$.a[?(@.k!=null)].id", true, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			expr, err := ParseReferencePath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseReferencePath: %v", err)
+			}
+			if got := expr.JSONPath(); got != tt.jsonPath {
+				t.Errorf("JSONPath() = %s, want %s", got, tt.jsonPath)
+			}
+			if expr.Query != tt.query || expr.QueryIndex != tt.queryIndex || expr.Definite() != tt.definite {
+				t.Errorf("Query, QueryIndex, Definite = %v, %d, %v; want %v, %d, %v",
+					expr.Query, expr.QueryIndex, expr.Definite(), tt.query, tt.queryIndex, tt.definite)
+			}
+		})
+	}
+}
+
+func TestParseReferencePathErrors(t *testing.T) {
+	for _, path := range []string{
+		"jsonpath.query(other, '$.a')",
+		"jsonpath.query(responseJson '$.a')",
+		"jsonpath.query(responseJson, '$.a')[x]",
+		"jsonpath.query(responseJson, '$.a') + 1",
+		"$.a[?(@.k=='v')",
+		"$.a['unterminated]",
+	} {
+		if _, err := ParseReferencePath(path); err == nil {
+			t.Errorf("ParseReferencePath(%s) succeeded, want an error", path)
+		}
+	}
+}
+
+// evaluationDocument lists its keys out of alphabetical order, so that results in sorted order are detected.
+const evaluationDocument = `{
+  "zeta": {"id": "z1", "name": "last"},
+  "alpha": {"id": "a1", "nested": {"id": "a2"}},
+  "items": [
+    {"type": "CHD", "id": "t-1", "age": 7, "tags": {"vip": false}},
+    {"type": "ADT", "id": "t-2", "age": 40, "tags": {"vip": true}},
+    {"type": "ADT", "id": "t-3", "age": 35, "seat": null}
+  ],
+  "mixed": {"b": 2, "a": 1, "c": 3}
+}`
+
+func TestEvaluate(t *testing.T) {
+	root, err := decodeJSONDocument(evaluationDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{"zeta.id", "z1", false},
+		{"items[1].id", "t-2", false},
+		{"$.items[-1].id", "t-3", false},
+		{"items[5].id", nil, true},
+		{"missing", nil, true},
+		{"$.items[?(@.type=='ADT')].id", "t-2", false},
+		{"$.items[?(@.type=='ADT' && @.age==35)].id", "t-3", false},
+		{"$.items[?(@.type!='ADT')].id", "t-1", false},
+		{"$.items[?(@.seat)].id", "t-3", false},
+		{"$.items[?(@.seat==null)].id", "t-3", false},
+		{"$.items[?(@.tags.vip==true)].id", "t-2", false},
+		{"$.items[?(@.type=='INF')].id", nil, true},
+		{"$..id", "z1", false},
+		{"$.mixed.*", float64(2), false},
+		{"$.mixed[*]", float64(2), false},
+		{"jsonpath.query(responseJson, '$..id')", []interface{}{"z1", "a1", "a2", "t-1", "t-2", "t-3"}, false},
+		{"jsonpath.query(responseJson, '$.mixed.*')", []interface{}{float64(2), float64(1), float64(3)}, false},
+		{"jsonpath.query(responseJson, '$.items[?(@.type==\"ADT\")].id')[1]", "t-3", false},
+		{"jsonpath.query(responseJson, '$.items[?(@.type==\"ADT\")].id')[2]", nil, true},
+		{"jsonpath.query(responseJson, '$.items[?(@.age==99)].id')", []interface{}{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			expr, err := ParseReferencePath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseReferencePath: %v", err)
+			}
+			got, err := expr.Evaluate(root)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONDocumentKeepsKeyOrder(t *testing.T) {
+	root, err := decodeJSONDocument(`{"b": 1, "a": {"y": true, "x": null}, "b": 2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := root.(*jsonObject)
+	if want := []string{"b", "a"}; !reflect.DeepEqual(obj.keys, want) {
+		t.Errorf("keys = %v, want %v", obj.keys, want)
+	}
+	if obj.values["b"] != float64(2) {
+		t.Errorf("duplicate key b = %v, want the last value 2", obj.values["b"])
+	}
+	if want := []string{"y", "x"}; !reflect.DeepEqual(obj.values["a"].(*jsonObject).keys, want) {
+		t.Errorf("nested keys = %v, want %v", obj.values["a"].(*jsonObject).keys, want)
+	}
+	for _, invalid := range []string{`{"a": 1} {}`, `{"a": }`, `[1, 2`} {
+		if _, err := decodeJSONDocument(invalid); err == nil {
+			t.Errorf("decodeJSONDocument(%s) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestReferenceExprRendering(t *testing.T) {
+	tests := []struct {
+		path   string
+		js     string
+		native string
+		jq     string
+	}{
+		{
+			path:   "data.items[0].id",
+			js:     "responseJson.data.items[0].id",
+			native: "responseJson.data.items[0].id",
+			jq:     ".data.items[0].id",
+		},
+		{
+			path:   "meta.content-type",
+			js:     `responseJson.meta["content-type"]`,
+			native: `responseJson.meta["content-type"]`,
+			jq:     `.meta."content-type"`,
+		},
+		{
+			path:   "[0].id",
+			js:     "responseJson[0].id",
+			native: "responseJson[0].id",
+			jq:     ".[0].id",
+		},
+		{
+			path:   "$.travelers[?(@.type=='ADT')].id",
+			js:     `jsonpath.query(responseJson, "$.travelers[?(@.type=='ADT')].id")[0]`,
+			native: `responseJson.travelers.find((e) => e.type === "ADT").id`,
+			jq:     `[.travelers[]? | select(.type == "ADT") | .id][0]`,
+		},
+		{
+			path:   "$.items[?(@.kind=='a' && @.size==2)].id",
+			js:     `jsonpath.query(responseJson, "$.items[?(@.kind=='a' && @.size==2)].id")[0]`,
+			native: `responseJson.items.find((e) => e.kind === "a" && e.size === 2).id`,
+			jq:     `[.items[]? | select(.kind == "a" and .size == 2) | .id][0]`,
+		},
+		{
+			path:   "$.items[?(@.flag)].id",
+			js:     `jsonpath.query(responseJson, "$.items[?(@.flag)].id")[0]`,
+			native: `responseJson.items.find((e) => e.flag !== undefined).id`,
+			jq:     `[.items[]? | select(.flag != null) | .id][0]`,
+		},
+		{
+			path:   "$.items[?(@.name=='O\\'Brien')].id",
+			js:     `jsonpath.query(responseJson, "$.items[?(@.name=='O\\'Brien')].id")[0]`,
+			native: `responseJson.items.find((e) => e.name === "O'Brien").id`,
+			jq:     `[.items[]? | select(.name == "O'Brien") | .id][0]`,
+		},
+		{
+			path:   "$..id",
+			js:     `jsonpath.query(responseJson, "$..id")[0]`,
+			native: "",
+			jq:     `[(.. | objects | select(has("id")) | .id)][0]`,
+		},
+		{
+			path:   "jsonpath.query(responseJson, '$.items[*].id')[2]",
+			js:     `jsonpath.query(responseJson, "$.items[*].id")[2]`,
+			native: "",
+			jq:     `[.items[]?.id][2]`,
+		},
+		{
+			path:   "jsonpath.query(responseJson, '$.items[*].id')",
+			js:     `jsonpath.query(responseJson, "$.items[*].id")`,
+			native: "",
+			jq:     `[.items[]?.id]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			expr, err := ParseReferencePath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseReferencePath: %v", err)
+			}
+			if got := expr.JSExpression("responseJson"); got != tt.js {
+				t.Errorf("JSExpression = %s, want %s", got, tt.js)
+			}
+			native, err := expr.NativeJSExpression("responseJson")
+			if tt.native == "" {
+				if err == nil {
+					t.Errorf("NativeJSExpression = %s, want an error", native)
+				}
+			} else if err != nil || native != tt.native {
+				t.Errorf("NativeJSExpression = %s, %v; want %s", native, err, tt.native)
+			}
+			if got := expr.JQExpression(); got != tt.jq {
+				t.Errorf("JQExpression = %s, want %s", got, tt.jq)
+			}
+			reparsed, err := ParseReferencePath(expr.JSONPath())
+			if err != nil || !reflect.DeepEqual(reparsed.Steps, expr.Steps) {
+				t.Errorf("JSONPath %s does not parse back to the same steps: %v", expr.JSONPath(), err)
+			}
+		})
+	}
+}
+
+func TestValidateRefinedPath(t *testing.T) {
+	body := `{"travelers": [{"type": "CHD", "id": "t-1"}, {"type": "ADT", "id": "t-2"}], "count": 2}`
+	tests := []struct {
+		path     string
+		expected string
+		want     string
+		wantErr  bool
+	}{
+		{"travelers[1].id", "t-2", "travelers[1].id", false},
+		{"responseJson.count", "2", "responseJson.count", false},
+		{"$.travelers[?(@.type=='ADT')].id", "t-2", "$.travelers[?(@.type=='ADT')].id", false},
+		{`jsonpath.query(responseJson, "$.travelers[?(@.type=='ADT')].id")[0]`, "t-2", "$.travelers[?(@.type=='ADT')].id", false},
+		{"jsonpath.query(responseJson, '$.travelers[?(@.type==\"ADT\")].id')", "t-2", "$.travelers[?(@.type=='ADT')].id", false},
+		{"jsonpath.query(responseJson, '$.travelers[*].id')[1]", "t-2", "jsonpath.query(responseJson, '$.travelers[*].id')[1]", false},
+		{"travelers[0].id", "t-2", "", true},
+		{"jsonpath.query(responseJson, '$.travelers[?(@.type==\"INF\")].id')", "t-2", "", true},
+		{"travelers[", "t-2", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := validateRefinedPath(body, tt.path, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateRefinedPath error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateRefinedPath = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := validateRefinedPath("not json", "a", "b"); err == nil {
+		t.Error("validateRefinedPath accepted a body that is not JSON")
+	}
+}
+
+func TestJSStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"double quotes", jsString("<a href='x'>\"&\"</a>\u2028"), `"<a href='x'>\"&\"</a>\u2028"`},
+		{"single quotes", jsSingleQuote(`it's a\b`), `'it\'s a\\b'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+	for _, s := range []string{trickyText, `{"a": "b\\n"}`, "line\r\nbreak", "\x00\ue000\u2028", ""} {
+		var got string
+		if err := json.Unmarshal([]byte(jsString(s)), &got); err != nil || got != s {
+			t.Errorf("jsString(%q) = %s, which JSON reads as %q (%v)", s, jsString(s), got, err)
+		}
+	}
+}
+
+func ExampleReferenceExpr_NativeJSExpression() {
+	expr, _ := ParseReferencePath("$.travelers[?(@.type=='ADT')].id")
+	js, _ := expr.NativeJSExpression("pm.response.json()")
+	fmt.Println(js)
+	// Output: pm.response.json().travelers.find((e) => e.type === "ADT").id
+}
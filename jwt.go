@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// jwtPattern matches the compact serialization of a JWS: three base64url segments separated by dots.
+var jwtPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+
+// jwtRegisteredClaimNames gives readable names to the registered JWT claims for heuristic naming.
+var jwtRegisteredClaimNames = map[string]string{
+	"sub": "subject",
+	"iss": "issuer",
+	"aud": "audience",
+	"jti": "tokenId",
+	"sid": "sessionId",
+	"exp": "expiresAt",
+	"iat": "issuedAt",
+	"nbf": "notBefore",
+	"kid": "keyId",
+}
+
+// decodeJWT decodes the header and payload of a JWT. It reports false if s is not a JWT,
+// i.e. the segments don't decode to JSON objects or the header lacks an "alg".
+func decodeJWT(s string) (header map[string]interface{}, payload map[string]interface{}, ok bool) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "Bearer "))
+	if !jwtPattern.MatchString(s) {
+		return nil, nil, false
+	}
+	parts := strings.Split(s, ".")
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, nil, false
+	}
+	if _, hasAlg := header["alg"]; !hasAlg {
+		return nil, nil, false
+	}
+	if err := decodeJWTSegment(parts[1], &payload); err != nil {
+		return nil, nil, false
+	}
+	return header, payload, true
+}
+
+// decodeJWTSegment base64url-decodes a JWT segment (with or without padding) and unmarshals it into out.
+func decodeJWTSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// expandJWTs decodes every JWT found among refs and returns its header and payload claims as additional,
+// virtual value references. Their paths look like "jwt(access_token).payload.sub", and they point back
+// to the reference holding the token so that scripts can decode it at runtime.
+func expandJWTs(refs []*ValueReference) []*ValueReference {
+	var claims []*ValueReference
+	for _, ref := range refs {
+		str, ok := ref.Value.(string)
+		if !ok {
+			continue
+		}
+		header, payload, ok := decodeJWT(str)
+		if !ok {
+			continue
+		}
+		for _, part := range []struct {
+			name   string
+			claims map[string]interface{}
+		}{{"header", header}, {"payload", payload}} {
+			for _, claim := range flatten("", nil, part.claims) {
+				claimPath := part.name + "." + claim.ReferencePath
+				claims = append(claims, &ValueReference{
+					Value:          claim.Value,
+					ReferencePath:  fmt.Sprintf("jwt(%s).%s", ref.ReferencePath, claimPath),
+					HeaderName:     ref.HeaderName,
+					CookieName:     ref.CookieName,
+					SourceLocation: ref.SourceLocation,
+					SourceType:     ref.SourceType,
+					Source:         ref.Source,
+					JWTParent:      ref,
+					JWTClaimPath:   claimPath,
+				})
+			}
+		}
+	}
+	return claims
+}
+
+// jwtPartIndex returns the index of the JWT segment that a claim path refers to.
+func jwtPartIndex(claimPath string) int {
+	if strings.HasPrefix(claimPath, "header.") {
+		return 0
+	}
+	return 1
+}
+
+// jwtClaimExpr parses the part of a claim path below "header." or "payload.".
+func jwtClaimExpr(claimPath string) (*ReferenceExpr, error) {
+	_, rest, _ := strings.Cut(claimPath, ".")
+	return ParseReferencePath(rest)
+}
+
+// jwtDecodeFunctionJS is the helper emitted into scripts that read JWT claims.
+var jwtDecodeFunctionJS = []string{
+	"function decodeJwtPart(token, index) {",
+	"  var part = String(token).replace(/^Bearer\\s+/i, '').split('.')[index].replace(/-/g, '+').replace(/_/g, '/');",
+	"  while (part.length % 4) { part += '='; }",
+	"  return JSON.parse(decodeURIComponent(escape(atob(part))));",
+	"}",
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestExpandJWTs(t *testing.T) {
+	segment := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	token := segment(`{"alg":"RS256","kid":"k1"}`) + "." + segment(`{"sub":"user-42","roles":["admin"],"exp":1700000000}`) + ".c2ln"
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  map[string]interface{}
+	}{
+		{
+			name:  "header and payload claims",
+			value: token,
+			want: map[string]interface{}{
+				"jwt(access_token).header.alg":       "RS256",
+				"jwt(access_token).header.kid":       "k1",
+				"jwt(access_token).payload.sub":      "user-42",
+				"jwt(access_token).payload.roles[0]": "admin",
+				"jwt(access_token).payload.exp":      float64(1700000000),
+			},
+		},
+		{
+			name:  "bearer prefix and empty signature",
+			value: "Bearer " + segment(`{"alg":"none"}`) + "." + segment(`{"sid":"s-1"}`) + ".",
+			want: map[string]interface{}{
+				"jwt(access_token).header.alg":  "none",
+				"jwt(access_token).payload.sid": "s-1",
+			},
+		},
+		{name: "header without alg", value: segment(`{"typ":"JWT"}`) + "." + segment(`{"sub":"x"}`) + ".c2ln"},
+		{name: "payload is not JSON", value: segment(`{"alg":"HS256"}`) + "." + segment("plain") + ".c2ln"},
+		{name: "dotted text", value: "version.1.2"},
+		{name: "not a string", value: float64(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := &ValueReference{
+				Value:          tt.value,
+				ReferencePath:  "access_token",
+				SourceLocation: SourceLocationBodyJson,
+				SourceType:     SourceTypeResponse,
+			}
+			claims := expandJWTs([]*ValueReference{parent})
+			got := make(map[string]interface{})
+			for _, claim := range claims {
+				got[claim.ReferencePath] = claim.Value
+				if claim.JWTParent != parent || claim.SourceLocation != parent.SourceLocation || claim.SourceType != parent.SourceType {
+					t.Errorf("claim %s does not point back to its token", claim.ReferencePath)
+				}
+				if want := "jwt(access_token)." + claim.JWTClaimPath; claim.ReferencePath != want {
+					t.Errorf("claim path %s, want %s", claim.ReferencePath, want)
+				}
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandJWTs(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTClaimPath(t *testing.T) {
+	tests := []struct {
+		claimPath string
+		part      int
+		want      string
+	}{
+		{"header.kid", 0, "$.kid"},
+		{"payload.sub", 1, "$.sub"},
+		{"payload.roles[0]", 1, "$.roles[0]"},
+		{"payload.address.city", 1, "$.address.city"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.claimPath, func(t *testing.T) {
+			if got := jwtPartIndex(tt.claimPath); got != tt.part {
+				t.Errorf("jwtPartIndex(%s) = %d, want %d", tt.claimPath, got, tt.part)
+			}
+			expr, err := jwtClaimExpr(tt.claimPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.JSONPath(); got != tt.want {
+				t.Errorf("jwtClaimExpr(%s) = %s, want %s", tt.claimPath, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// k6Exporter writes a k6 load-test script. Each call becomes an http.request, response-chained values are
+// read into local variables, later requests use them in template literals, and the recorded pauses between
+// calls become sleep() calls.
+type k6Exporter struct{}
+
+func (k6Exporter) Name() string          { return "k6" }
+func (k6Exporter) Description() string   { return "k6 load-test script" }
+func (k6Exporter) DefaultOutput() string { return "k6-script.js" }
+func (k6Exporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	script := BuildK6Script(callDetailsList, chainedValues)
+	if err := os.WriteFile(outputPath, []byte(script), 0644); err != nil {
+		return fmt.Errorf("error writing k6 script: %w", err)
+	}
+	return nil
+}
+
+// BuildK6Script renders the calls as a k6 scenario.
+func BuildK6Script(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) string {
+	var body []string
+	var variables []string
+	declared := make(map[string]bool)
+	usesEncoding := false
+
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		request := callDetails.Entry.Request
+		refs := callDetails.RequestChainedValues
+
+		name := callDetails.Name
+		if name == "" {
+			name = request.Method + " " + request.URL
+		}
+		body = append(body, "", "  // "+strings.ReplaceAll(name, "\n", " "))
+
+		var params []string
+		var headers []string
+		for _, header := range requestHeaders(callDetails) {
+			if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") {
+				continue
+			}
+			headers = append(headers, fmt.Sprintf("      %s: %s,", jsString(header.Name), k6Template(header.Value, refs)))
+		}
+		if len(headers) > 0 {
+			params = append(params, "    headers: {")
+			params = append(params, headers...)
+			params = append(params, "    },")
+		}
+		params = append(params, fmt.Sprintf("    tags: { name: %s },", jsString(name)))
+
+		requestBody := "null"
+		if request.PostData != nil && request.PostData.Text != "" {
+			if request.PostData.MimeType == "application/x-www-form-urlencoded" {
+				// k6 encodes object bodies as forms, so the chained values are substituted in the decoded values.
+				var fields []string
+				for _, pair := range decodeFormBody(request.PostData.Text) {
+					fields = append(fields, fmt.Sprintf("%s: %s", jsString(pair[0]), k6Template(pair[1], refs)))
+				}
+				requestBody = "{ " + strings.Join(fields, ", ") + " }"
+			} else {
+				requestBody = k6Template(request.PostData.Text, refs)
+			}
+		}
+
+		body = append(body, fmt.Sprintf("  res = http.request(%s, %s, %s, {", jsString(request.Method), k6Template(request.URL, refs), requestBody))
+		body = append(body, params...)
+		body = append(body, "  });")
+		if status := callDetails.Entry.Response.Status; status != 0 {
+			body = append(body, fmt.Sprintf("  check(res, { %s: (r) => r.status === %d });", jsString(fmt.Sprintf("%s: status %d", name, status)), status))
+		}
+
+		for _, ref := range callDetails.ResponseChainedValues {
+			if ref.SourceType != SourceTypeResponse || ref.Context == nil || declared[ref.Context.VariableName] {
+				continue
+			}
+			variableName := ref.Context.VariableName
+			declared[variableName] = true
+			variables = append(variables, variableName)
+			expr, err := k6Expression(ref)
+			if err != nil {
+				log.Printf("k6: %s keeps its recorded value because %s cannot be read: %v", variableName, ref.ReferencePath, err)
+				expr = jsString(ref.Context.Value)
+			}
+			body = append(body, fmt.Sprintf("  %s = %s;", variableName, expr))
+		}
+		for _, ref := range refs {
+			if ref.Context != nil && (ref.Transform == "base64" || ref.Transform == "base64url") {
+				usesEncoding = true
+			}
+		}
+
+		if i+1 < len(callDetailsList) && callDetailsList[i+1] != nil {
+			if pause := thinkTime(callDetails.Entry, callDetailsList[i+1].Entry); pause >= minThinkTime {
+				body = append(body, fmt.Sprintf("  sleep(%s);", strconv.FormatFloat(pause.Seconds(), 'f', -1, 64)))
+			}
+		}
+	}
+
+	var lines []string
+	lines = append(lines, `import http from "k6/http";`, `import { check, sleep } from "k6";`)
+	if usesEncoding || k6UsesJWT(callDetailsList) {
+		lines = append(lines, `import encoding from "k6/encoding";`)
+	}
+	lines = append(lines, "", "export const options = {", "  vus: 1,", "  iterations: 1,", "};")
+
+	var predefined []string
+	for _, cv := range chainedValues {
+		if cv.ExternalSource {
+			if cv.InitScript != "" {
+				log.Printf("k6: the initializer of %s is not run; pass it with -e %s=...", cv.VariableName, cv.VariableName)
+			}
+			predefined = append(predefined, fmt.Sprintf("const %s = __ENV.%s || %s;", cv.VariableName, cv.VariableName, jsString(cv.Value)))
+		}
+	}
+	if len(predefined) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, predefined...)
+	}
+	if k6UsesJWT(callDetailsList) {
+		lines = append(lines, "",
+			"function decodeJwtPart(token, index) {",
+			`  return JSON.parse(encoding.b64decode(String(token).replace(/^Bearer\s+/i, "").split(".")[index], "rawurl", "s"));`,
+			"}")
+	}
+
+	lines = append(lines, "", "export default function () {", "  let res;")
+	if len(variables) > 0 {
+		lines = append(lines, fmt.Sprintf("  let %s;", strings.Join(variables, ", ")))
+	}
+	lines = append(lines, body...)
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// k6Expression returns the JavaScript expression reading a response value from the k6 response res.
+// Body values use res.json() with a GJSON selector where possible, and plain JavaScript otherwise.
+func k6Expression(ref *ValueReference) (string, error) {
+	switch {
+	case ref.JWTParent != nil:
+		token, err := k6Expression(ref.JWTParent)
+		if err != nil {
+			return "", err
+		}
+		expr, err := jwtClaimExpr(ref.JWTClaimPath)
+		if err != nil {
+			return "", err
+		}
+		return expr.NativeJSExpression(fmt.Sprintf("decodeJwtPart(%s, %d)", token, jwtPartIndex(ref.JWTClaimPath)))
+	case ref.SourceLocation == SourceLocationCookie:
+		return fmt.Sprintf("res.cookies[%s][0].value", jsString(ref.CookieName)), nil
+	case ref.SourceLocation == SourceLocationHeader:
+		return fmt.Sprintf("res.headers[%s]", jsString(http.CanonicalHeaderKey(ref.HeaderName))), nil
+	}
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		return "", err
+	}
+	if selector, ok := gjsonSelector(expr); ok {
+		return fmt.Sprintf("res.json(%s)", jsString(selector)), nil
+	}
+	return expr.NativeJSExpression("res.json()")
+}
+
+// gjsonSelector renders an expression as a GJSON path, the syntax of k6's res.json(). Filters become
+// "#(key==value)" queries, which select the first match. It reports false for expressions GJSON cannot
+// express: wildcards, recursive descent, filters with several conditions or without a comparison.
+func gjsonSelector(expr *ReferenceExpr) (string, bool) {
+	if expr.Query && expr.QueryIndex != 0 {
+		return "", false
+	}
+	var parts []string
+	for _, st := range expr.Steps {
+		switch st.kind {
+		case stepKey:
+			parts = append(parts, gjsonEscape(st.key))
+		case stepIndex:
+			parts = append(parts, strconv.Itoa(st.index))
+		case stepFilter:
+			if len(st.filter) != 1 || st.filter[0].op == "" {
+				return "", false
+			}
+			cond := st.filter[0]
+			var keys []string
+			for _, key := range cond.path {
+				keys = append(keys, gjsonEscape(key))
+			}
+			parts = append(parts, fmt.Sprintf("#(%s%s%s)", strings.Join(keys, "."), cond.op, jsLiteral(cond.value)))
+		default:
+			return "", false
+		}
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return strings.Join(parts, "."), true
+}
+
+// gjsonEscape escapes the characters that have a meaning in GJSON paths.
+func gjsonEscape(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`.*?|#@\!=<>%`, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// k6Template renders a recorded string as a JavaScript template literal with the chained values
+// interpolated as variables, applying the encoding each usage needs.
+func k6Template(s string, refs []*ValueReference) string {
+	var sb strings.Builder
+	sb.WriteString("`")
+	for _, segment := range splitChainedValues(s, refs) {
+		if segment.ref == nil {
+			sb.WriteString(strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${").Replace(segment.literal))
+			continue
+		}
+		sb.WriteString("${" + k6Transform(segment.ref.Transform, segment.ref.Context.VariableName) + "}")
+	}
+	sb.WriteString("`")
+	return sb.String()
+}
+
+// k6Transform applies a value transform to the expression expr. k6 has no btoa, so base64 uses k6/encoding.
+func k6Transform(transform string, expr string) string {
+	switch transform {
+	case "base64":
+		return fmt.Sprintf("encoding.b64encode(%s)", expr)
+	case "base64url":
+		return fmt.Sprintf("encoding.b64encode(%s, \"rawurl\")", expr)
+	}
+	if t := lookupTransform(transform); t != nil {
+		return t.JSExpression(expr)
+	}
+	return expr
+}
+
+// k6UsesJWT reports whether any response-chained value is a JWT claim, requiring the decoder function.
+func k6UsesJWT(callDetailsList []*CallDetails) bool {
+	for _, callDetails := range callDetailsList {
+		if callDetails != nil && needsJWTDecoder(callDetails.ResponseChainedValues) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestK6Strings(t *testing.T) {
+	refs := []*ValueReference{{Value: "abc123", Context: &ChainedValueContext{VariableName: "token"}}}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"template", k6Template(trickyText, refs), "`a'b\"c $HOME \\`id\\` \\\\ \\${x}\n\t${token}`"},
+		{"gjson key", gjsonEscape("a.b*c?#@"), `a\.b\*c\?\#\@`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildK6Script(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	script := BuildK6Script(callDetailsList, chainedValues)
+	for _, want := range []string{
+		"let dataToken, orderId;",
+		`dataToken = res.json("data.token");`,
+		`"Authorization": ` + "`Bearer ${dataToken}`",
+		`orderId = res.json("order.id");`,
+		"`https://api.example.com/v1/orders/${orderId}`",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("missing %q in:\n%s", want, script)
+		}
+	}
+}
+
+func TestBuildK6ScriptUnreadablePath(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	callDetailsList[1].ResponseChainedValues[0].ReferencePath = "order["
+	logged := captureLog(t)
+
+	script := BuildK6Script(callDetailsList, chainedValues)
+	if want := `orderId = "ord55123";`; !strings.Contains(script, want) {
+		t.Errorf("missing the recorded value %q in:\n%s", want, script)
+	}
+	if !strings.Contains(logged.String(), "k6: orderId keeps its recorded value") {
+		t.Errorf("fallback to the recorded value was not logged: %q", logged.String())
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// LLMMessage is a single provider-neutral chat message.
+type LLMMessage struct {
+	Role    string
+	Content string
+}
+
+// LLMProvider abstracts a chat-completion style language model API.
+// Implementations translate the provider-neutral messages into their own wire format
+// and return the raw text content of the model's reply.
+type LLMProvider interface {
+	// Name returns the short identifier of the provider (e.g. "openai").
+	Name() string
+
+	// Model returns the model (or deployment) that requests are sent to.
+	Model() string
+
+	// Endpoint returns the URL that requests are sent to. For Azure it includes the deployment.
+	Endpoint() string
+
+	// Complete sends the messages to the model and returns the text of its reply.
+	// A single call makes a single request; retries are handled by the caller.
+	Complete(ctx context.Context, messages []LLMMessage) (string, error)
+}
+
+// Supported values for the -llm flag.
+const (
+	LLMProviderOpenAI    = "openai"
+	LLMProviderAzure     = "azure"
+	LLMProviderAnthropic = "anthropic"
+	LLMProviderLocal     = "local"
+)
+
+// LLMConfig holds the settings used to construct an LLMProvider.
+// Empty fields are filled in with provider-specific defaults.
+type LLMConfig struct {
+	// Provider is one of the LLMProvider* constants.
+	Provider string
+
+	// Model is the model name, or the deployment name for Azure OpenAI.
+	Model string
+
+	// BaseURL overrides the API endpoint. For Azure it is the resource endpoint,
+	// for local servers it is the OpenAI-compatible base URL (e.g. http://localhost:11434/v1).
+	BaseURL string
+
+	// APIVersion is the Azure OpenAI API version.
+	APIVersion string
+
+	// APIKey overrides the key otherwise read from the environment. It is the only key sent to local servers.
+	APIKey string
+}
+
+// llmRetryPolicy governs every LLM request: transient failures (rate limits, 5xx, timeouts and
+// unparsable model output) are retried with exponential backoff, sharing one budget per run.
+var llmRetryPolicy = RetryPolicy{
+	MaxAttempts:   4,
+	InitialDelay:  time.Second,
+	MaxDelay:      30 * time.Second,
+	Multiplier:    2,
+	Jitter:        0.2,
+	MaxRetryAfter: 2 * time.Minute,
+	Budget:        NewRetryBudget(20),
+}
+
+// llmHTTPClient is shared by the providers. The timeout turns hung requests into retryable errors.
+var llmHTTPClient = &http.Client{Timeout: 2 * time.Minute}
+
+// llmProvider is the provider used by callOpenAIBase. It is configured once at startup by configureLLM.
+var llmProvider LLMProvider
+
+// configureLLM builds the provider described by cfg and makes it the active provider.
+func configureLLM(cfg LLMConfig) error {
+	provider, err := NewLLMProvider(cfg)
+	if err != nil {
+		return err
+	}
+	llmProvider = provider
+	return nil
+}
+
+// activeLLMProvider returns the configured provider, defaulting to OpenAI.
+func activeLLMProvider() LLMProvider {
+	if llmProvider == nil {
+		provider, _ := NewLLMProvider(LLMConfig{Provider: LLMProviderOpenAI})
+		llmProvider = provider
+	}
+	return llmProvider
+}
+
+// NewLLMProvider constructs the provider selected by cfg.Provider.
+// API keys are resolved lazily so that a missing key surfaces as an error from Complete,
+// allowing callers to fall back to non-AI behavior.
+func NewLLMProvider(cfg LLMConfig) (LLMProvider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", LLMProviderOpenAI:
+		return &openAIProvider{
+			name:      LLMProviderOpenAI,
+			endpoint:  strings.TrimSuffix(firstNonEmpty(cfg.BaseURL, "https://api.openai.com/v1"), "/") + "/chat/completions",
+			model:     firstNonEmpty(cfg.Model, "gpt-4o-mini"),
+			apiKey:    cfg.APIKey,
+			apiKeyEnv: "OPENAI_API_KEY",
+		}, nil
+	case LLMProviderAzure:
+		endpoint := firstNonEmpty(cfg.BaseURL, os.Getenv("AZURE_OPENAI_ENDPOINT"))
+		if endpoint == "" {
+			return nil, fmt.Errorf("azure provider requires -llm-url or AZURE_OPENAI_ENDPOINT")
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("azure provider requires -llm-model to name the deployment")
+		}
+		apiVersion := firstNonEmpty(cfg.APIVersion, "2024-06-01")
+		return &openAIProvider{
+			name: LLMProviderAzure,
+			endpoint: fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+				strings.TrimSuffix(endpoint, "/"), cfg.Model, apiVersion),
+			model:      cfg.Model,
+			apiKey:     cfg.APIKey,
+			apiKeyEnv:  "AZURE_OPENAI_API_KEY",
+			authHeader: "api-key",
+		}, nil
+	case LLMProviderAnthropic:
+		return &anthropicProvider{
+			endpoint:  strings.TrimSuffix(firstNonEmpty(cfg.BaseURL, "https://api.anthropic.com/v1"), "/") + "/messages",
+			model:     firstNonEmpty(cfg.Model, "claude-3-5-haiku-latest"),
+			apiKey:    cfg.APIKey,
+			maxTokens: 8192,
+		}, nil
+	case LLMProviderLocal:
+		return &openAIProvider{
+			name:           LLMProviderLocal,
+			endpoint:       strings.TrimSuffix(firstNonEmpty(cfg.BaseURL, "http://localhost:11434/v1"), "/") + "/chat/completions",
+			model:          firstNonEmpty(cfg.Model, "llama3.1"),
+			apiKey:         cfg.APIKey,
+			apiKeyOptional: true,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q (expected openai, azure, anthropic or local)", cfg.Provider)
+	}
+}
+
+// resolveAPIKey returns the explicit key if set, otherwise CHAINER_LLM_API_KEY, otherwise the value of the
+// provider's environment variable. Providers without one, such as local servers, pass an empty envVar and
+// only get the explicit key, so that a key meant for a hosted service is never sent to them.
+func resolveAPIKey(explicit string, envVar string) string {
+	if explicit != "" || envVar == "" {
+		return explicit
+	}
+	if key := os.Getenv("CHAINER_LLM_API_KEY"); key != "" {
+		return key
+	}
+	return os.Getenv(envVar)
+}
+
+// postLLMRequest marshals body, POSTs it to endpoint with the given headers and
+// unmarshals the JSON reply into out. Non-2xx responses are returned as *HTTPStatusError.
+func postLLMRequest(ctx context.Context, endpoint string, headers map[string]string, body interface{}, out interface{}) error {
+	reqBodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshalling request body: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBodyJSON))
+	if err != nil {
+		return markPermanent(fmt.Errorf("error creating request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+	}(resp.Body)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPStatusError(resp, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	return nil
+}
+
+// firstNonEmpty returns the first non-empty string from values.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Supported values for the -cache flag.
+const (
+	// CacheModeOn reads cached responses and stores new ones.
+	CacheModeOn = "on"
+
+	// CacheModeOff bypasses the cache entirely.
+	CacheModeOff = "off"
+
+	// CacheModeRefresh ignores cached responses but stores the new ones, replacing stale entries.
+	CacheModeRefresh = "refresh"
+)
+
+// LLMCacheEntry is the on-disk representation of a cached LLM response.
+type LLMCacheEntry struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   string    `json:"content"`
+}
+
+// llmCache is a content-addressed store of LLM responses, one JSON file per request hash.
+type llmCache struct {
+	dir  string
+	mode string
+}
+
+// responseCache is the cache consulted by callOpenAIBase. Nil disables caching.
+var responseCache *llmCache
+
+// configureLLMCache sets up the response cache in dir, first pruning entries older than pruneAge
+// when pruneAge is positive.
+func configureLLMCache(dir string, mode string, pruneAge time.Duration) error {
+	switch mode {
+	case CacheModeOn, CacheModeRefresh:
+	case CacheModeOff:
+		responseCache = nil
+		return nil
+	default:
+		return fmt.Errorf("invalid -cache value %q (expected %s, %s or %s)", mode, CacheModeOn, CacheModeOff, CacheModeRefresh)
+	}
+
+	if dir == "" {
+		dir = defaultLLMCacheDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	if pruneAge > 0 {
+		removed, err := pruneLLMCache(dir, pruneAge)
+		if err != nil {
+			return fmt.Errorf("error pruning cache: %w", err)
+		}
+		log.Printf("Pruned %d cached LLM responses older than %s", removed, pruneAge)
+	}
+
+	responseCache = &llmCache{dir: dir, mode: mode}
+	return nil
+}
+
+// defaultLLMCacheDir returns the per-user cache location, falling back to a local directory.
+func defaultLLMCacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(".chainer-cache", "llm")
+	}
+	return filepath.Join(base, "chainer", "llm")
+}
+
+// llmCacheKey hashes everything that determines the model's answer: the provider, model, endpoint, prompt
+// and input. The endpoint tells apart servers, such as two local servers or Azure deployments, that
+// serve models of the same name.
+func llmCacheKey(provider LLMProvider, prompt string, input []byte) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(provider.Name()), []byte(provider.Model()), []byte(provider.Endpoint()), []byte(prompt), input} {
+		// Length-prefix each part so that different splits cannot produce the same hash.
+		_, _ = fmt.Fprintf(h, "%d:", len(part))
+		_, _ = h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *llmCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get returns the cached content for key. In refresh mode it always misses.
+func (c *llmCache) get(key string) (string, bool) {
+	if c.mode == CacheModeRefresh {
+		return "", false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	var entry LLMCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("Ignoring corrupt cache entry %s: %v", key, err)
+		return "", false
+	}
+	// Touch the entry so pruning removes the least recently used responses first.
+	now := time.Now()
+	_ = os.Chtimes(c.path(key), now, now)
+	return entry.Content, true
+}
+
+// put stores content under key, writing through a temporary file so readers never see partial entries.
+func (c *llmCache) put(key string, provider LLMProvider, content string) error {
+	data, err := json.MarshalIndent(LLMCacheEntry{
+		Provider:  provider.Name(),
+		Model:     provider.Model(),
+		CreatedAt: time.Now().UTC(),
+		Content:   content,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// forget removes the entry for key, e.g. when its content could not be decoded.
+func (c *llmCache) forget(key string) {
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error removing cache entry %s: %v", key, err)
+	}
+}
+
+// pruneLLMCache deletes cache entries that have not been used for longer than maxAge
+// and returns how many were removed.
+func pruneLLMCache(dir string, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, e := range entries {
+		if e.IsDir() || !(strings.HasSuffix(e.Name(), ".json") || strings.HasSuffix(e.Name(), ".tmp")) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
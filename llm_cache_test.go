@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingProvider answers every request with the same reply and counts the requests.
+type countingProvider struct {
+	openAIProvider
+	calls int
+}
+
+func (p *countingProvider) Complete(ctx context.Context, messages []LLMMessage) (string, error) {
+	p.calls++
+	return "reply", nil
+}
+
+func TestLLMCacheKey(t *testing.T) {
+	base := &openAIProvider{name: LLMProviderLocal, endpoint: "http://localhost:11434/v1/chat/completions", model: "llama3.1"}
+	key := llmCacheKey(base, "prompt", []byte("input"))
+	tests := []struct {
+		name     string
+		provider *openAIProvider
+		prompt   string
+		input    string
+		same     bool
+	}{
+		{"identical request", base, "prompt", "input", true},
+		{"model", &openAIProvider{name: LLMProviderLocal, endpoint: base.endpoint, model: "qwen2.5"}, "prompt", "input", false},
+		{"endpoint", &openAIProvider{name: LLMProviderLocal, endpoint: "http://localhost:8080/v1/chat/completions", model: "llama3.1"}, "prompt", "input", false},
+		{"provider", &openAIProvider{name: LLMProviderOpenAI, endpoint: base.endpoint, model: "llama3.1"}, "prompt", "input", false},
+		{"prompt", base, "other prompt", "input", false},
+		{"input", base, "prompt", "other input", false},
+		{"prompt and input split differently", base, "promptin", "put", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := llmCacheKey(tt.provider, tt.prompt, []byte(tt.input)); (got == key) != tt.same {
+				t.Errorf("key equal to the base key: %v, want %v", got == key, tt.same)
+			}
+		})
+	}
+
+	azure := func(deployment string) LLMProvider {
+		p, err := NewLLMProvider(LLMConfig{Provider: LLMProviderAzure, BaseURL: "https://example.openai.azure.com", Model: deployment})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	if llmCacheKey(azure("gpt-4o"), "p", nil) == llmCacheKey(azure("gpt-4o-eu"), "p", nil) {
+		t.Error("two Azure deployments share a cache key")
+	}
+}
+
+func TestLLMCacheModes(t *testing.T) {
+	tests := []struct {
+		mode      string
+		wantCalls int
+		wantFiles int
+	}{
+		{CacheModeOn, 1, 1},
+		{CacheModeRefresh, 2, 1},
+		{CacheModeOff, 2, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "cache")
+			provider := useTestLLM(t)
+			if err := configureLLMCache(dir, tt.mode, 0); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if got, err := CallOpenAIString(context.Background(), "name this", map[string]string{"a": "b"}); err != nil || got != "reply" {
+					t.Fatalf("CallOpenAIString = %q, %v", got, err)
+				}
+			}
+			if provider.calls != tt.wantCalls {
+				t.Errorf("%d requests to the model, want %d", provider.calls, tt.wantCalls)
+			}
+			entries, err := os.ReadDir(dir)
+			if tt.wantFiles < 0 {
+				if !os.IsNotExist(err) {
+					t.Errorf("cache directory was created in off mode: %v", err)
+				}
+				return
+			}
+			if err != nil || len(entries) != tt.wantFiles {
+				t.Errorf("cache holds %d files (%v), want %d", len(entries), err, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestLLMCacheCorruptEntryMisses(t *testing.T) {
+	dir := t.TempDir()
+	provider := useTestLLM(t)
+	if err := configureLLMCache(dir, CacheModeOn, 0); err != nil {
+		t.Fatal(err)
+	}
+	key := llmCacheKey(provider, "p", []byte(`"x"`))
+	if err := os.WriteFile(responseCache.path(key), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := responseCache.get(key); ok {
+		t.Error("corrupt entry was returned")
+	}
+	if _, err := CallOpenAIString(context.Background(), "p", "x"); err != nil || provider.calls != 1 {
+		t.Errorf("CallOpenAIString = %v with %d requests, want one request", err, provider.calls)
+	}
+	if content, ok := responseCache.get(key); !ok || content != "reply" {
+		t.Errorf("entry was not replaced: %q, %v", content, ok)
+	}
+}
+
+func TestLLMCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for name, modTime := range map[string]time.Time{
+		"old.json":        old,
+		"old.123.tmp":     old,
+		"recent.json":     time.Now(),
+		"notes.txt":       old,
+		"sub/nested.json": old,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useTestLLM(t)
+	if err := configureLLMCache(dir, CacheModeOn, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"old.json": false, "old.123.tmp": false, "recent.json": true, "notes.txt": true, "sub/nested.json": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s exists: %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+// useTestLLM makes a countingProvider the active provider and restores the provider, the cache
+// and the log output when the test ends.
+func useTestLLM(t *testing.T) *countingProvider {
+	t.Helper()
+	provider := &countingProvider{openAIProvider: openAIProvider{name: LLMProviderLocal, endpoint: "http://127.0.0.1:1/v1/chat/completions", model: "test"}}
+	previousProvider, previousCache, previousLog := llmProvider, responseCache, log.Writer()
+	llmProvider = provider
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		llmProvider, responseCache = previousProvider, previousCache
+		log.SetOutput(previousLog)
+	})
+	return provider
+}
+
+func TestCheckHarFlagsCacheMode(t *testing.T) {
+	for mode, valid := range map[string]bool{CacheModeOn: true, CacheModeOff: true, CacheModeRefresh: true, "bogus": false, "": false} {
+		// The cache mode is checked whatever the naming mode, although only AI naming uses the cache.
+		f := flags{harFilePath: "flow.har", namingMode: NamingModeHeuristic, cookieMode: CookieModeVars, cacheMode: mode}
+		if err := f.checkHarFlags(); (err == nil) != valid {
+			t.Errorf("checkHarFlags with -cache=%q = %v, want valid %v", mode, err, valid)
+		}
+	}
+}
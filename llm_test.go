@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolveAPIKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit string
+		chainer  string
+		provider string
+		envVar   string
+		want     string
+	}{
+		{"explicit wins", "explicit", "chainer", "provider", "OPENAI_API_KEY", "explicit"},
+		{"chainer key", "", "chainer", "provider", "OPENAI_API_KEY", "chainer"},
+		{"provider key", "", "", "provider", "OPENAI_API_KEY", "provider"},
+		{"no provider variable", "", "", "provider", "", ""},
+		{"no provider variable with chainer key", "", "chainer", "provider", "", ""},
+		{"no provider variable with explicit key", "explicit", "chainer", "provider", "", "explicit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAINER_LLM_API_KEY", tt.chainer)
+			t.Setenv("OPENAI_API_KEY", tt.provider)
+			if got := resolveAPIKey(tt.explicit, tt.envVar); got != tt.want {
+				t.Errorf("resolveAPIKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalProviderIgnoresOpenAIKey(t *testing.T) {
+	t.Setenv("CHAINER_LLM_API_KEY", "chainer")
+	t.Setenv("OPENAI_API_KEY", "sk-hosted")
+	provider, err := NewLLMProvider(LLMConfig{Provider: LLMProviderLocal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := provider.(*openAIProvider)
+	if key := resolveAPIKey(p.apiKey, p.apiKeyEnv); key != "" {
+		t.Errorf("local provider would send key %q", key)
+	}
+}
+
+// recordedLLMRequest is what a test server received from a provider.
+type recordedLLMRequest struct {
+	path   string
+	query  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// llmTestServer serves reply to every request and records the requests.
+func llmTestServer(t *testing.T, reply string) (*httptest.Server, *[]recordedLLMRequest) {
+	t.Helper()
+	var requests []recordedLLMRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		requests = append(requests, recordedLLMRequest{path: r.URL.Path, query: r.URL.RawQuery, header: r.Header.Clone(), body: body})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestLLMProviderRequests(t *testing.T) {
+	messages := []LLMMessage{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "prompt"},
+		{Role: "user", Content: `{"a": 1}`},
+	}
+	openAIReply := `{"choices": [{"message": {"role": "assistant", "content": "answer"}}, {"message": {"content": "other"}}]}`
+	anthropicReply := `{"content": [{"type": "text", "text": "ans"}, {"type": "tool_use"}, {"type": "text", "text": "wer"}]}`
+
+	tests := []struct {
+		name        string
+		config      func(url string) LLMConfig
+		env         map[string]string
+		reply       string
+		wantPath    string
+		wantQuery   string
+		wantHeaders map[string]string
+		check       func(t *testing.T, body map[string]interface{})
+	}{
+		{
+			name:        "openai",
+			config:      func(url string) LLMConfig { return LLMConfig{Provider: LLMProviderOpenAI, BaseURL: url + "/v1"} },
+			env:         map[string]string{"OPENAI_API_KEY": "sk-openai"},
+			reply:       openAIReply,
+			wantPath:    "/v1/chat/completions",
+			wantHeaders: map[string]string{"Authorization": "Bearer sk-openai", "Content-Type": "application/json"},
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["model"] != "gpt-4o-mini" || len(body["messages"].([]interface{})) != 3 {
+					t.Errorf("body = %v, want the default model and the three messages", body)
+				}
+			},
+		},
+		{
+			name: "azure",
+			config: func(url string) LLMConfig {
+				return LLMConfig{Provider: LLMProviderAzure, BaseURL: url + "/", Model: "flows-gpt4o", APIVersion: "2024-10-21"}
+			},
+			env:         map[string]string{"AZURE_OPENAI_API_KEY": "az-key"},
+			reply:       openAIReply,
+			wantPath:    "/openai/deployments/flows-gpt4o/chat/completions",
+			wantQuery:   "api-version=2024-10-21",
+			wantHeaders: map[string]string{"Api-Key": "az-key", "Authorization": ""},
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["model"] != "flows-gpt4o" {
+					t.Errorf("model = %v, want the deployment", body["model"])
+				}
+			},
+		},
+		{
+			name: "anthropic",
+			config: func(url string) LLMConfig {
+				return LLMConfig{Provider: LLMProviderAnthropic, BaseURL: url + "/v1", Model: "claude-test"}
+			},
+			env:         map[string]string{"ANTHROPIC_API_KEY": "ant-key"},
+			reply:       anthropicReply,
+			wantPath:    "/v1/messages",
+			wantHeaders: map[string]string{"X-Api-Key": "ant-key", "Anthropic-Version": "2023-06-01", "Authorization": ""},
+			check: func(t *testing.T, body map[string]interface{}) {
+				want := map[string]interface{}{
+					"model":      "claude-test",
+					"max_tokens": float64(8192),
+					"system":     "be brief",
+					"messages":   []interface{}{map[string]interface{}{"role": "user", "content": "prompt\n\n{\"a\": 1}"}},
+				}
+				if !reflect.DeepEqual(body, want) {
+					t.Errorf("body = %v, want %v", body, want)
+				}
+			},
+		},
+		{
+			name:        "local ignores the environment",
+			config:      func(url string) LLMConfig { return LLMConfig{Provider: LLMProviderLocal, BaseURL: url + "/v1"} },
+			env:         map[string]string{"CHAINER_LLM_API_KEY": "generic-secret", "OPENAI_API_KEY": "sk-openai"},
+			reply:       openAIReply,
+			wantPath:    "/v1/chat/completions",
+			wantHeaders: map[string]string{"Authorization": ""},
+		},
+		{
+			name: "local with an explicit key",
+			config: func(url string) LLMConfig {
+				return LLMConfig{Provider: LLMProviderLocal, BaseURL: url + "/v1", APIKey: "local-key"}
+			},
+			env:         map[string]string{"CHAINER_LLM_API_KEY": "generic-secret"},
+			reply:       openAIReply,
+			wantPath:    "/v1/chat/completions",
+			wantHeaders: map[string]string{"Authorization": "Bearer local-key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CHAINER_LLM_API_KEY", "OPENAI_API_KEY", "AZURE_OPENAI_API_KEY", "ANTHROPIC_API_KEY"} {
+				t.Setenv(name, tt.env[name])
+			}
+			server, requests := llmTestServer(t, tt.reply)
+			provider, err := NewLLMProvider(tt.config(server.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := provider.Complete(context.Background(), messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "answer" {
+				t.Errorf("Complete = %q, want %q", got, "answer")
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("%d requests, want 1", len(*requests))
+			}
+			req := (*requests)[0]
+			if req.path != tt.wantPath || req.query != tt.wantQuery {
+				t.Errorf("request to %s?%s, want %s?%s", req.path, req.query, tt.wantPath, tt.wantQuery)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := req.header.Get(name); got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, req.body)
+			}
+		})
+	}
+}
+
+func TestLLMProviderErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		reply     string
+		wantError string
+	}{
+		{"openai without choices", LLMProviderOpenAI, `{"choices": []}`, "no choices returned from openai"},
+		{"anthropic without text", LLMProviderAnthropic, `{"content": [{"type": "tool_use"}]}`, "no text content returned from Anthropic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAINER_LLM_API_KEY", "key")
+			server, _ := llmTestServer(t, tt.reply)
+			provider, err := NewLLMProvider(LLMConfig{Provider: tt.provider, BaseURL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := provider.Complete(context.Background(), []LLMMessage{{Role: "user", Content: "x"}}); err == nil || err.Error() != tt.wantError {
+				t.Errorf("Complete error = %v, want %q", err, tt.wantError)
+			}
+		})
+	}
+
+	t.Run("missing key is permanent", func(t *testing.T) {
+		t.Setenv("CHAINER_LLM_API_KEY", "")
+		t.Setenv("ANTHROPIC_API_KEY", "")
+		provider, _ := NewLLMProvider(LLMConfig{Provider: LLMProviderAnthropic, BaseURL: "http://127.0.0.1:1"})
+		if _, err := provider.Complete(context.Background(), nil); err == nil || classifyError(err) != RetryDecisionStop {
+			t.Errorf("Complete error = %v, want a permanent error", err)
+		}
+	})
+
+	t.Run("status error", func(t *testing.T) {
+		t.Setenv("CHAINER_LLM_API_KEY", "key")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+		provider, _ := NewLLMProvider(LLMConfig{Provider: LLMProviderOpenAI, BaseURL: server.URL})
+		_, err := provider.Complete(context.Background(), nil)
+		var statusErr *HTTPStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != 429 || statusErr.RetryAfter != 7*time.Second || statusErr.Body != "slow down" {
+			t.Errorf("Complete error = %#v, want a 429 HTTPStatusError retrying after 7s", err)
+		}
+	})
+}
+
+func TestLLMAPIKeyFlag(t *testing.T) {
+	var f flags
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	f.registerHarFlags(fs)
+	if err := fs.Parse([]string{"-llm", "local", "-llm-api-key", "local-key"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.llm.Provider != LLMProviderLocal || f.llm.APIKey != "local-key" {
+		t.Errorf("llm config = %+v, want the local provider with the key", f.llm)
+	}
+}
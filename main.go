@@ -1,21 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 )
 
 // flags holds the parsed command-line flag values.
 type flags struct {
 	harFilePath  string
+	analysisPath string
 	varsFilePath string
 	outputPath   string
+	namingMode   string
+	format       string
+	cookieMode   string
+	goPackage    string
+	llm          LLMConfig
+	cacheMode    string
+	cacheDir     string
+	cachePrune   time.Duration
+	quiet        bool
 }
 
 type varsInput struct {
@@ -24,50 +35,165 @@ type varsInput struct {
 	InitializerPrompt string `json:"initializer,omitempty"`
 }
 
-func main() {
-	if err := run(); err != nil {
-		log.Fatalf("Error: %v", err)
+// runConvert analyzes a HAR file and writes the result in the selected format in one go.
+func runConvert(ctx context.Context, cmd *command, args []string) error {
+	var f flags
+	fs := cmd.flagSet(&f)
+	f.registerHarFlags(fs)
+	f.registerOutputFlags(fs)
+	if err := cmd.parse(fs, args, &f, f.checkHarFlags, f.checkOutputFlags); err != nil {
+		return err
+	}
+
+	callDetailsList, chainedValues, dropped, err := analyzeHar(ctx, f)
+	if err != nil {
+		return err
 	}
+	return export(f.format, f.outputPath, callDetailsList, chainedValues, dropped)
 }
 
-func run() error {
-	// Parse command-line flags.
-	f, err := parseFlags()
+// runAnalyze analyzes a HAR file and writes the editable analysis file that runRender exports.
+func runAnalyze(ctx context.Context, cmd *command, args []string) error {
+	var f flags
+	fs := cmd.flagSet(&f)
+	f.registerHarFlags(fs)
+	fs.StringVar(&f.outputPath, "output", "analysis.json", "Path of the analysis file, written as JSON whatever its extension")
+	if err := cmd.parse(fs, args, &f, f.checkHarFlags); err != nil {
+		return err
+	}
+
+	callDetailsList, chainedValues, dropped, err := analyzeHar(ctx, f)
 	if err != nil {
 		return err
 	}
+	if err := writeAnalysis(f.outputPath, buildAnalysis(callDetailsList, chainedValues, dropped)); err != nil {
+		return err
+	}
+	fmt.Printf("Analysis written to %s.\n", f.outputPath)
+	return nil
+}
+
+// runRender reads an analysis file, possibly edited by hand, and writes it in the selected format.
+func runRender(_ context.Context, cmd *command, args []string) error {
+	var f flags
+	fs := cmd.flagSet(&f)
+	fs.StringVar(&f.analysisPath, "file", "", "Path to the analysis file written by the analyze command, or - to read it from standard input")
+	f.registerOutputFlags(fs)
+	if err := cmd.parse(fs, args, &f, f.checkAnalysisFlag, f.checkOutputFlags); err != nil {
+		return err
+	}
+
+	callDetailsList, chainedValues, dropped, err := loadAnalysis(f.analysisPath)
+	if err != nil {
+		return err
+	}
+	return export(f.format, f.outputPath, callDetailsList, chainedValues, dropped)
+}
+
+// loadAnalysis reads an analysis file and restores its calls and chained values, and the dropped values
+// shown in reports.
+func loadAnalysis(path string) ([]*CallDetails, []*ChainedValueContext, []*DroppedValue, error) {
+	analysis, err := readAnalysis(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return restoreAnalysis(analysis, path)
+}
+
+// restoreAnalysis restores the calls and chained values of an analysis file read from path, and the dropped
+// values shown in reports.
+func restoreAnalysis(analysis *Analysis, path string) ([]*CallDetails, []*ChainedValueContext, []*DroppedValue, error) {
+	callDetailsList, chainedValues, dropped, err := analysis.restore()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error in analysis file %s: %w", path, err)
+	}
+	return callDetailsList, chainedValues, dropped, nil
+}
+
+// checkAnalysisFlag validates the -file flag of the commands reading an analysis file.
+func (f *flags) checkAnalysisFlag() error {
+	if f.analysisPath == "" {
+		return errors.New("missing analysis file path (-file)")
+	}
+	return nil
+}
 
-	// Read and process the HAR file.
+// analyzeHar reads the HAR file and finds, refines and names its chained values. It also returns the
+// candidate values that were not chained, for the report.
+func analyzeHar(ctx context.Context, f flags) ([]*CallDetails, []*ChainedValueContext, []*DroppedValue, error) {
 	har, err := readHar(f.harFilePath)
 	if err != nil {
-		return fmt.Errorf("error reading HAR file: %w", err)
+		return nil, nil, nil, fmt.Errorf("error reading HAR file: %w", err)
 	}
+	return analyzeEntries(ctx, f, har)
+}
+
+// analyzeEntries finds, refines and names the chained values of a HAR file that has been read.
+func analyzeEntries(ctx context.Context, f flags, har HAR) ([]*CallDetails, []*ChainedValueContext, []*DroppedValue, error) {
+	// Select the language model used for naming and path refinement.
+	if f.namingMode == NamingModeAI {
+		if err := configureLLM(f.llm); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := configureLLMCache(f.cacheDir, f.cacheMode, f.cachePrune); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// Process the HAR file.
 	callDetailsList := processHar(har)
 
 	// Identify and process chained values.
-	chainedValues := findChainedValues(callDetailsList)
+	chainedValues, dropped := findChainedValues(callDetailsList)
 	// Optionally substitute pre-defined variables from a YAML file.
 	if f.varsFilePath != "" {
-		predefinedVars := loadJSONVars(f.varsFilePath)
+		predefinedVars, err := loadJSONVars(f.varsFilePath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 		chainedValues = extractPredefinedVars(callDetailsList, predefinedVars, chainedValues)
 	}
 
+	chainedValues = applyCookieMode(f.cookieMode, chainedValues)
+
 	logInitialChainedValues(chainedValues)
 	repopulateCallDetails(chainedValues)
-	updateComplexPaths(chainedValues)
-	WithRetries(assignVariableNames, 3)(chainedValues)
-	WithRetries(assignCallDetailNames, 3)(callDetailsList)
+	updateComplexPaths(ctx, chainedValues, f.namingMode == NamingModeAI)
+	assignNames(ctx, f.namingMode, callDetailsList, chainedValues)
+	return callDetailsList, chainedValues, dropped, nil
+}
 
-	// Build the Postman collection and write it to a file.
-	collection := BuildPostmanCollection(callDetailsList, chainedValues)
-	if err := WriteCollectionToFile(collection, f.outputPath); err != nil {
-		return fmt.Errorf("error writing Postman collection: %w", err)
+// export writes the calls and chained values in the given format.
+func export(format string, outputPath string, callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue) error {
+	exporter := lookupExporter(format)
+	if err := exportWith(exporter, callDetailsList, chainedValues, dropped, outputPath); err != nil {
+		return err
 	}
 
-	fmt.Println("Postman collection generated successfully.")
+	fmt.Printf("%s written to %s.\n", exporter.Description(), outputPath)
 	return nil
 }
 
+// assignNames names the chained variables and the calls. In AI mode the LLM proposes names,
+// falling back to heuristic names if it fails; in heuristic mode no LLM is used.
+// Variables are named before calls so that call names can refer to them.
+func assignNames(ctx context.Context, namingMode string, callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) {
+	if namingMode == NamingModeHeuristic {
+		assignHeuristicVariableNames(chainedValues)
+		assignHeuristicCallNames(callDetailsList)
+		return
+	}
+
+	if err := assignVariableNames(ctx, chainedValues); err != nil {
+		log.Printf("AI variable naming failed, falling back to heuristic names: %v", err)
+		assignHeuristicVariableNames(chainedValues)
+	}
+	if err := assignCallDetailNames(ctx, callDetailsList); err != nil {
+		log.Printf("AI call naming failed, falling back to heuristic names: %v", err)
+		assignHeuristicCallNames(callDetailsList)
+	}
+}
+
 // CallNameRequest holds the URL and sequence number for naming a call.
 type CallNameRequest struct {
 	URL      string `json:"url"`
@@ -80,7 +206,7 @@ type CallNameResponse struct {
 }
 
 // assignCallDetailNames uses the OpenAI API to generate descriptive call names based on the URL and the sequence of the calls.
-func assignCallDetailNames(list []*CallDetails) error {
+func assignCallDetailNames(ctx context.Context, list []*CallDetails) error {
 	var requests []CallNameRequest
 	for i, callDetails := range list {
 		// Parse the URL for validation.
@@ -115,35 +241,17 @@ The format of the result should be:
   ...repeat for each call...
 ]
 `
-	responses, err := CallOpenAIArray[CallNameResponse](prompt, requests)
+	if len(requests) != len(list) {
+		return errors.New("unable to parse every call URL")
+	}
+
+	// The validator ensures there is a 1:1 correspondence between the input and the response.
+	responses, err := CallOpenAIArray[CallNameResponse](ctx, prompt, requests, expectCount[CallNameResponse](len(requests)))
 	if err != nil {
-		// Fall back to using the URL's path if the AI call fails.
-		for _, callDetails := range list {
-			parsedURL, err := url.Parse(callDetails.Entry.Request.URL)
-			if err != nil {
-				log.Printf("Error parsing URL %s: %v", callDetails.Entry.Request.URL, err)
-				continue
-			}
-			callDetails.Name = parsedURL.Path
-		}
 		log.Printf("Error calling OpenAI: %v", err)
 		return errors.New("error calling OpenAI")
 	}
 
-	// Ensure there is a 1:1 correspondence between the input and the response.
-	if len(responses) != len(requests) {
-		log.Printf("Mismatched response count from OpenAI, falling back to URL path names")
-		for _, callDetails := range list {
-			parsedURL, err := url.Parse(callDetails.Entry.Request.URL)
-			if err != nil {
-				log.Printf("Error parsing URL %s: %v", callDetails.Entry.Request.URL, err)
-				return errors.New("error parsing URL")
-			}
-			callDetails.Name = parsedURL.Path
-		}
-		return errors.New("mismatched response count from OpenAI")
-	}
-
 	// Assign the AI-generated names to the respective call details.
 	for i, callDetails := range list {
 		callDetails.Name = responses[i].Name
@@ -152,41 +260,33 @@ The format of the result should be:
 	return nil
 }
 
-// parseFlags extracts and validates command-line flags.
-func parseFlags() (flags, error) {
-	harFilePath := flag.String("file", "", "Path to the HAR file")
-	varsFilePath := flag.String("vars", "", "Path to the YAML file with pre-defined variables")
-	outputPath := flag.String("output", "collection.json", "Output path for the generated Postman collection")
-
-	flag.Parse()
-
-	if *harFilePath == "" {
-		usage := "Usage: goharparser -file=<path_to_har_file> [-vars=<path_to_yaml_file>]"
-		fmt.Println(usage)
-		return flags{}, errors.New("missing HAR file path")
+// envOrDefault returns the value of the environment variable, or def if it is unset.
+func envOrDefault(name string, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
 	}
-
-	return flags{
-		harFilePath:  *harFilePath,
-		varsFilePath: *varsFilePath,
-		outputPath:   *outputPath,
-	}, nil
+	return def
 }
 
 // IsInteresting determines whether a ValueReference is significant for chaining.
 // It filters out values that are nil, too short (for strings), or below a threshold (for numbers).
 // It also excludes specific headers and JSON properties that are not useful for variable substitution.
 func (r *ValueReference) IsInteresting() bool {
+	return r.uninterestingReason() == ""
+}
+
+// uninterestingReason returns why IsInteresting rejects a ValueReference, or "" if it is interesting.
+func (r *ValueReference) uninterestingReason() string {
 	if r.Value == nil {
-		return false
+		return "no value"
 	}
 
 	if strings.Contains(r.ReferencePath, "@type") {
-		return false
+		return "@type property"
 	}
 
 	if r.HeaderName == "Content-Type" {
-		return false
+		return "Content-Type header"
 	}
 
 	// If it's a string, make sure it's at least 2 characters long
@@ -195,22 +295,33 @@ func (r *ValueReference) IsInteresting() bool {
 
 	switch v := r.Value.(type) {
 	case string:
-		return len(v) >= 2
+		if len(v) < 2 {
+			return "string shorter than 2 characters"
+		}
+		return ""
 	case int:
-		return v >= 100
+		if v < 100 {
+			return "number below 100"
+		}
+		return ""
 	case float64:
-		return v >= 100.0
+		if v < 100.0 {
+			return "number below 100"
+		}
+		return ""
 	}
-	return false
+	return fmt.Sprintf("%T value", r.Value)
 }
 
 // logInitialChainedValues logs the initial set of chained values for debugging purposes.
 // It prints each value along with its usage context in requests and responses.
 func logInitialChainedValues(chainedValues []*ChainedValueContext) {
+	// Written to the log output, which -quiet discards, to keep standard output for command results.
+	w := log.Writer()
 	for i, chainedValue := range chainedValues {
-		fmt.Printf("Chained Value %d:\n", i+1)
-		fmt.Printf("  Value: %s\n", chainedValue.Value)
-		fmt.Println("  Context:")
+		fmt.Fprintf(w, "Chained Value %d:\n", i+1)
+		fmt.Fprintf(w, "  Value: %s\n", chainedValue.Value)
+		fmt.Fprintln(w, "  Context:")
 		for _, ref := range chainedValue.AllUsages {
 			var requestOrResponse string
 			if ref.SourceType == SourceTypeRequest {
@@ -218,40 +329,120 @@ func logInitialChainedValues(chainedValues []*ChainedValueContext) {
 			} else {
 				requestOrResponse = "Response"
 			}
-			fmt.Printf("    - %s - %s\n", requestOrResponse, ref.ReferencePath)
+			var notes []string
+			if ref.MatchLength > 0 {
+				notes = append(notes, fmt.Sprintf("embedded at offset %d", ref.MatchOffset))
+			}
+			if ref.Transform != "" {
+				notes = append(notes, ref.Transform)
+			}
+			if len(notes) > 0 {
+				fmt.Fprintf(w, "    - %s - %s (%s)\n", requestOrResponse, ref.ReferencePath, strings.Join(notes, ", "))
+			} else {
+				fmt.Fprintf(w, "    - %s - %s\n", requestOrResponse, ref.ReferencePath)
+			}
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 }
 
 // findChainedValues analyzes the call details to identify values that appear in multiple requests and responses.
-// It filters out values that are not considered "interesting" and returns a slice of ChainedValueContext.
-func findChainedValues(callDetailsList []*CallDetails) []*ChainedValueContext {
-	// Map to keep track of values and their occurrences
+// Besides whole-value matches, response values embedded inside later request strings are detected,
+// as are values that reappear URL-encoded, base64-encoded or JSON-escaped (see valueTransforms).
+// It filters out values that are not considered "interesting" and returns a slice of ChainedValueContext,
+// along with the candidate values that were discarded and why, for the report.
+func findChainedValues(callDetailsList []*CallDetails) ([]*ChainedValueContext, []*DroppedValue) {
+	// Map to keep track of values and their occurrences, plus the order in which values were first seen
+	// so that the result is deterministic.
 	valueOccurrences := make(map[string][]*ValueReference)
+	var valueOrder []string
+	addOccurrence := func(valueStr string, ref *ValueReference) {
+		if _, seen := valueOccurrences[valueStr]; !seen {
+			valueOrder = append(valueOrder, valueStr)
+		}
+		valueOccurrences[valueStr] = append(valueOccurrences[valueStr], ref)
+	}
+
+	// Response values seen so far, searched for inside later request values.
+	embedded := newEmbeddedMatcher()
+
+	// Values rejected by IsInteresting, reported as dropped if they would otherwise have been chained.
+	rejectedOccurrences := make(map[string][]*ValueReference)
+	var rejectedOrder []string
+	addRejected := func(valueStr string, ref *ValueReference) {
+		if _, seen := rejectedOccurrences[valueStr]; !seen {
+			rejectedOrder = append(rejectedOrder, valueStr)
+		}
+		rejectedOccurrences[valueStr] = append(rejectedOccurrences[valueStr], ref)
+	}
 
-	// Iterate over each CallDetails
+	var dropped []*DroppedValue
+	dropValue := func(valueStr string, reason string, refs []*ValueReference) {
+		dropped = append(dropped, &DroppedValue{Value: valueStr, Reason: reason, Usages: refs})
+	}
+
+	// Iterate over each CallDetails in order, so that an embedded match can only refer to an earlier response.
 	for _, callDetails := range callDetailsList {
 		// Process RequestDetails
 		for _, reqDetail := range callDetails.RequestDetails {
 			if reqDetail.IsInteresting() {
-				valueStr := fmt.Sprintf("%v", reqDetail.Value)
-				valueOccurrences[valueStr] = append(valueOccurrences[valueStr], reqDetail)
+				addOccurrence(fmt.Sprintf("%v", reqDetail.Value), reqDetail)
+			} else if reqDetail.Value != nil {
+				addRejected(fmt.Sprintf("%v", reqDetail.Value), reqDetail)
+			}
+			str, ok := reqDetail.Value.(string)
+			if !ok {
+				continue
+			}
+			// Each encoded or embedded usage gets its own reference recording how the value appears in the string.
+			exactValue, exactMatched := "", false
+			c, ok := embedded.exactEncoded(str)
+			if !ok && isQueryReference(reqDetail) {
+				c, ok = embedded.exactQueryDecoded(str)
+			}
+			if ok {
+				usage := *reqDetail
+				usage.Transform = c.transform
+				addOccurrence(c.value, &usage)
+				exactValue, exactMatched = c.value, true
+			}
+			for _, match := range embedded.find(str) {
+				if exactMatched && match.value == exactValue {
+					// e.g. unpadded base64 found inside the padded form that already matched exactly.
+					continue
+				}
+				usage := *reqDetail
+				usage.MatchOffset = match.offset
+				usage.MatchLength = len(match.needle)
+				usage.Transform = match.transform
+				addOccurrence(match.value, &usage)
 			}
 		}
 
 		// Process ResponseDetails
 		for _, respDetail := range callDetails.ResponseDetails {
 			if respDetail.IsInteresting() {
-				valueStr := fmt.Sprintf("%v", respDetail.Value)
-				valueOccurrences[valueStr] = append(valueOccurrences[valueStr], respDetail)
+				addOccurrence(fmt.Sprintf("%v", respDetail.Value), respDetail)
+				if str, ok := respDetail.Value.(string); ok {
+					embedded.add(str)
+				}
+			} else if respDetail.Value != nil {
+				addRejected(fmt.Sprintf("%v", respDetail.Value), respDetail)
 			}
 		}
 	}
 
+	for _, value := range rejectedOrder {
+		refs := rejectedOccurrences[value]
+		if returnedBeforeUse(refs) {
+			dropValue(value, "not interesting: "+refs[0].uninterestingReason(), refs)
+		}
+	}
+
 	// Filter to keep only values that appear in multiple requests and responses
 	var chainedValues []*ChainedValueContext
-	for value, refs := range valueOccurrences {
+	for _, value := range valueOrder {
+		refs := valueOccurrences[value]
 		if len(refs) > 1 {
 			chainedValues = append(chainedValues, &ChainedValueContext{
 				Value:     value,
@@ -271,6 +462,7 @@ NextChainedValue:
 		for _, contextItem := range chainedValue.AllUsages {
 			if contextItem.SourceType == SourceTypeRequest {
 				if !seenResponse {
+					dropValue(chainedValue.Value, "used in a request before any response returned it", chainedValue.AllUsages)
 					continue NextChainedValue
 				}
 				includeVal = true
@@ -280,10 +472,12 @@ NextChainedValue:
 		}
 		if includeVal {
 			filteredChainedValues = append(filteredChainedValues, chainedValue)
+		} else {
+			dropValue(chainedValue.Value, "never used in a request after a response returned it", chainedValue.AllUsages)
 		}
 	}
 
-	return filteredChainedValues
+	return filteredChainedValues, dropped
 }
 
 // repopulateCallDetails updates each CallDetails instance by linking it to the associated chained values.
@@ -352,14 +546,14 @@ func addPredefinedUseIfFound(cv *ChainedValueContext, vr *ValueReference) bool {
 	return false
 }
 
-func loadJSONVars(filePath string) []varsInput {
+func loadJSONVars(filePath string) ([]varsInput, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Fatalf("Error reading JSON file: %v", err)
+		return nil, fmt.Errorf("error reading variables file: %w", err)
 	}
 	var vars []varsInput
 	if err := json.Unmarshal(data, &vars); err != nil {
-		log.Fatalf("Error parsing JSON file: %v", err)
+		return nil, fmt.Errorf("error parsing variables file %s: %w", filePath, err)
 	}
-	return vars
+	return vars, nil
 }
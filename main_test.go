@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// analyzeTestHar runs the analysis of convert on a HAR without the language model, naming heuristically.
+func analyzeTestHar(t *testing.T, har HAR) ([]*CallDetails, []*ChainedValueContext) {
+	t.Helper()
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	callDetailsList := processHar(har)
+	chainedValues, _ := findChainedValues(callDetailsList)
+	repopulateCallDetails(chainedValues)
+	updateComplexPaths(context.Background(), chainedValues, false)
+	assignNames(context.Background(), NamingModeHeuristic, callDetailsList, chainedValues)
+	return callDetailsList, chainedValues
+}
+
+// jsonEntry returns a HAR entry for a call with an optional body and a JSON response.
+func jsonEntry(method string, rawURL string, mimeType string, body string, response string) Entry {
+	entry := Entry{
+		Request:  Request{Method: method, URL: rawURL},
+		Response: Response{Status: 200, Content: Content{MimeType: "application/json", Text: response}},
+	}
+	if body != "" {
+		entry.Request.PostData = &PostData{MimeType: mimeType, Text: body}
+	}
+	return entry
+}
+
+func TestRunCommands(t *testing.T) {
+	dir := t.TempDir()
+	harData, err := json.Marshal(roundTripHar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	harPath := filepath.Join(dir, "flow.har")
+	analysisPath := filepath.Join(dir, "analysis.json")
+	if err := os.WriteFile(harPath, harData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The steps run in order: later ones read the analysis file written by analyze.
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantStatus int
+		wantStdout string
+		wantOutput string
+	}{
+		{"overview", []string{"help"}, "", 0, "Commands:", ""},
+		{"global help", []string{"-help"}, "", 0, "Global flags:", ""},
+		{"command help", []string{"render", "-help"}, "", 0, "Usage: chainer render [flags]", ""},
+		{"command double-dash help", []string{"validate", "--help"}, "", 0, "Check an analysis file", ""},
+		{"help for a command", []string{"help", "explain"}, "", 0, "the analysis file to explain", ""},
+		{"help for an unknown command", []string{"help", "frobnicate"}, "", exitUsageError, "", ""},
+		{"unknown command", []string{"frobnicate"}, "", exitUsageError, "", ""},
+		{"unknown flag", []string{"convert", "-frobnicate"}, "", exitUsageError, "", ""},
+		{"missing -file", []string{"convert", "-naming=heuristic"}, "", exitUsageError, "", ""},
+		{"invalid -format", []string{"convert", "-file", harPath, "-format", "word"}, "", exitUsageError, "", ""},
+		{"unexpected argument", []string{"render", "-file", analysisPath, "extra"}, "", exitUsageError, "", ""},
+		{"missing HAR file", []string{"convert", "-file", filepath.Join(dir, "missing.har"), "-naming=heuristic"}, "", exitProcessingError, "", ""},
+		{"HAR without entries", []string{"convert", "-file=-", "-naming=heuristic"}, `{"log": {"entries": []}}`, exitUsageError, "", ""},
+		{"convert from stdin", []string{"convert", "-file=-", "-naming=heuristic", "-format=curl", "-output", filepath.Join(dir, "chain.sh")}, string(harData), 0, "curl commands written", "chain.sh"},
+		{"convert without a command", []string{"-quiet", "-file", harPath, "-naming=heuristic", "-format=hurl", "-output", filepath.Join(dir, "flow.hurl")}, "", 0, "", "flow.hurl"},
+		{"analyze from stdin", []string{"analyze", "-file=-", "-naming=heuristic", "-output", analysisPath}, string(harData), 0, "Analysis written", "analysis.json"},
+		{"analysis file given to convert", []string{"convert", "-file", analysisPath, "-naming=heuristic"}, "", exitUsageError, "", ""},
+		{"render from stdin", []string{"render", "-file=-", "-format=dot", "-output", filepath.Join(dir, "chain.dot")}, "@analysis", 0, "dependency graph written", "chain.dot"},
+		{"render a HAR file", []string{"render", "-file", harPath, "-format=dot"}, "", exitProcessingError, "", ""},
+		{"validate", []string{"validate", "-file", analysisPath}, "", 0, "is valid", ""},
+		{"explain an analysis file", []string{"explain", "-file=-"}, "@analysis", 0, "chained values", ""},
+		{"explain a HAR file", []string{"explain", "-file", harPath, "-naming=heuristic"}, "", 0, "chained values", ""},
+		{"diff", []string{"diff", analysisPath, analysisPath}, "", 0, "", ""},
+		{"diff without files", []string{"diff"}, "", exitUsageError, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin := tt.stdin
+			if stdin == "@analysis" {
+				data, err := os.ReadFile(analysisPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stdin = string(data)
+			}
+			stdout, err := runWithStdio(t, tt.args, stdin)
+			if status := exitStatus(err); status != tt.wantStatus {
+				t.Fatalf("exit status %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout does not contain %q:\n%s", tt.wantStdout, stdout)
+			}
+			if tt.wantOutput != "" {
+				if _, err := os.Stat(filepath.Join(dir, tt.wantOutput)); err != nil {
+					t.Errorf("output not written: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// runWithStdio runs the command line with stdin as standard input and returns what it printed to standard output.
+func runWithStdio(t *testing.T, args []string, stdin string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	in, err := os.Create(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := in.WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	previousStdin, previousStdout, previousLog := os.Stdin, os.Stdout, log.Writer()
+	os.Stdin, os.Stdout = in, out
+	log.SetOutput(io.Discard)
+	defer func() {
+		os.Stdin, os.Stdout = previousStdin, previousStdout
+		log.SetOutput(previousLog)
+	}()
+
+	runErr := run(args)
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), runErr
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// mermaidExporter writes a Mermaid sequence diagram of the calls. Each call is a request from the client to
+// its host and a response back, annotated with the chained variables the request uses and the response sets.
+type mermaidExporter struct{}
+
+func (mermaidExporter) Name() string          { return "mermaid" }
+func (mermaidExporter) Description() string   { return "Mermaid sequence diagram" }
+func (mermaidExporter) DefaultOutput() string { return "flow.mmd" }
+func (mermaidExporter) Export(callDetailsList []*CallDetails, _ []*ChainedValueContext, outputPath string) error {
+	if err := os.WriteFile(outputPath, []byte(BuildMermaidDiagram(callDetailsList)), 0644); err != nil {
+		return fmt.Errorf("error writing Mermaid diagram: %w", err)
+	}
+	return nil
+}
+
+// BuildMermaidDiagram renders the calls as a Mermaid sequenceDiagram with one participant per host.
+func BuildMermaidDiagram(callDetailsList []*CallDetails) string {
+	lines := []string{"sequenceDiagram", "    participant Client"}
+	hosts := make(map[string]string)
+	var messages []string
+	for _, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		request := callDetails.Entry.Request
+		host := request.URL
+		path := request.URL
+		if parsedURL, err := url.Parse(request.URL); err == nil && parsedURL.Host != "" {
+			host = parsedURL.Host
+			path = parsedURL.RequestURI()
+		}
+		participant, ok := hosts[host]
+		if !ok {
+			participant = fmt.Sprintf("H%d", len(hosts)+1)
+			hosts[host] = participant
+			lines = append(lines, fmt.Sprintf("    participant %s as %s", participant, mermaidText(host)))
+		}
+
+		label := []string{mermaidText(request.Method + " " + path)}
+		if callDetails.Name != "" && !strings.HasPrefix(request.Method+" "+path, callDetails.Name) {
+			label = append([]string{mermaidText(callDetails.Name)}, label...)
+		}
+		if uses := mermaidVariables(callDetails.RequestChainedValues, SourceTypeRequest); uses != "" {
+			label = append(label, "uses: "+uses)
+		}
+		messages = append(messages, fmt.Sprintf("    Client->>%s: %s", participant, strings.Join(label, "<br/>")))
+
+		response := callDetails.Entry.Response
+		label = []string{mermaidText(strings.TrimSpace(fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status))))}
+		if sets := mermaidVariables(callDetails.ResponseChainedValues, SourceTypeResponse); sets != "" {
+			label = append(label, "sets: "+sets)
+		}
+		messages = append(messages, fmt.Sprintf("    %s-->>Client: %s", participant, strings.Join(label, "<br/>")))
+	}
+	lines = append(lines, messages...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// mermaidVariables lists the distinct chained variables of the references of the given source type.
+func mermaidVariables(refs []*ValueReference, sourceType SourceType) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if ref.SourceType != sourceType || ref.Context == nil || seen[ref.Context.VariableName] {
+			continue
+		}
+		seen[ref.Context.VariableName] = true
+		names = append(names, ref.Context.VariableName)
+	}
+	return mermaidText(strings.Join(names, ", "))
+}
+
+// mermaidText escapes the characters that end or break a Mermaid message as entity codes.
+func mermaidText(s string) string {
+	return strings.NewReplacer("#", "#35;", ";", "#59;", "\n", " ", "<", "#lt;", ">", "#gt;").Replace(s)
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestBuildMermaidDiagram(t *testing.T) {
+	callDetailsList, _ := analyzeTestHar(t, chainTestHar())
+	want := `sequenceDiagram
+    participant Client
+    participant H1 as api.example.com
+    Client->>H1: POST /v1/login
+    H1-->>Client: 200 OK<br/>sets: dataToken
+    Client->>H1: POST /v1/orders<br/>uses: dataToken
+    H1-->>Client: 200 OK<br/>sets: orderId
+    Client->>H1: GET /v1/orders/{orderId}<br/>GET /v1/orders/ord55123<br/>uses: dataToken, orderId
+    H1-->>Client: 200 OK
+`
+	if got := BuildMermaidDiagram(callDetailsList); got != want {
+		t.Errorf("BuildMermaidDiagram =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMermaidText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"GET /v1/items", "GET /v1/items"},
+		{"GET /a#b;c", "GET /a#35;b#59;c"},
+		{"<br/>\nnext", "#lt;br/#gt; next"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := mermaidText(tt.in); got != tt.want {
+				t.Errorf("mermaidText(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
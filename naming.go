@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Supported values for the -naming flag.
+const (
+	// NamingModeAI asks the configured LLM for variable and call names.
+	NamingModeAI = "ai"
+
+	// NamingModeHeuristic derives names deterministically from paths and URLs, without any LLM.
+	NamingModeHeuristic = "heuristic"
+)
+
+// genericLeafKeys are JSON keys that say little on their own, so the parent key is
+// prepended to them (e.g. "travelers[0].id" becomes "travelerId").
+var genericLeafKeys = map[string]bool{
+	"id":     true,
+	"key":    true,
+	"value":  true,
+	"code":   true,
+	"name":   true,
+	"type":   true,
+	"number": true,
+	"ref":    true,
+	"token":  true,
+	"uuid":   true,
+	"guid":   true,
+}
+
+// identifierLikeSegment matches URL path segments that look like generated identifiers:
+// numbers, UUIDs, long hex strings, or tokens of at least 8 characters that contain a digit.
+var identifierLikeSegment = regexp.MustCompile(`^(\d+|[0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,}|[A-Za-z_-]*\d[A-Za-z0-9_-]*)$`)
+
+// isIdentifierLikeSegment reports whether a URL path segment looks like a generated identifier
+// rather than a fixed part of the route.
+func isIdentifierLikeSegment(segment string) bool {
+	if !identifierLikeSegment.MatchString(segment) {
+		return false
+	}
+	for _, r := range segment {
+		if !unicode.IsDigit(r) {
+			// Mixed tokens such as "v2" or "oauth2" are route names, not identifiers.
+			return len(segment) >= 8
+		}
+	}
+	return true
+}
+
+// assignHeuristicVariableNames assigns deterministic variable names to each chained value.
+// Names are derived from the source reference path, camelCased and made unique by suffixing a counter.
+// Values that already carry a name (pre-defined variables) keep it.
+func assignHeuristicVariableNames(chainedValues []*ChainedValueContext) {
+	used := make(map[string]bool)
+	for _, cv := range chainedValues {
+		if cv.VariableName != "" {
+			used[cv.VariableName] = true
+		}
+	}
+
+	for _, cv := range chainedValues {
+		if cv.VariableName != "" {
+			continue
+		}
+		ref := cv.ValueSource
+		if ref == nil && len(cv.AllUsages) > 0 {
+			ref = cv.AllUsages[0]
+		}
+		base := "value"
+		if ref != nil {
+			base = heuristicVariableName(ref)
+		}
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		used[name] = true
+		cv.VariableName = name
+	}
+}
+
+// heuristicVariableName proposes a camelCase variable name for a single value reference
+// based on where it was found.
+func heuristicVariableName(ref *ValueReference) string {
+	var words []string
+	switch {
+	case ref.JWTParent != nil:
+		words = jwtClaimWords(ref.JWTClaimPath)
+	case ref.SourceLocation == SourceLocationHeader:
+		words = splitWords(ref.HeaderName)
+		if len(words) > 1 && strings.EqualFold(words[0], "x") {
+			words = words[1:]
+		}
+	case ref.SourceLocation == SourceLocationCookie:
+		words = splitWords(ref.CookieName)
+	case ref.SourceLocation == SourceLocationUrl:
+		words = urlReferenceWords(ref)
+	default:
+		keys := referencePathKeys(ref.ReferencePath)
+		if len(keys) == 0 {
+			break
+		}
+		leaf := keys[len(keys)-1]
+		words = splitWords(leaf)
+		if len(keys) > 1 && genericLeafKeys[strings.ToLower(leaf)] {
+			parent := splitWords(keys[len(keys)-2])
+			if len(parent) > 0 {
+				parent[len(parent)-1] = singularize(parent[len(parent)-1])
+			}
+			words = append(parent, words...)
+		}
+	}
+
+	name := camelCase(words)
+	if name == "" {
+		return "value"
+	}
+	if unicode.IsDigit(rune(name[0])) {
+		name = "value" + strings.ToUpper(name[:1]) + name[1:]
+	}
+	return name
+}
+
+// jwtClaimWords names a JWT claim by its key, spelling out the registered claim names (e.g. "sub" yields "subject").
+func jwtClaimWords(claimPath string) []string {
+	_, rest, _ := strings.Cut(claimPath, ".")
+	keys := referencePathKeys(rest)
+	if len(keys) == 0 {
+		return nil
+	}
+	leaf := keys[len(keys)-1]
+	if readable, ok := jwtRegisteredClaimNames[leaf]; ok && len(keys) == 1 {
+		return splitWords(readable)
+	}
+	return splitWords(leaf)
+}
+
+// urlReferenceWords names a URL-sourced value by its query key or, for path segments,
+// by the preceding path segment (e.g. "/orders/123" yields "order id").
+func urlReferenceWords(ref *ValueReference) []string {
+	if strings.HasPrefix(ref.ReferencePath, "query.") {
+		keys := referencePathKeys(strings.TrimPrefix(ref.ReferencePath, "query."))
+		if len(keys) > 0 {
+			return splitWords(keys[len(keys)-1])
+		}
+	}
+	if ref.Source != nil && ref.Source.Entry != nil && ref.UrlLocation > 0 {
+		parsedURL, err := url.Parse(ref.Source.Entry.Request.URL)
+		if err == nil {
+			segments := urlPathSegments(parsedURL.Path)
+			if ref.UrlLocation-1 < len(segments) {
+				prev := segments[ref.UrlLocation-1]
+				if prev != "" && !isIdentifierLikeSegment(prev) {
+					words := splitWords(prev)
+					if len(words) > 0 {
+						words[len(words)-1] = singularize(words[len(words)-1])
+					}
+					return append(words, "id")
+				}
+			}
+		}
+	}
+	return []string{"path", "value"}
+}
+
+// referencePathKeys returns the object keys of a reference path, dropping array indices and predicates.
+func referencePathKeys(path string) []string {
+	var keys []string
+	if expr, err := ParseReferencePath(path); err == nil {
+		for _, st := range expr.Steps {
+			if (st.kind == stepKey || st.kind == stepRecursive) && st.key != "" {
+				keys = append(keys, st.key)
+			}
+		}
+		return keys
+	}
+	for _, token := range strings.Split(path, ".") {
+		if i := strings.IndexRune(token, '['); i >= 0 {
+			token = token[:i]
+		}
+		if token != "" {
+			keys = append(keys, token)
+		}
+	}
+	return keys
+}
+
+// splitWords splits an identifier on punctuation and camelCase boundaries.
+func splitWords(s string) []string {
+	var words []string
+	var current []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, strings.ToLower(string(current)))
+			current = nil
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return words
+}
+
+// camelCase joins lower-case words into a lowerCamelCase identifier.
+func camelCase(words []string) string {
+	var sb strings.Builder
+	for i, w := range words {
+		if w == "" {
+			continue
+		}
+		if i == 0 || sb.Len() == 0 {
+			sb.WriteString(w)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return sb.String()
+}
+
+// singularize applies a naive English singularization to a lower-case word.
+func singularize(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 3:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "ses") || strings.HasSuffix(w, "xes"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+		return w
+	case strings.HasSuffix(w, "s") && len(w) > 1:
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// assignHeuristicCallNames names each call after its method and templated URL path,
+// e.g. "GET /orders/{orderId}". Chained path segments are replaced by their variable names
+// and other identifier-like segments by "{id}".
+func assignHeuristicCallNames(list []*CallDetails) {
+	for _, callDetails := range list {
+		callDetails.Name = heuristicCallName(callDetails)
+	}
+}
+
+// heuristicCallName builds the method + path template name for a single call.
+func heuristicCallName(callDetails *CallDetails) string {
+	method := strings.ToUpper(callDetails.Entry.Request.Method)
+	parsedURL, err := url.Parse(callDetails.Entry.Request.URL)
+	if err != nil {
+		return strings.TrimSpace(method + " " + callDetails.Entry.Request.URL)
+	}
+
+	segments := urlPathSegments(parsedURL.Path)
+	var template []string
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		segment, replaced := substituteChainedValue(segment, urlPathRefs(callDetails, i), func(ref *ValueReference) string {
+			return "{" + ref.Context.VariableName + "}"
+		})
+		if !replaced && isIdentifierLikeSegment(segment) {
+			segment = "{id}"
+		}
+		template = append(template, segment)
+	}
+	return method + " /" + strings.Join(template, "/")
+}
+
+// urlPathRefs returns the chained request values found in the given URL path segment.
+func urlPathRefs(callDetails *CallDetails, segment int) []*ValueReference {
+	var refs []*ValueReference
+	for _, ref := range callDetails.RequestChainedValues {
+		if ref.SourceLocation == SourceLocationUrl && strings.HasPrefix(ref.ReferencePath, "path[") && ref.UrlLocation == segment &&
+			ref.Context != nil && ref.Context.VariableName != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
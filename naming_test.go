@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeuristicVariableName(t *testing.T) {
+	call := func(rawURL string) *CallDetails {
+		return &CallDetails{Entry: &Entry{Request: Request{Method: "GET", URL: rawURL}}}
+	}
+	tests := []struct {
+		name string
+		ref  *ValueReference
+		want string
+	}{
+		{"json key", &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: "customer.email"}, "email"},
+		{"json generic key under its parent", &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: "order.id"}, "orderId"},
+		{"json generic key under an array", &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: "items[0].id"}, "itemId"},
+		{"json predicate", &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: "$.travelers[?(@.type=='ADT')].id"}, "travelerId"},
+		{"json snake case key", &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: "data.access_token"}, "accessToken"},
+		{"json key with a leading digit", &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: "2fa_code"}, "value2faCode"},
+		{"header", &ValueReference{SourceLocation: SourceLocationHeader, HeaderName: "X-Request-Id"}, "requestId"},
+		{"cookie", &ValueReference{SourceLocation: SourceLocationCookie, CookieName: "session_key"}, "sessionKey"},
+		{"query", &ValueReference{SourceLocation: SourceLocationUrl, ReferencePath: "query.customer_id[0]"}, "customerId"},
+		{"path after its collection",
+			&ValueReference{SourceLocation: SourceLocationUrl, ReferencePath: "path[3]", UrlLocation: 3, Source: call("https://api.example.com/v1/orders/ord55123")}, "orderId"},
+		{"path with repeated slashes",
+			&ValueReference{SourceLocation: SourceLocationUrl, ReferencePath: "path[3]", UrlLocation: 3, Source: call("https://api.example.com/v1//orders/ord55123")}, "orderId"},
+		{"path after another identifier",
+			&ValueReference{SourceLocation: SourceLocationUrl, ReferencePath: "path[2]", UrlLocation: 2, Source: call("https://api.example.com/12345/ord55123")}, "pathValue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heuristicVariableName(tt.ref); got != tt.want {
+				t.Errorf("heuristicVariableName(%s) = %s, want %s", tt.ref.ReferencePath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignHeuristicVariableNames(t *testing.T) {
+	value := func(name string, path string) *ChainedValueContext {
+		return &ChainedValueContext{VariableName: name, ValueSource: &ValueReference{SourceLocation: SourceLocationBodyJson, ReferencePath: path}}
+	}
+	tests := []struct {
+		name   string
+		values []*ChainedValueContext
+		want   []string
+	}{
+		{"distinct", []*ChainedValueContext{value("", "order.id"), value("", "data.token")}, []string{"orderId", "dataToken"}},
+		{"collisions", []*ChainedValueContext{value("", "order.id"), value("", "orders[0].id"), value("", "order.id")}, []string{"orderId", "orderId2", "orderId3"}},
+		{"pre-defined name kept", []*ChainedValueContext{value("", "order.id"), value("orderId", "")}, []string{"orderId2", "orderId"}},
+		{"no source", []*ChainedValueContext{{}, {}}, []string{"value", "value2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignHeuristicVariableNames(tt.values)
+			var got []string
+			for _, cv := range tt.values {
+				got = append(got, cv.VariableName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeuristicCallName(t *testing.T) {
+	orderID := &ChainedValueContext{VariableName: "orderId"}
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		refs   []*ValueReference
+		want   string
+	}{
+		{"root", "get", "https://api.example.com", nil, "GET /"},
+		{"fixed route", "post", "https://api.example.com/v1/orders?x=1", nil, "POST /v1/orders"},
+		{"identifier", "GET", "https://api.example.com/v1/orders/12345", nil, "GET /v1/orders/{id}"},
+		{"chained segment", "GET", "https://api.example.com/v1/orders/ord55123",
+			[]*ValueReference{{Value: "ord55123", SourceLocation: SourceLocationUrl, ReferencePath: "path[3]", UrlLocation: 3, Context: orderID}}, "GET /v1/orders/{orderId}"},
+		{"chained segment after repeated slashes", "GET", "https://api.example.com/v1//orders/ord55123",
+			[]*ValueReference{{Value: "ord55123", SourceLocation: SourceLocationUrl, ReferencePath: "path[3]", UrlLocation: 3, Context: orderID}}, "GET /v1/orders/{orderId}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callDetails := &CallDetails{Entry: &Entry{Request: Request{Method: tt.method, URL: tt.url}}, RequestChainedValues: tt.refs}
+			if got := heuristicCallName(callDetails); got != tt.want {
+				t.Errorf("heuristicCallName = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
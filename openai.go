@@ -1,12 +1,10 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"log"
 )
 
 // OpenAIMessage represents a single message for the OpenAI API.
@@ -32,15 +30,73 @@ type OpenAIResponse struct {
 	Choices []OpenAIChoice `json:"choices"`
 }
 
-// callOpenAIBase sends the request to the OpenAI API and returns the raw response string.
-// It serves as the common base for the higher-level helper functions.
-func callOpenAIBase(prompt string, input interface{}) (string, error) {
-	// Get the API key from the environment
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("OPENAI_API_KEY environment variable is not set")
+// openAIProvider talks to the OpenAI chat completions API or any server that implements it,
+// such as Azure OpenAI or local runtimes like Ollama, llama.cpp and vLLM.
+type openAIProvider struct {
+	name     string
+	endpoint string
+	model    string
+	apiKey   string
+
+	// apiKeyEnv is the environment variable consulted when apiKey and CHAINER_LLM_API_KEY are empty. Without
+	// one, the environment is not consulted at all.
+	apiKeyEnv string
+
+	// authHeader is the header carrying the key. Empty means "Authorization: Bearer <key>".
+	authHeader string
+
+	// apiKeyOptional allows requests without a key, as local servers usually don't need one.
+	apiKeyOptional bool
+}
+
+func (p *openAIProvider) Name() string  { return p.name }
+func (p *openAIProvider) Model() string { return p.model }
+
+func (p *openAIProvider) Endpoint() string { return p.endpoint }
+
+// Complete sends the messages to the chat completions endpoint and returns the first choice.
+func (p *openAIProvider) Complete(ctx context.Context, messages []LLMMessage) (string, error) {
+	apiKey := resolveAPIKey(p.apiKey, p.apiKeyEnv)
+	if apiKey == "" && !p.apiKeyOptional {
+		return "", markPermanent(fmt.Errorf("%s environment variable is not set", p.apiKeyEnv))
+	}
+
+	headers := map[string]string{}
+	if apiKey != "" {
+		if p.authHeader != "" {
+			headers[p.authHeader] = apiKey
+		} else {
+			headers["Authorization"] = "Bearer " + apiKey
+		}
+	}
+
+	reqBody := OpenAIRequest{
+		Model: p.model,
+	}
+	for _, m := range messages {
+		reqBody.Messages = append(reqBody.Messages, OpenAIMessage{Role: m.Role, Content: m.Content})
 	}
 
+	var openAIResponse OpenAIResponse
+	if err := postLLMRequest(ctx, p.endpoint, headers, reqBody, &openAIResponse); err != nil {
+		return "", err
+	}
+
+	if len(openAIResponse.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned from %s", p.name)
+	}
+
+	// Return the raw content from the first choice
+	return openAIResponse.Choices[0].Message.Content, nil
+}
+
+// callOpenAIBase sends the request to the configured LLM provider and returns the raw response string.
+// It serves as the common base for the higher-level helper functions.
+// Responses are served from and stored in the response cache. If decode is non-nil, a response is only
+// cached once decode accepts it, and a cached response that decode rejects is discarded and re-requested.
+// Requests are retried according to llmRetryPolicy; a decode failure counts as a retryable error
+// since the model may answer correctly on the next attempt.
+func callOpenAIBase(ctx context.Context, prompt string, input interface{}, decode func(content string) error) (string, error) {
 	// Convert input to JSON
 	jsonData, err := json.Marshal(input)
 	if err != nil {
@@ -48,7 +104,7 @@ func callOpenAIBase(prompt string, input interface{}) (string, error) {
 	}
 
 	// Prepare the messages
-	messages := []OpenAIMessage{
+	messages := []LLMMessage{
 		{
 			Role:    "user",
 			Content: "You are an assistant that takes the input request and performs a simple request.",
@@ -63,100 +119,99 @@ func callOpenAIBase(prompt string, input interface{}) (string, error) {
 		},
 	}
 
-	// Log the request for debugging
-	fmt.Println("Request to OpenAI:")
-	fmt.Println(messages)
+	provider := activeLLMProvider()
 
-	// Create the OpenAI request body
-	reqBody := OpenAIRequest{
-		//Model: "o3-mini",
-		//Model: "o1-mini",
-		Model: "gpt-4o-mini",
-		//Model:     "gpt-4o",
-		Messages: messages,
-		//MaxTokens: 16384,
-	}
-	reqBodyJSON, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("error marshalling request body: %v", err)
+	cacheKey := llmCacheKey(provider, prompt, jsonData)
+	if responseCache != nil {
+		if content, ok := responseCache.get(cacheKey); ok {
+			if decode == nil || decode(content) == nil {
+				log.Printf("Using cached %s response %s", provider.Name(), cacheKey[:12])
+				return content, nil
+			}
+			responseCache.forget(cacheKey)
+		}
 	}
 
-	// Create the HTTP request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(reqBodyJSON))
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	log.Printf("Sending request to %s (%s)", provider.Name(), provider.Model())
 
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("error making request: %v", err)
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	content, err := Retry(ctx, llmRetryPolicy, func(ctx context.Context) (string, error) {
+		content, err := provider.Complete(ctx, messages)
 		if err != nil {
-			fmt.Println("Error closing response body:", err)
+			return "", err
 		}
-	}(resp.Body)
-
-	// Read the response
-	respBody, err := io.ReadAll(resp.Body)
+		if decode != nil {
+			if err := decode(content); err != nil {
+				return "", markRetryable(err)
+			}
+		}
+		return content, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
+		return "", err
 	}
 
-	// Log the raw response
-	fmt.Println("Response from OpenAI:")
-	fmt.Println(string(respBody))
-
-	// Parse the response into our struct
-	var openAIResponse OpenAIResponse
-	if err := json.Unmarshal(respBody, &openAIResponse); err != nil {
-		return "", fmt.Errorf("error unmarshalling response: %v", err)
-	}
-
-	if len(openAIResponse.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned from OpenAI")
+	if responseCache != nil {
+		if err := responseCache.put(cacheKey, provider, content); err != nil {
+			log.Printf("Error caching LLM response: %v", err)
+		}
 	}
-
-	// Return the raw content from the first choice
-	return openAIResponse.Choices[0].Message.Content, nil
+	return content, nil
 }
 
 // CallOpenAIString calls the API and returns the raw string response.
-func CallOpenAIString(prompt string, input interface{}) (string, error) {
-	return callOpenAIBase(prompt, input)
+func CallOpenAIString(ctx context.Context, prompt string, input interface{}) (string, error) {
+	return callOpenAIBase(ctx, prompt, input, nil)
 }
 
 // CallOpenAIArray calls the API and unmarshals the JSON response into a slice of type T.
-func CallOpenAIArray[T any](prompt string, input interface{}) ([]T, error) {
-	content, err := callOpenAIBase(prompt, input)
+// Optional validators check the decoded result; a rejected result is retried like unparsable output.
+func CallOpenAIArray[T any](ctx context.Context, prompt string, input interface{}, validate ...func([]T) error) ([]T, error) {
+	var result []T
+	_, err := callOpenAIBase(ctx, prompt, input, func(content string) error {
+		result = nil
+		if err := json.Unmarshal([]byte(content), &result); err != nil {
+			return fmt.Errorf("error unmarshalling result into []T: %v", err)
+		}
+		for _, v := range validate {
+			if err := v(result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var result []T
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("error unmarshalling result into []T: %v", err)
-	}
-
 	return result, nil
 }
 
 // CallOpenAIObject calls the API and unmarshals the JSON response into an object of type T.
-func CallOpenAIObject[T any](prompt string, input interface{}) (T, error) {
+func CallOpenAIObject[T any](ctx context.Context, prompt string, input interface{}) (T, error) {
 	var result T
-	content, err := callOpenAIBase(prompt, input)
+	_, err := callOpenAIBase(ctx, prompt, input, func(content string) error {
+		var zero T
+		result = zero
+		if err := json.Unmarshal([]byte(content), &result); err != nil {
+			return fmt.Errorf("error unmarshalling result into T: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return result, err
-	}
-
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return result, fmt.Errorf("error unmarshalling result into T: %v", err)
+		var zero T
+		return zero, err
 	}
 
 	return result, nil
 }
+
+// expectCount returns a CallOpenAIArray validator requiring exactly n results,
+// for prompts that must answer every input item.
+func expectCount[T any](n int) func([]T) error {
+	return func(result []T) error {
+		if len(result) != n {
+			return fmt.Errorf("mismatched response count from OpenAI: expected %d, got %d", n, len(result))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// openAPIPathParam matches the {{name}} placeholders BuildPostmanURL puts in chained path segments.
+var openAPIPathParam = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// OpenAPIDocument is an OpenAPI 3.1 document.
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components *OpenAPIComponents         `json:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPathItem maps lower-case HTTP methods to the operations of a path.
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Servers     []OpenAPIServer             `json:"servers,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *JSONSchema `json:"schema,omitempty"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+	Links       map[string]*OpenAPILink      `json:"links,omitempty"`
+}
+
+// OpenAPILink describes how a value of a response feeds a parameter of a later operation.
+type OpenAPILink struct {
+	OperationID string            `json:"operationId"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// openAPIExporter writes an OpenAPI 3.1 document inferred from the recorded traffic. Calls with the same
+// method and templated path are merged into one operation, chained path segments become path parameters,
+// body schemas are inferred from the recorded bodies, and chained values become links between operations.
+type openAPIExporter struct{}
+
+func (openAPIExporter) Name() string          { return "openapi" }
+func (openAPIExporter) Description() string   { return "OpenAPI 3.1 specification" }
+func (openAPIExporter) DefaultOutput() string { return "openapi.json" }
+func (openAPIExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	data, err := json.MarshalIndent(BuildOpenAPIDocument(callDetailsList, chainedValues), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling OpenAPI document: %w", err)
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing OpenAPI document: %w", err)
+	}
+	return nil
+}
+
+// openAPIBuilder accumulates operations while the calls are clustered.
+type openAPIBuilder struct {
+	doc          *OpenAPIDocument
+	operations   map[string]*OpenAPIOperation
+	callOps      map[*CallDetails]*OpenAPIOperation
+	operationIDs map[string]bool
+}
+
+// BuildOpenAPIDocument infers an OpenAPI document from the calls and their chained values.
+func BuildOpenAPIDocument(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) *OpenAPIDocument {
+	b := &openAPIBuilder{
+		doc: &OpenAPIDocument{
+			OpenAPI: "3.1.0",
+			Info:    OpenAPIInfo{Title: "Recorded API", Version: "1.0.0"},
+			Paths:   make(map[string]OpenAPIPathItem),
+		},
+		operations:   make(map[string]*OpenAPIOperation),
+		callOps:      make(map[*CallDetails]*OpenAPIOperation),
+		operationIDs: make(map[string]bool),
+	}
+	for _, callDetails := range callDetailsList {
+		if callDetails != nil {
+			b.addCall(callDetails)
+		}
+	}
+	for _, cv := range chainedValues {
+		b.addLinks(cv)
+	}
+	return b.doc
+}
+
+// addCall merges a call into the operation for its method and templated path, creating it if needed.
+func (b *openAPIBuilder) addCall(callDetails *CallDetails) {
+	request := callDetails.Entry.Request
+	parsedURL, err := url.Parse(request.URL)
+	if err != nil {
+		log.Printf("OpenAPI: skipping %s: %v", request.URL, err)
+		return
+	}
+
+	// Chained path segments carry {{name}} placeholders, which become {name} path parameters.
+	templated := "/" + strings.Join(BuildPostmanURL(callDetails).Path, "/")
+	path := openAPIPathParam.ReplaceAllString(templated, "{$1}")
+	method := strings.ToLower(request.Method)
+
+	server := OpenAPIServer{URL: parsedURL.Scheme + "://" + parsedURL.Host}
+	if len(b.doc.Servers) == 0 {
+		b.doc.Servers = append(b.doc.Servers, server)
+	}
+
+	op := b.operations[method+" "+path]
+	if op == nil {
+		op = &OpenAPIOperation{
+			OperationID: b.operationID(callDetails),
+			Summary:     callDetails.Name,
+			Responses:   make(map[string]*OpenAPIResponse),
+		}
+		if server != b.doc.Servers[0] {
+			op.Servers = []OpenAPIServer{server}
+		}
+		for _, match := range openAPIPathParam.FindAllStringSubmatch(templated, -1) {
+			op.addParameter(match[1], "path", &JSONSchema{Types: []string{"string"}})
+		}
+		b.operations[method+" "+path] = op
+		if b.doc.Paths[path] == nil {
+			b.doc.Paths[path] = make(OpenAPIPathItem)
+		}
+		b.doc.Paths[path][method] = op
+	}
+	b.callOps[callDetails] = op
+
+	for _, key := range sortedKeys(parsedURL.Query()) {
+		op.addParameter(key, "query", &JSONSchema{Types: []string{"string"}})
+	}
+	for _, ref := range callDetails.RequestChainedValues {
+		if ref.SourceLocation == SourceLocationHeader && ref.Context != nil && !strings.EqualFold(ref.HeaderName, "Authorization") {
+			op.addParameter(http.CanonicalHeaderKey(ref.HeaderName), "header", &JSONSchema{Types: []string{"string"}})
+		}
+	}
+	for _, header := range request.Headers {
+		if strings.EqualFold(header.Name, "Authorization") {
+			b.addSecurity(op, header.Value)
+		}
+	}
+
+	if request.PostData != nil && request.PostData.Text != "" {
+		mediaType, schema := bodySchema(request.PostData.MimeType, request.PostData.Text)
+		if op.RequestBody == nil {
+			op.RequestBody = &OpenAPIRequestBody{Required: true, Content: make(map[string]*OpenAPIMediaType)}
+		}
+		op.RequestBody.Content[mediaType] = mergeMediaType(op.RequestBody.Content[mediaType], schema)
+	}
+
+	response := callDetails.Entry.Response
+	status := "default"
+	if response.Status != 0 {
+		status = strconv.Itoa(response.Status)
+	}
+	resp := op.Responses[status]
+	if resp == nil {
+		description := response.StatusText
+		if description == "" {
+			description = http.StatusText(response.Status)
+		}
+		if description == "" {
+			description = "Response"
+		}
+		resp = &OpenAPIResponse{Description: description}
+		op.Responses[status] = resp
+	}
+	if response.Content.Text != "" {
+		mediaType, schema := bodySchema(response.Content.MimeType, response.Content.Text)
+		if resp.Content == nil {
+			resp.Content = make(map[string]*OpenAPIMediaType)
+		}
+		resp.Content[mediaType] = mergeMediaType(resp.Content[mediaType], schema)
+	}
+}
+
+// operationID derives a unique camelCase operation id from the call name.
+func (b *openAPIBuilder) operationID(callDetails *CallDetails) string {
+	name := callDetails.Name
+	if name == "" {
+		name = callDetails.Entry.Request.Method + " " + callDetails.Entry.Request.URL
+	}
+	words := splitWords(name)
+	id := "operation"
+	if len(words) > 0 {
+		id = strings.ToLower(words[0])
+		for _, word := range words[1:] {
+			id += strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+		}
+	}
+	unique := id
+	for i := 2; b.operationIDs[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", id, i)
+	}
+	b.operationIDs[unique] = true
+	return unique
+}
+
+// addSecurity declares the HTTP authentication scheme of an Authorization header and requires it for op.
+func (b *openAPIBuilder) addSecurity(op *OpenAPIOperation, value string) {
+	scheme, _, _ := strings.Cut(value, " ")
+	scheme = strings.ToLower(scheme)
+	if scheme != "bearer" && scheme != "basic" {
+		return
+	}
+	name := scheme + "Auth"
+	if b.doc.Components == nil {
+		b.doc.Components = &OpenAPIComponents{SecuritySchemes: make(map[string]*OpenAPISecurityScheme)}
+	}
+	b.doc.Components.SecuritySchemes[name] = &OpenAPISecurityScheme{Type: "http", Scheme: scheme}
+	for _, requirement := range op.Security {
+		if _, ok := requirement[name]; ok {
+			return
+		}
+	}
+	op.Security = append(op.Security, map[string][]string{name: {}})
+}
+
+// addParameter adds a parameter unless the operation already has it.
+func (op *OpenAPIOperation) addParameter(name string, in string, schema *JSONSchema) {
+	for _, p := range op.Parameters {
+		if p.Name == name && p.In == in {
+			return
+		}
+	}
+	op.Parameters = append(op.Parameters, &OpenAPIParameter{Name: name, In: in, Required: in == "path", Schema: schema})
+}
+
+// addLinks adds a link from the operation producing a chained value to each operation using it.
+// Usages in path, query and header parameters map to the parameter; other usages are only described.
+func (b *openAPIBuilder) addLinks(cv *ChainedValueContext) {
+	if cv.ExternalSource {
+		return
+	}
+	var source *ValueReference
+	for _, usage := range cv.AllUsages {
+		if usage.SourceType == SourceTypeResponse {
+			source = usage
+			break
+		}
+	}
+	if source == nil || source.Source == nil || b.callOps[source.Source] == nil {
+		return
+	}
+	sourceOp := b.callOps[source.Source]
+	status := "default"
+	if source.Source.Entry.Response.Status != 0 {
+		status = strconv.Itoa(source.Source.Entry.Response.Status)
+	}
+	resp := sourceOp.Responses[status]
+	expression, ok := openAPIRuntimeExpression(source)
+
+	for _, usage := range cv.AllUsages {
+		if usage.SourceType != SourceTypeRequest || usage.Source == nil || b.callOps[usage.Source] == nil {
+			continue
+		}
+		targetOp := b.callOps[usage.Source]
+		key := cv.VariableName + "_" + targetOp.OperationID
+		if resp.Links == nil {
+			resp.Links = make(map[string]*OpenAPILink)
+		}
+		link := resp.Links[key]
+		if link == nil {
+			link = &OpenAPILink{OperationID: targetOp.OperationID}
+			resp.Links[key] = link
+		}
+
+		// A path parameter is the variable itself even when it is embedded in a segment; elsewhere the
+		// parameter carries the surrounding text too, which a runtime expression cannot reproduce.
+		param, in := openAPIUsageParameter(usage)
+		if ok && param != "" && usage.Transform == "" && (usage.MatchLength == 0 || in == "path") {
+			if link.Parameters == nil {
+				link.Parameters = make(map[string]string)
+			}
+			link.Parameters[in+"."+param] = expression
+		}
+		location := openAPIUsageDescription(usage)
+		if link.Description == "" {
+			link.Description = fmt.Sprintf("%s is used in %s", cv.VariableName, location)
+		} else if !strings.Contains(link.Description, location) {
+			link.Description += ", " + location
+		}
+	}
+}
+
+// openAPIRuntimeExpression returns the runtime expression reading a response value: a JSON pointer into
+// the body or a header. A path with filters or wildcards falls back to the recorded path it was refined
+// from, when that one is definite. Cookies and JWT claims have no runtime expression.
+func openAPIRuntimeExpression(ref *ValueReference) (string, bool) {
+	if ref.JWTParent != nil {
+		return "", false
+	}
+	switch ref.SourceLocation {
+	case SourceLocationHeader:
+		return "$response.header." + ref.HeaderName, true
+	case SourceLocationBodyJson:
+		expr, err := ParseReferencePath(ref.ReferencePath)
+		if (err != nil || expr.Query || !expr.Definite()) && ref.Context != nil && ref.Context.OriginalPath != "" {
+			expr, err = ParseReferencePath(ref.Context.OriginalPath)
+		}
+		if err != nil || expr.Query || !expr.Definite() {
+			return "", false
+		}
+		var pointer strings.Builder
+		for _, st := range expr.Steps {
+			pointer.WriteString("/")
+			if st.kind == stepIndex {
+				pointer.WriteString(strconv.Itoa(st.index))
+			} else {
+				pointer.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(st.key))
+			}
+		}
+		return "$response.body#" + pointer.String(), true
+	}
+	return "", false
+}
+
+// openAPIUsageParameter returns the parameter a request usage fills and where it is, or "" for usages in
+// the body or cookies. Path parameters are named after the variable, as in the templated path.
+func openAPIUsageParameter(usage *ValueReference) (string, string) {
+	switch {
+	case usage.SourceLocation == SourceLocationHeader && !strings.EqualFold(usage.HeaderName, "Authorization"):
+		return http.CanonicalHeaderKey(usage.HeaderName), "header"
+	case strings.HasPrefix(usage.ReferencePath, "path["):
+		return usage.Context.VariableName, "path"
+	case strings.HasPrefix(usage.ReferencePath, "query."):
+		key := strings.TrimPrefix(usage.ReferencePath, "query.")
+		if i := strings.LastIndex(key, "["); i >= 0 {
+			key = key[:i]
+		}
+		return key, "query"
+	}
+	return "", ""
+}
+
+// openAPIUsageDescription describes where a request uses a chained value.
+func openAPIUsageDescription(usage *ValueReference) string {
+	switch {
+	case usage.SourceLocation == SourceLocationCookie:
+		return "cookie " + usage.CookieName
+	case usage.SourceLocation == SourceLocationHeader:
+		return "header " + usage.HeaderName
+	case usage.SourceLocation == SourceLocationBodyJson || usage.SourceLocation == SourceLocationBodyForm:
+		return "body " + usage.ReferencePath
+	}
+	return "URL " + usage.ReferencePath
+}
+
+// bodySchema returns the media type of a body and its inferred schema: JSON bodies are inferred from their
+// values, form bodies are objects of strings and anything else is a string.
+func bodySchema(mimeType string, text string) (string, *JSONSchema) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(text), &v); err == nil {
+			return mediaType, inferSchema(v)
+		}
+	}
+	if mediaType == "application/x-www-form-urlencoded" {
+		schema := &JSONSchema{Types: []string{"object"}, Properties: make(map[string]*JSONSchema)}
+		for _, pair := range decodeFormBody(text) {
+			if _, ok := schema.Properties[pair[0]]; !ok {
+				schema.Properties[pair[0]] = &JSONSchema{Types: []string{"string"}}
+				schema.Required = append(schema.Required, pair[0])
+			}
+		}
+		return mediaType, schema
+	}
+	return mediaType, &JSONSchema{Types: []string{"string"}}
+}
+
+func mergeMediaType(existing *OpenAPIMediaType, schema *JSONSchema) *OpenAPIMediaType {
+	if existing == nil {
+		return &OpenAPIMediaType{Schema: schema}
+	}
+	existing.Schema = mergeSchemas(existing.Schema, schema)
+	return existing
+}
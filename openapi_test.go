@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPILinkParameters(t *testing.T) {
+	har := HAR{Log: Log{Entries: []Entry{
+		jsonEntry("POST", "https://api.example.com/v1/orders", "application/json", `{"sku": "A1"}`,
+			`{"order": {"id": "ORD7731"}, "travelers": [{"type": "CHD", "id": "t-4412"}, {"type": "ADT", "id": "t-9087"}]}`),
+		jsonEntry("GET", "https://api.example.com/v1/orders/ref-ORD7731?traveler=t-9087", "", "", `{}`),
+	}}}
+	calls, values := analyzeTestHar(t, har)
+
+	var traveler *ChainedValueContext
+	for _, cv := range values {
+		if cv.ValueSource.Value == "t-9087" {
+			traveler = cv
+		}
+	}
+	if traveler == nil || traveler.ValueSource.ReferencePath != "$.travelers[?(@.type=='ADT')].id" {
+		t.Fatalf("traveler id was not refined to a predicate path: %+v", traveler)
+	}
+
+	doc := BuildOpenAPIDocument(calls, values)
+	if doc.Paths["/v1/orders/ref-{orderId}"] == nil {
+		t.Fatalf("embedded path parameter was not templated, paths: %v", sortedKeys(doc.Paths))
+	}
+	links := doc.Paths["/v1/orders"]["post"].Responses["200"].Links
+	want := map[string]map[string]string{
+		"orderId_getV1OrdersRefOrderId":    {"path.orderId": "$response.body#/order/id"},
+		"travelerId_getV1OrdersRefOrderId": {"query.traveler": "$response.body#/travelers/1/id"},
+	}
+	for key, parameters := range want {
+		link := links[key]
+		if link == nil {
+			t.Errorf("missing link %s, links: %v", key, sortedKeys(links))
+			continue
+		}
+		if !reflect.DeepEqual(link.Parameters, parameters) {
+			t.Errorf("link %s parameters = %v, want %v", key, link.Parameters, parameters)
+		}
+	}
+}
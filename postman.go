@@ -1,9 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/url"
 	"os"
 	"strings"
@@ -89,7 +89,7 @@ func ReplaceChainedValuesInRequest(request *CallDetails) PostmanRequest {
 	requestUrl := BuildPostmanURL(request)
 	// Replace chained values in the request headers
 	var headers []PostmanHeader
-	for _, header := range request.Entry.Request.Headers {
+	for _, header := range requestHeaders(request) {
 		if shouldSkipHeader(header) {
 			continue
 		}
@@ -100,10 +100,20 @@ func ReplaceChainedValuesInRequest(request *CallDetails) PostmanRequest {
 	}
 	// Replace chained values in the request body
 	var body PostmanRequestBody
-	if request.Entry.Request.PostData != nil {
+	if postData := request.Entry.Request.PostData; postData != nil && postData.MimeType == "application/x-www-form-urlencoded" {
+		// Chained values appear decoded in form fields; Postman encodes the fields when sending.
+		body.Mode = "urlencoded"
+		for _, pair := range decodeFormBody(postData.Text) {
+			body.Urlencoded = append(body.Urlencoded, PostmanKeyValue{
+				Key:   pair[0],
+				Value: ReplaceValuesInString(pair[1], request.RequestChainedValues),
+				Type:  "text",
+			})
+		}
+	} else if postData != nil {
 		body = PostmanRequestBody{
 			Mode: "raw",
-			Raw:  ReplaceValuesInString(request.Entry.Request.PostData.Text, request.RequestChainedValues),
+			Raw:  ReplaceValuesInString(postData.Text, request.RequestChainedValues),
 		}
 	}
 
@@ -132,19 +142,17 @@ func shouldSkipHeader(header Header) bool {
 }
 
 // ReplaceValuesInString replaces all occurrences of specified values in an input string with corresponding Postman variable placeholders.
-// It iterates over the provided ValueReference instances to perform the substitutions.
+// It iterates over the provided ValueReference instances to perform the substitutions. Values that are only embedded
+// in a larger string have just the matched portion replaced.
 func ReplaceValuesInString(input string, valueToVariableName []*ValueReference) string {
 	// TODO: take into account the type (JSON or form) of the body and s=use the paths to replace the values instead of the values themselves.
-	for _, v := range valueToVariableName {
-		valueString := fmt.Sprintf("%v", v.Value)
-		if v.Context == nil {
-			// log and continue
-			log.Printf("Value %v has no context", valueString)
-			continue
-		}
-		input = strings.ReplaceAll(input, valueString, "{{"+v.Context.VariableName+"}}")
-	}
-	return input
+	return replaceChainedValues(input, valueToVariableName, postmanPlaceholder)
+}
+
+// postmanPlaceholder returns the Postman variable reference for a chained value,
+// referring to the encoded variant when the usage is encoded.
+func postmanPlaceholder(ref *ValueReference) string {
+	return "{{" + transformedVariableName(ref) + "}}"
 }
 
 // BuildPostmanURL builds a PostmanURL struct for a Postman request.
@@ -167,36 +175,21 @@ func BuildPostmanURL(callDetails *CallDetails) PostmanURL {
 	for _, component := range pathComponents {
 		if component != "" {
 			// Replace path parameters with Postman variables
-			for _, ref := range callDetails.RequestChainedValues {
-				valueString := fmt.Sprintf("%v", ref.Value)
-				if component == valueString {
-					component = "{{" + ref.Context.VariableName + "}}"
-					break
-				}
-			}
+			component, _ = substituteChainedValue(component, callDetails.RequestChainedValues, postmanPlaceholder)
 			path = append(path, component)
 		}
 	}
 	postmanURL.Path = path
 
-	// Split the query string into individual components
-	queryComponents := parsedURL.Query()
+	// Split the query string into individual components, kept encoded as in the raw URL
 	var query []PostmanQueryParam
-	for key, values := range queryComponents {
-		for _, value := range values {
-			// Replace query parameters with Postman variables
-			for _, ref := range callDetails.RequestChainedValues {
-				valueString := fmt.Sprintf("%v", ref.Value)
-				if value == valueString {
-					value = "{{" + ref.Context.VariableName + "}}"
-					break
-				}
-			}
-			query = append(query, PostmanQueryParam{
-				Key:   key,
-				Value: value,
-			})
-		}
+	for _, pair := range rawQueryPairs(parsedURL.RawQuery) {
+		// Replace query parameters with Postman variables
+		value, _ := substituteChainedValue(pair[1], callDetails.RequestChainedValues, postmanPlaceholder)
+		query = append(query, PostmanQueryParam{
+			Key:   pair[0],
+			Value: value,
+		})
 	}
 	postmanURL.Query = query
 
@@ -206,61 +199,15 @@ func BuildPostmanURL(callDetails *CallDetails) PostmanURL {
 // CreateTestScript generates a Postman test script event to extract values from responses.
 // It creates JavaScript code that retrieves values from the response JSON and sets them as collection variables.
 func CreateTestScript(chainedValues []*ValueReference) PostmanEvent {
-	var scriptLines []string
-	scriptLines = append(scriptLines, "var responseJson = pm.response.json();")
-
-	usedVariables := make(map[string]bool)
-
-	for _, chainedValue := range chainedValues {
-		if chainedValue.SourceType != SourceTypeResponse {
-			continue
-		}
-		if chainedValue.Context == nil {
-			continue
-		}
-		variableName := chainedValue.Context.VariableName
-		if usedVariables[variableName] {
-			continue
-		}
-		usedVariables[variableName] = true
-
-		scriptLines = append(scriptLines, buildScriptForVariable(chainedValue)...)
-	}
-
 	return PostmanEvent{
 		Listen: "test",
 		Script: PostmanEventScript{
 			Type: "text/javascript",
-			Exec: scriptLines,
+			Exec: postmanDialect.responseScriptLines(chainedValues),
 		},
 	}
 }
 
-// buildScriptForVariable constructs JavaScript code snippets for extracting a single variable from the response.
-// It includes error handling and sets the extracted value as a Postman collection variable.
-func buildScriptForVariable(chainedValue *ValueReference) []string {
-	var scriptLines []string
-	collectionVarName := chainedValue.Context.VariableName
-
-	// Build JavaScript code to extract the value with error handling
-	jsPath := chainedValue.ReferencePath
-	scriptLines = append(scriptLines, "try {")
-
-	valueExtraction := fmt.Sprintf("  var %s = %s;", collectionVarName, jsPath)
-	scriptLines = append(scriptLines, valueExtraction)
-
-	setVariable := fmt.Sprintf("  pm.collectionVariables.set(\"%s\", %s);", collectionVarName, collectionVarName)
-	scriptLines = append(scriptLines, setVariable)
-	printToConsole := fmt.Sprintf("  console.log('Variable: %s, Value:', %s);", collectionVarName, collectionVarName)
-	scriptLines = append(scriptLines, printToConsole)
-	scriptLines = append(scriptLines, "} catch (e) {")
-	logError := fmt.Sprintf("  console.error('Error extracting variable %s:', e);", collectionVarName)
-	scriptLines = append(scriptLines, logError)
-	scriptLines = append(scriptLines, "}")
-
-	return scriptLines
-}
-
 // BuildPostmanCollection assembles the complete Postman collection.
 // It iterates over the processed call details to create Postman items (requests).
 // It incorporates variable replacements and test scripts into each item and adds collection variables.
@@ -299,8 +246,8 @@ func BuildPostmanCollection(callDetailsList []*CallDetails, chainedValues []*Cha
 		items = append(items, item)
 	}
 
-	variables := make([]PostmanVariable, len(chainedValues))
-	for i, chainedValue := range chainedValues {
+	var variables []PostmanVariable
+	for _, chainedValue := range chainedValues {
 		var description string
 		if chainedValue.ValueSource != nil {
 			description = chainedValue.ValueSource.ReferencePath
@@ -308,10 +255,16 @@ func BuildPostmanCollection(callDetailsList []*CallDetails, chainedValues []*Cha
 			description = "Manually set variable"
 		}
 
-		variables[i] = PostmanVariable{
+		variables = append(variables, PostmanVariable{
 			Key: chainedValue.VariableName,
 			//Value: fmt.Sprintf("%v", chainedValue.Value),
 			Description: description,
+		})
+		for _, t := range usedTransforms(chainedValue) {
+			variables = append(variables, PostmanVariable{
+				Key:         chainedValue.VariableName + t.VariableSuffix(),
+				Description: fmt.Sprintf("%s encoded as %s", chainedValue.VariableName, t.Name()),
+			})
 		}
 	}
 
@@ -365,10 +318,14 @@ func CreateInitScript(values []*ChainedValueContext) *PostmanEvent {
 
 // WriteCollectionToFile serializes the Postman collection into JSON format with proper indentation.
 // It writes the JSON data to the specified filename and returns any errors encountered.
+// HTML escaping is disabled so that scripts keep their => and && operators readable.
 func WriteCollectionToFile(collection PostmanCollection, filename string) error {
-	data, err := json.MarshalIndent(collection, "", "  ")
-	if err != nil {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(collection); err != nil {
 		return err
 	}
-	return os.WriteFile(filename, data, 0644)
+	return os.WriteFile(filename, buf.Bytes(), 0644)
 }
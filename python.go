@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pythonKeywords are the reserved words that cannot name a step function.
+var pythonKeywords = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true, "except": true, "finally": true,
+	"for": true, "from": true, "global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+	"False": true, "None": true, "True": true,
+}
+
+// pythonNonIdentifierChars matches the runs of characters replaced by underscores in function names.
+var pythonNonIdentifierChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// pythonExporter writes a pytest module replaying the calls with a requests.Session. Each call is a function
+// taking the session and a dict of chained values, which it reads from and adds to; a single test runs the
+// functions in order.
+type pythonExporter struct{}
+
+func (pythonExporter) Name() string          { return "pytest" }
+func (pythonExporter) Description() string   { return "Python pytest module using requests" }
+func (pythonExporter) DefaultOutput() string { return "test_flow.py" }
+func (pythonExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	script := BuildPythonTest(callDetailsList, chainedValues)
+	if err := os.WriteFile(outputPath, []byte(script), 0644); err != nil {
+		return fmt.Errorf("error writing Python test: %w", err)
+	}
+	return nil
+}
+
+// pythonModule tracks the imports and helpers a generated module needs.
+type pythonModule struct {
+	imports   map[string]bool
+	jwtHelper bool
+
+	// readsBody is set when an extraction of the current step reads the decoded body.
+	readsBody bool
+}
+
+// BuildPythonTest renders the calls as a pytest module.
+func BuildPythonTest(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext) string {
+	module := &pythonModule{imports: map[string]bool{}}
+
+	var functions []string
+	var calls []string
+	used := make(map[string]bool)
+	extracted := make(map[string]bool)
+	for _, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		name := pythonFunctionName(callDetails, used)
+		functions = append(functions, module.stepFunction(name, callDetails, extracted))
+		calls = append(calls, fmt.Sprintf("        %s(session, ctx)", name))
+	}
+
+	var lines []string
+	lines = append(lines, `"""Replays a recorded HAR flow with requests. Generated by chainer; run it with pytest."""`, "")
+	for _, imp := range []string{"base64", "json", "os", "urllib.parse"} {
+		if module.imports[imp] || imp == "os" {
+			lines = append(lines, "import "+imp)
+		}
+	}
+	lines = append(lines, "", "import requests", "")
+	if module.jwtHelper {
+		lines = append(lines, "",
+			"def _jwt_part(token, index):",
+			`    """Decodes the header (0) or payload (1) of a JWT."""`,
+			`    part = token.removeprefix("Bearer ").split(".")[index]`,
+			`    return json.loads(base64.urlsafe_b64decode(part + "=" * (-len(part) % 4)))`,
+			"")
+	}
+	for _, fn := range functions {
+		lines = append(lines, "", fn)
+	}
+
+	lines = append(lines, "", "def test_recorded_flow():", `    """Runs the recorded calls in order, passing chained values between them in ctx."""`, "    ctx = {")
+	for _, cv := range chainedValues {
+		if !cv.ExternalSource {
+			continue
+		}
+		if cv.InitScript != "" {
+			log.Printf("pytest: the initializer of %s is not run; set the %s environment variable instead", cv.VariableName, cv.VariableName)
+		}
+		lines = append(lines, fmt.Sprintf("        %s: os.environ.get(%s, %s),", pyString(cv.VariableName), pyString(cv.VariableName), pyString(cv.Value)))
+	}
+	lines = append(lines, "    }", "    with requests.Session() as session:")
+	lines = append(lines, calls...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// pythonFunctionName derives a unique snake_case function name from the call name. Names that pytest
+// would collect as tests are prefixed with "step_".
+func pythonFunctionName(callDetails *CallDetails, used map[string]bool) string {
+	name := callDetails.Name
+	if name == "" {
+		name = callDetails.Entry.Request.Method + " " + callDetails.Entry.Request.URL
+	}
+	name = strings.Trim(pythonNonIdentifierChars.ReplaceAllString(strings.ToLower(splitCamelCase(name)), "_"), "_")
+	if name == "" || pythonKeywords[name] || name[0] >= '0' && name[0] <= '9' || strings.HasPrefix(name, "test") {
+		name = "step_" + name
+	}
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// splitCamelCase inserts a space before each upper-case letter that follows a lower-case letter or digit,
+// so that "createOrder" becomes "create Order".
+func splitCamelCase(s string) string {
+	var sb strings.Builder
+	var prev rune
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' && (prev >= 'a' && prev <= 'z' || prev >= '0' && prev <= '9') {
+			sb.WriteRune(' ')
+		}
+		sb.WriteRune(r)
+		prev = r
+	}
+	return sb.String()
+}
+
+// stepFunction renders one call as a function that sends the request, asserts the recorded status and
+// stores the chained values of the response in ctx.
+func (m *pythonModule) stepFunction(name string, callDetails *CallDetails, extracted map[string]bool) string {
+	request := callDetails.Entry.Request
+	refs := callDetails.RequestChainedValues
+
+	description := callDetails.Name
+	if description == "" {
+		description = request.Method + " " + request.URL
+	}
+
+	lines := []string{
+		fmt.Sprintf("def %s(session, ctx):", name),
+		"    " + pyDocString(description),
+		"    resp = session.request(",
+		"        " + pyString(request.Method) + ",",
+		"        " + m.template(request.URL, refs) + ",",
+	}
+
+	var headers, cookies []string
+	for _, header := range requestHeaders(callDetails) {
+		if shouldSkipHeader(header) || strings.HasPrefix(header.Name, ":") || clientManagedHeaders[http.CanonicalHeaderKey(header.Name)] {
+			continue
+		}
+		if strings.EqualFold(header.Name, "Cookie") {
+			// Cookies are passed separately, since requests ignores the session's cookies when a Cookie header is set.
+			for _, pair := range strings.Split(header.Value, ";") {
+				cookieName, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if cookieName != "" {
+					cookies = append(cookies, fmt.Sprintf("            %s: %s,", pyString(cookieName), m.template(value, refs)))
+				}
+			}
+			continue
+		}
+		headers = append(headers, fmt.Sprintf("            %s: %s,", pyString(header.Name), m.template(header.Value, refs)))
+	}
+	if len(headers) > 0 {
+		lines = append(lines, "        headers={")
+		lines = append(lines, headers...)
+		lines = append(lines, "        },")
+	}
+	if len(cookies) > 0 {
+		lines = append(lines, "        cookies={")
+		lines = append(lines, cookies...)
+		lines = append(lines, "        },")
+	}
+
+	if request.PostData != nil && request.PostData.Text != "" {
+		if request.PostData.MimeType == "application/x-www-form-urlencoded" {
+			// requests encodes the fields itself, so chained values are substituted in the decoded values.
+			lines = append(lines, "        data=[")
+			for _, pair := range decodeFormBody(request.PostData.Text) {
+				lines = append(lines, fmt.Sprintf("            (%s, %s),", pyString(pair[0]), m.template(pair[1], refs)))
+			}
+			lines = append(lines, "        ],")
+		} else {
+			lines = append(lines, fmt.Sprintf("        data=(%s).encode(),", m.template(request.PostData.Text, refs)))
+		}
+	}
+	lines = append(lines, "        timeout=30,", "    )")
+
+	if status := callDetails.Entry.Response.Status; status != 0 {
+		lines = append(lines, fmt.Sprintf("    assert resp.status_code == %d, resp.text", status))
+	}
+
+	parsedBody := false
+	m.readsBody = false
+	for _, ref := range callDetails.ResponseChainedValues {
+		if ref.SourceType != SourceTypeResponse || ref.Context == nil || extracted[ref.Context.VariableName] {
+			continue
+		}
+		cv := ref.Context
+		extracted[cv.VariableName] = true
+		expr, err := m.extraction(ref)
+		if err != nil {
+			log.Printf("pytest: %s keeps its recorded value because %s cannot be read: %v", cv.VariableName, ref.ReferencePath, err)
+			expr = pyString(cv.Value)
+		}
+		if m.readsBody && !parsedBody {
+			lines = append(lines, "    data = resp.json()")
+			parsedBody = true
+		}
+		lines = append(lines, fmt.Sprintf("    ctx[%s] = %s", pyString(cv.VariableName), expr))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// extraction returns the Python expression reading a response value, where resp is the response and
+// data its decoded JSON body.
+func (m *pythonModule) extraction(ref *ValueReference) (string, error) {
+	switch {
+	case ref.JWTParent != nil:
+		token, err := m.extraction(ref.JWTParent)
+		if err != nil {
+			return "", err
+		}
+		expr, err := jwtClaimExpr(ref.JWTClaimPath)
+		if err != nil {
+			return "", err
+		}
+		access, err := pythonAccess(expr, fmt.Sprintf("_jwt_part(%s, %d)", token, jwtPartIndex(ref.JWTClaimPath)))
+		if err != nil {
+			return "", err
+		}
+		m.jwtHelper = true
+		m.imports["base64"] = true
+		m.imports["json"] = true
+		return fmt.Sprintf("str(%s)", access), nil
+	case ref.SourceLocation == SourceLocationCookie:
+		return fmt.Sprintf("resp.cookies[%s]", pyString(ref.CookieName)), nil
+	case ref.SourceLocation == SourceLocationHeader:
+		if strings.EqualFold(ref.HeaderName, "Authorization") {
+			return fmt.Sprintf("resp.headers[%s].removeprefix(\"Bearer \")", pyString(ref.HeaderName)), nil
+		}
+		return fmt.Sprintf("resp.headers[%s]", pyString(ref.HeaderName)), nil
+	}
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		return "", err
+	}
+	access, err := pythonAccess(expr, "data")
+	if err != nil {
+		return "", err
+	}
+	m.readsBody = true
+	return fmt.Sprintf("str(%s)", access), nil
+}
+
+// pythonAccess renders an expression as Python subscripts on root. Filters select the first matching
+// element with next(); wildcards and recursive descent are rejected.
+func pythonAccess(expr *ReferenceExpr, root string) (string, error) {
+	if expr.Query && expr.QueryIndex != 0 {
+		return "", fmt.Errorf("only the first query result can be selected, not [%d]", expr.QueryIndex)
+	}
+	result := root
+	for _, st := range expr.Steps {
+		switch st.kind {
+		case stepKey:
+			result += "[" + pyString(st.key) + "]"
+		case stepIndex:
+			result += fmt.Sprintf("[%d]", st.index)
+		case stepFilter:
+			var conds []string
+			for _, cond := range st.filter {
+				left := "e"
+				for i, key := range cond.path {
+					if i < len(cond.path)-1 {
+						left += fmt.Sprintf(".get(%s, {})", pyString(key))
+					} else {
+						left += fmt.Sprintf(".get(%s)", pyString(key))
+					}
+				}
+				switch cond.op {
+				case "":
+					conds = append(conds, left+" is not None")
+				case "==":
+					conds = append(conds, left+" == "+pyLiteral(cond.value))
+				case "!=":
+					conds = append(conds, left+" != "+pyLiteral(cond.value))
+				}
+			}
+			result = fmt.Sprintf("next(e for e in %s if %s)", result, strings.Join(conds, " and "))
+		default:
+			return "", fmt.Errorf("wildcards and recursive descent are not supported")
+		}
+	}
+	return result, nil
+}
+
+// template renders a recorded string as a Python expression concatenating literals and chained values
+// from ctx, applying the encoding each usage needs.
+func (m *pythonModule) template(s string, refs []*ValueReference) string {
+	var parts []string
+	for _, segment := range splitChainedValues(s, refs) {
+		if segment.ref == nil {
+			parts = append(parts, pyString(segment.literal))
+			continue
+		}
+		parts = append(parts, m.transform(segment.ref.Transform, fmt.Sprintf("ctx[%s]", pyString(segment.ref.Context.VariableName))))
+	}
+	if len(parts) == 0 {
+		return `""`
+	}
+	return strings.Join(parts, " + ")
+}
+
+// transform applies a value transform to the Python expression expr.
+func (m *pythonModule) transform(transform string, expr string) string {
+	switch transform {
+	case "urlencoded":
+		m.imports["urllib.parse"] = true
+		return fmt.Sprintf("urllib.parse.quote(%s, safe=\"-_.!~*'()\")", expr)
+	case "base64":
+		m.imports["base64"] = true
+		return fmt.Sprintf("base64.b64encode(%s.encode()).decode()", expr)
+	case "base64url":
+		m.imports["base64"] = true
+		return fmt.Sprintf("base64.urlsafe_b64encode(%s.encode()).decode().rstrip(\"=\")", expr)
+	case "json_escaped":
+		m.imports["json"] = true
+		return fmt.Sprintf("json.dumps(%s, ensure_ascii=False)[1:-1]", expr)
+	}
+	return expr
+}
+
+// pyLiteral renders a decoded JSON scalar as a Python literal.
+func pyLiteral(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return pyString(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		if val {
+			return "True"
+		}
+		return "False"
+	}
+	return "None"
+}
+
+// pyString renders s as a Python string literal, using single quotes when s contains double quotes.
+func pyString(s string) string {
+	quote := '"'
+	if strings.ContainsRune(s, '"') && !strings.ContainsRune(s, '\'') {
+		quote = '\''
+	}
+	var sb strings.Builder
+	sb.WriteRune(quote)
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == quote:
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune(quote)
+	return sb.String()
+}
+
+// pyDocString renders text as a one-line docstring.
+func pyDocString(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"""`, `\"\"\"`)
+	if strings.HasSuffix(text, `"`) {
+		text += " "
+	}
+	return `"""` + text + `"""`
+}
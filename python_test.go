@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPythonStrings(t *testing.T) {
+	refs := []*ValueReference{{Value: "abc123", Context: &ChainedValueContext{VariableName: "token"}}}
+	python := &pythonModule{imports: map[string]bool{}}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"template", python.template(trickyText, refs), `"a'b\"c $HOME ` + "`id`" + ` \\ ${x}\n\t" + ctx["token"]`},
+		{"empty template", python.template("", nil), `""`},
+		{"single quotes", pyString(`say "hi"`), `'say "hi"'`},
+		{"both quotes", pyString(`it's "x"`), `"it's \"x\""`},
+		{"control characters", pyString("\x00\x7f"), `"\x00\x7f"`},
+		{"docstring", pyDocString(`ends with "quote"`), `"""ends with "quote" """`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPythonTest(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	module := BuildPythonTest(callDetailsList, chainedValues)
+	for _, want := range []string{
+		`ctx["dataToken"] = str(data["data"]["token"])`,
+		`"Authorization": "Bearer " + ctx["dataToken"],`,
+		`ctx["orderId"] = str(data["order"]["id"])`,
+		`"https://api.example.com/v1/orders/" + ctx["orderId"],`,
+	} {
+		if !strings.Contains(module, want) {
+			t.Errorf("missing %q in:\n%s", want, module)
+		}
+	}
+}
+
+func TestBuildPythonTestUnreadablePath(t *testing.T) {
+	callDetailsList, chainedValues := analyzeTestHar(t, chainTestHar())
+	callDetailsList[1].ResponseChainedValues[0].ReferencePath = "order["
+	logged := captureLog(t)
+
+	module := BuildPythonTest(callDetailsList, chainedValues)
+	if want := `ctx["orderId"] = "ord55123"`; !strings.Contains(module, want) {
+		t.Errorf("missing the recorded value %q in:\n%s", want, module)
+	}
+	if !strings.Contains(logged.String(), "pytest: orderId keeps its recorded value") {
+		t.Errorf("fallback to the recorded value was not logged: %q", logged.String())
+	}
+}
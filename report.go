@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// reportExporter writes a self-contained HTML report of the analysis: the calls, every chained value with
+// its source, original and refined paths and usages, and the candidate values that were dropped.
+type reportExporter struct{}
+
+func (reportExporter) Name() string          { return "html" }
+func (reportExporter) Description() string   { return "HTML analysis report" }
+func (reportExporter) DefaultOutput() string { return "report.html" }
+func (e reportExporter) Export(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, outputPath string) error {
+	return e.ExportWithDropped(callDetailsList, chainedValues, nil, outputPath)
+}
+
+// ExportWithDropped writes the report, listing the dropped values in their own table.
+func (reportExporter) ExportWithDropped(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("error writing HTML report: %w", err)
+	}
+	defer file.Close()
+	if err := reportTemplate.Execute(file, buildReportData(callDetailsList, chainedValues, dropped)); err != nil {
+		return fmt.Errorf("error writing HTML report: %w", err)
+	}
+	return file.Close()
+}
+
+type reportData struct {
+	Calls   []reportCall
+	Values  []reportValue
+	Dropped []reportDropped
+}
+
+type reportCall struct {
+	Label  string
+	Method string
+	URL    string
+	Status string
+	Uses   []string
+	Sets   []string
+}
+
+type reportValue struct {
+	Name         string
+	Value        string
+	External     bool
+	Source       string
+	SourcePath   string
+	OriginalPath string
+	Usages       []reportUsage
+}
+
+type reportUsage struct {
+	Call  string
+	Side  string
+	Path  string
+	Notes string
+}
+
+type reportDropped struct {
+	Value  string
+	Reason string
+	Usages []reportUsage
+}
+
+// buildReportData flattens the calls, chained values and dropped values into the rows of the report.
+func buildReportData(callDetailsList []*CallDetails, chainedValues []*ChainedValueContext, dropped []*DroppedValue) reportData {
+	var data reportData
+	labels := make(map[*CallDetails]string)
+	for i, callDetails := range callDetailsList {
+		if callDetails == nil {
+			continue
+		}
+		request := callDetails.Entry.Request
+		label := fmt.Sprintf("%02d", i+1)
+		if callDetails.Name != "" {
+			label += " " + callDetails.Name
+		}
+		labels[callDetails] = label
+
+		response := callDetails.Entry.Response
+		data.Calls = append(data.Calls, reportCall{
+			Label:  label,
+			Method: request.Method,
+			URL:    request.URL,
+			Status: strings.TrimSpace(fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status))),
+			Uses:   reportVariables(callDetails.RequestChainedValues),
+			Sets:   reportVariables(callDetails.ResponseChainedValues),
+		})
+	}
+
+	for _, cv := range chainedValues {
+		value := reportValue{
+			Name:     cv.VariableName,
+			Value:    cv.Value,
+			External: cv.ExternalSource,
+			Usages:   reportUsages(cv.AllUsages, labels),
+		}
+		if source := cv.ValueSource; source != nil {
+			value.Source = labels[source.Source]
+			value.SourcePath = reportPath(source)
+			if cv.OriginalPath != "" && cv.OriginalPath != source.ReferencePath {
+				value.OriginalPath = cv.OriginalPath
+			}
+		}
+		data.Values = append(data.Values, value)
+	}
+
+	for _, d := range dropped {
+		data.Dropped = append(data.Dropped, reportDropped{Value: d.Value, Reason: d.Reason, Usages: reportUsages(d.Usages, labels)})
+	}
+	return data
+}
+
+// reportVariables lists the distinct chained variables of a call's references.
+func reportVariables(refs []*ValueReference) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if ref.Context == nil || seen[ref.Context.VariableName] {
+			continue
+		}
+		seen[ref.Context.VariableName] = true
+		names = append(names, ref.Context.VariableName)
+	}
+	return names
+}
+
+func reportUsages(refs []*ValueReference, labels map[*CallDetails]string) []reportUsage {
+	var usages []reportUsage
+	for _, ref := range refs {
+		side := "Response"
+		if ref.SourceType == SourceTypeRequest {
+			side = "Request"
+		}
+		var notes []string
+		if ref.MatchLength > 0 {
+			notes = append(notes, fmt.Sprintf("embedded at offset %d", ref.MatchOffset))
+		}
+		if ref.Transform != "" {
+			notes = append(notes, ref.Transform)
+		}
+		usages = append(usages, reportUsage{Call: labels[ref.Source], Side: side, Path: reportPath(ref), Notes: strings.Join(notes, ", ")})
+	}
+	return usages
+}
+
+// reportPath returns the reference path of a value, prefixed with the token path for JWT claims.
+func reportPath(ref *ValueReference) string {
+	if ref.JWTParent != nil {
+		return reportPath(ref.JWTParent) + " → " + ref.JWTClaimPath
+	}
+	return ref.ReferencePath
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>chainer report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ccc; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+code { font-family: Menlo, Consolas, monospace; word-break: break-all; }
+ul { margin: 0; padding-left: 1.2em; }
+.muted { color: #888; }
+.external { color: #06c; }
+.changed { background: #fff6d5; }
+</style>
+</head>
+<body>
+<h1>chainer report</h1>
+<p>{{len .Calls}} calls, {{len .Values}} chained values, {{len .Dropped}} dropped values.</p>
+
+<h2>Calls</h2>
+<table>
+<tr><th>Call</th><th>Method</th><th>URL</th><th>Status</th><th>Uses</th><th>Sets</th></tr>
+{{range .Calls}}<tr>
+<td>{{.Label}}</td><td>{{.Method}}</td><td><code>{{.URL}}</code></td><td>{{.Status}}</td>
+<td>{{range $i, $v := .Uses}}{{if $i}}, {{end}}{{$v}}{{end}}</td>
+<td>{{range $i, $v := .Sets}}{{if $i}}, {{end}}{{$v}}{{end}}</td>
+</tr>
+{{end}}</table>
+
+<h2>Chained values</h2>
+<table>
+<tr><th>Variable</th><th>Value</th><th>Source</th><th>Source path</th><th>Original path</th><th>Usages</th></tr>
+{{range .Values}}<tr{{if .OriginalPath}} class="changed"{{end}}>
+<td>{{.Name}}</td><td><code>{{.Value}}</code></td>
+<td>{{if .External}}<span class="external">pre-defined</span>{{else}}{{.Source}}{{end}}</td>
+<td><code>{{.SourcePath}}</code></td>
+<td>{{if .OriginalPath}}<code>{{.OriginalPath}}</code>{{else}}<span class="muted">unchanged</span>{{end}}</td>
+<td>{{template "usages" .Usages}}</td>
+</tr>
+{{end}}</table>
+
+<h2>Dropped values</h2>
+<details>
+<summary>{{len .Dropped}} candidate values were not chained</summary>
+<table>
+<tr><th>Value</th><th>Reason</th><th>Occurrences</th></tr>
+{{range .Dropped}}<tr>
+<td><code>{{.Value}}</code></td><td>{{.Reason}}</td><td>{{template "usages" .Usages}}</td>
+</tr>
+{{end}}</table>
+</details>
+</body>
+</html>
+{{define "usages"}}<ul>{{range .}}<li>{{.Call}} {{.Side}} <code>{{.Path}}</code>{{if .Notes}} <span class="muted">({{.Notes}})</span>{{end}}</li>{{end}}</ul>{{end}}`))
@@ -1,91 +1,266 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"reflect"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// WithRetries takes a function f of any signature and an integer `retries`.
-// It returns a new function of the same signature that will:
-// 1) Retry calling f up to `retries` times if there is a panic or if f returns a non-nil error.
-// 2) If f does not return an error, then only panic retries apply.
-func WithRetries[T any](f T, retries int) T {
-	fv := reflect.ValueOf(f)
-	ft := fv.Type()
-
-	if ft.Kind() != reflect.Func {
-		panic("WithRetries expects a function")
-	}
-
-	// Check if the function returns an error as its last result.
-	// We'll store the index of the error result (if any).
-	var hasError bool
-	var errorIndex int
-	outCount := ft.NumOut()
-	if outCount > 0 {
-		lastOut := ft.Out(outCount - 1)
-		// Compare with the type of 'error' (i.e. the type underlying `error` interface).
-		hasError = (lastOut == reflect.TypeOf((*error)(nil)).Elem())
-		if hasError {
-			errorIndex = outCount - 1
-		}
+// RetryDecision tells Retry whether a failed attempt may be repeated.
+type RetryDecision int
+
+const (
+	// RetryDecisionStop means the error is permanent and retrying cannot help.
+	RetryDecisionStop RetryDecision = iota
+
+	// RetryDecisionRetry means the error is transient and the attempt may be repeated.
+	RetryDecisionRetry
+)
+
+// RetryPolicy describes how often and how quickly a failing operation is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+
+	// MaxDelay caps the exponential backoff delay.
+	MaxDelay time.Duration
+
+	// Multiplier is the factor applied to the delay after every retry.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction (0 to 1) in either direction.
+	Jitter float64
+
+	// MaxRetryAfter is the longest server-requested Retry-After delay that will be honored.
+	// Longer requests end the retries with the last error.
+	MaxRetryAfter time.Duration
+
+	// Budget, if set, limits the number of retries shared by every operation using it.
+	Budget *RetryBudget
+
+	// Classify decides whether an error is retryable. Nil means classifyError.
+	Classify func(error) RetryDecision
+}
+
+// RetryBudget is a retry allowance shared across operations, so that a failing backend
+// cannot multiply the total number of requests without bound.
+type RetryBudget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+// NewRetryBudget returns a budget that allows n retries in total.
+func NewRetryBudget(n int) *RetryBudget {
+	return &RetryBudget{remaining: n}
+}
+
+// take consumes one retry from the budget and reports whether one was available.
+func (b *RetryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
 	}
+	b.remaining--
+	return true
+}
 
-	// MakeFunc allows us to create a new function of the same type as ft,
-	// but we control how it is invoked internally.
-	wrapper := reflect.MakeFunc(ft, func(in []reflect.Value) (out []reflect.Value) {
-		for i := 0; i <= retries; i++ {
-			callOut, panicVal := safeCall(fv, in)
-
-			// If there was a panic, treat it like an error
-			if panicVal != nil {
-				if i < retries {
-					// Retry
-					continue
-				}
-				// No more retries left
-				if hasError {
-					// Fill the error return with the panic as an error
-					panicErr := fmt.Errorf("panic recovered: %v", panicVal)
-					callOut[errorIndex] = reflect.ValueOf(panicErr)
-					return callOut
-				}
-				// If there's no error in the signature, re-panic
-				panic(panicVal)
-			}
+// HTTPStatusError is returned for non-2xx HTTP responses. It carries the status code
+// used for classification and any delay requested through the Retry-After header.
+type HTTPStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
 
-			// No panic, check for error if the signature allows it
-			if hasError {
-				errVal := callOut[errorIndex]
-				if !errVal.IsNil() {
-					// We have a non-nil error
-					if i < retries {
-						// Retry
-						continue
-					}
-				}
-			}
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
 
-			// Either there's no error, or we've exhausted retries
-			return callOut
+// newHTTPStatusError builds an HTTPStatusError from a response and its already-read body.
+func newHTTPStatusError(resp *http.Response, body []byte) *HTTPStatusError {
+	return &HTTPStatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+// parseRetryAfter interprets a Retry-After header given either as seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
 		}
+	}
+	return 0
+}
+
+// retryableError and permanentError let callers override the default classification.
+type retryableError struct{ err error }
+type permanentError struct{ err error }
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
 
-		// Should never reach here because the loop returns on success or final attempt
+// markRetryable wraps err so that it is always retried, e.g. for unparsable model output.
+func markRetryable(err error) error {
+	if err == nil {
 		return nil
-	})
+	}
+	return &retryableError{err: err}
+}
 
-	// Convert the newly built wrapper back to the original type T
-	return wrapper.Interface().(T)
+// markPermanent wraps err so that it is never retried, e.g. for missing credentials.
+func markPermanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
 }
 
-// safeCall invokes fn.Call(in) but wraps it in a recover to capture panics.
-// Returns the results of the call plus any panic value as a separate interface{}.
-func safeCall(fn reflect.Value, in []reflect.Value) (ret []reflect.Value, panicVal interface{}) {
-	defer func() {
-		if r := recover(); r != nil {
-			panicVal = r
+// classifyError is the default classification: explicit markers win, then rate limiting,
+// server errors and network timeouts are retryable; everything else is permanent.
+func classifyError(err error) RetryDecision {
+	var retryable *retryableError
+	var permanent *permanentError
+	switch {
+	case errors.As(err, &permanent):
+		return RetryDecisionStop
+	case errors.As(err, &retryable):
+		return RetryDecisionRetry
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusRequestTimeout,
+			statusErr.StatusCode == http.StatusTooEarly,
+			statusErr.StatusCode == http.StatusTooManyRequests,
+			statusErr.StatusCode >= 500:
+			return RetryDecisionRetry
+		}
+		return RetryDecisionStop
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return RetryDecisionStop
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return RetryDecisionRetry
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return RetryDecisionRetry
+	}
+	return RetryDecisionStop
+}
+
+// Retry calls fn until it succeeds, returns a permanent error, the policy's attempts or budget are
+// exhausted, or ctx is cancelled. Delays grow exponentially with jitter and honor Retry-After.
+func Retry[T any](ctx context.Context, p RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	classify := p.Classify
+	if classify == nil {
+		classify = classifyError
+	}
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	delay := p.InitialDelay
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil {
+			return result, nil
+		}
+
+		var zero T
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+		if attempt >= maxAttempts || classify(err) == RetryDecisionStop {
+			return zero, err
+		}
+		if p.Budget != nil && !p.Budget.take() {
+			log.Printf("Retry budget exhausted, giving up: %v", err)
+			return zero, err
+		}
+
+		wait := applyJitter(delay, p.Jitter)
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			if p.MaxRetryAfter > 0 && statusErr.RetryAfter > p.MaxRetryAfter {
+				log.Printf("Server asked to retry after %s, which exceeds the limit of %s: %v", statusErr.RetryAfter, p.MaxRetryAfter, err)
+				return zero, err
+			}
+			if statusErr.RetryAfter > wait {
+				wait = statusErr.RetryAfter
+			}
+		}
+
+		log.Printf("Attempt %d/%d failed, retrying in %s: %v", attempt, maxAttempts, wait.Round(time.Millisecond), err)
+		if err := retrySleep(ctx, wait); err != nil {
+			return zero, err
 		}
-	}()
-	ret = fn.Call(in)
-	return ret, nil
+
+		delay = time.Duration(float64(delay) * p.Multiplier)
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// applyJitter randomizes d by up to the given fraction in either direction.
+func applyJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	factor := 1 + jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(d) * factor)
+}
+
+// retrySleep waits between attempts. Tests replace it to observe the delays without sleeping.
+var retrySleep = sleepContext
+
+// sleepContext waits for d or until ctx is cancelled, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
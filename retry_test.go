@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// timeoutError is a net.Error reporting a timeout, as returned by http.Client on a deadline.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{" 120 ", 2 * time.Minute},
+		{"-3", 0},
+		{"Wed, 01 May 2024 10:00:30 GMT", 30 * time.Second},
+		{"Wed, 01 May 2024 09:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want RetryDecision
+	}{
+		{"429", &HTTPStatusError{StatusCode: 429}, RetryDecisionRetry},
+		{"408", &HTTPStatusError{StatusCode: 408}, RetryDecisionRetry},
+		{"503", &HTTPStatusError{StatusCode: 503}, RetryDecisionRetry},
+		{"wrapped 500", fmt.Errorf("calling model: %w", &HTTPStatusError{StatusCode: 500}), RetryDecisionRetry},
+		{"400", &HTTPStatusError{StatusCode: 400}, RetryDecisionStop},
+		{"401", &HTTPStatusError{StatusCode: 401}, RetryDecisionStop},
+		{"network timeout", fmt.Errorf("error making request: %w", timeoutError{}), RetryDecisionRetry},
+		{"deadline", context.DeadlineExceeded, RetryDecisionRetry},
+		{"unexpected EOF", io.ErrUnexpectedEOF, RetryDecisionRetry},
+		{"cancelled", context.Canceled, RetryDecisionStop},
+		{"plain error", errors.New("bad input"), RetryDecisionStop},
+		{"marked retryable", markRetryable(errors.New("unparsable reply")), RetryDecisionRetry},
+		{"marked permanent 503", markPermanent(&HTTPStatusError{StatusCode: 503}), RetryDecisionStop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	retryAfterDate := &http.Response{StatusCode: 429, Header: http.Header{}}
+	retryAfterDate.Header.Set("Retry-After", time.Now().Add(90*time.Second).UTC().Format(http.TimeFormat))
+
+	tests := []struct {
+		name         string
+		errs         []error
+		budget       int
+		wantAttempts int
+		wantWaits    []time.Duration
+		wantErr      bool
+	}{
+		{"first attempt succeeds", nil, 0, 1, nil, false},
+		{"5xx then success", []error{&HTTPStatusError{StatusCode: 502}, &HTTPStatusError{StatusCode: 503}}, 0, 3, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, false},
+		{"timeout then success", []error{timeoutError{}}, 0, 2, []time.Duration{10 * time.Millisecond}, false},
+		{"429 retry after seconds", []error{&HTTPStatusError{StatusCode: 429, RetryAfter: 3 * time.Second}}, 0, 2, []time.Duration{3 * time.Second}, false},
+		{"retry after shorter than backoff", []error{&HTTPStatusError{StatusCode: 429, RetryAfter: time.Millisecond}}, 0, 2, []time.Duration{10 * time.Millisecond}, false},
+		{"retry after over the limit", []error{&HTTPStatusError{StatusCode: 429, RetryAfter: time.Hour}}, 0, 1, nil, true},
+		{"401 stops", []error{&HTTPStatusError{StatusCode: 401}}, 0, 1, nil, true},
+		{"400 stops", []error{&HTTPStatusError{StatusCode: 400}}, 0, 1, nil, true},
+		{"permanent stops", []error{markPermanent(errors.New("missing API key"))}, 0, 1, nil, true},
+		{"attempts exhausted", []error{timeoutError{}, timeoutError{}, timeoutError{}, timeoutError{}}, 0, 4, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}, true},
+		{"budget exhausted", []error{timeoutError{}, timeoutError{}, timeoutError{}}, 1, 2, []time.Duration{10 * time.Millisecond}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := stubRetrySleep(t)
+			policy := RetryPolicy{
+				MaxAttempts:   4,
+				InitialDelay:  10 * time.Millisecond,
+				MaxDelay:      25 * time.Millisecond,
+				Multiplier:    2,
+				MaxRetryAfter: time.Minute,
+			}
+			if tt.budget > 0 {
+				policy.Budget = NewRetryBudget(tt.budget)
+			}
+			attempts := 0
+			got, err := Retry(context.Background(), policy, func(ctx context.Context) (string, error) {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return "", tt.errs[attempts-1]
+				}
+				return "ok", nil
+			})
+			if (err != nil) != tt.wantErr || (err == nil && got != "ok") {
+				t.Errorf("Retry = %q, %v; want error %v", got, err, tt.wantErr)
+			}
+			if tt.wantErr && err != tt.errs[attempts-1] {
+				t.Errorf("Retry returned %v, want the last error %v", err, tt.errs[attempts-1])
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if !reflect.DeepEqual(*waits, tt.wantWaits) {
+				t.Errorf("waits = %v, want %v", *waits, tt.wantWaits)
+			}
+		})
+	}
+
+	t.Run("429 retry after date", func(t *testing.T) {
+		waits := stubRetrySleep(t)
+		statusErr := newHTTPStatusError(retryAfterDate, []byte("slow down"))
+		attempts := 0
+		_, err := Retry(context.Background(), RetryPolicy{MaxAttempts: 2, MaxRetryAfter: time.Hour}, func(ctx context.Context) (string, error) {
+			attempts++
+			if attempts == 1 {
+				return "", statusErr
+			}
+			return "ok", nil
+		})
+		if err != nil || len(*waits) != 1 || (*waits)[0] < 80*time.Second || (*waits)[0] > 90*time.Second {
+			t.Errorf("Retry = %v with waits %v, want one wait of about 90s", err, *waits)
+		}
+	})
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	attempts := 0
+	start := time.Now()
+	_, err := Retry(ctx, RetryPolicy{MaxAttempts: 3, InitialDelay: time.Hour}, func(ctx context.Context) (string, error) {
+		attempts++
+		return "", &HTTPStatusError{StatusCode: 503}
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("Retry = %v after %d attempts, want context.Canceled after 1", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("Retry waited %s after cancellation", elapsed)
+	}
+}
+
+func TestApplyJitter(t *testing.T) {
+	tests := []struct {
+		jitter float64
+		min    time.Duration
+		max    time.Duration
+	}{
+		{0, time.Second, time.Second},
+		{-1, time.Second, time.Second},
+		{0.2, 800 * time.Millisecond, 1200 * time.Millisecond},
+		{5, 0, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.jitter), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := applyJitter(time.Second, tt.jitter); got < tt.min || got > tt.max {
+					t.Fatalf("applyJitter(1s, %v) = %s, want within [%s, %s]", tt.jitter, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+	if got := applyJitter(0, 0.5); got != 0 {
+		t.Errorf("applyJitter(0, 0.5) = %s, want 0", got)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(2)
+	var got []bool
+	for i := 0; i < 3; i++ {
+		got = append(got, budget.take())
+	}
+	if want := []bool{true, true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("take() = %v, want %v", got, want)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), 0); err != nil {
+		t.Errorf("sleepContext(0) = %v", err)
+	}
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("sleepContext(1ms) = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext(cancelled, 0) = %v, want context.Canceled", err)
+	}
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext(cancelled, 1h) = %v, want context.Canceled", err)
+	}
+}
+
+// stubRetrySleep makes Retry record its delays instead of sleeping, and silences its log.
+func stubRetrySleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	previousSleep, previousLog := retrySleep, log.Writer()
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		retrySleep = previousSleep
+		log.SetOutput(previousLog)
+	})
+	return &waits
+}
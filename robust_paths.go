@@ -0,0 +1,168 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// Discriminator scores: higher scores make better predicates. Keys scoring zero are never used.
+const (
+	discriminatorScoreNone   = 0
+	discriminatorScoreWeak   = 1
+	discriminatorScoreStrong = 3
+)
+
+// robustPathForReference derives a path for a response body value that does not depend on array order.
+// It walks the value's ancestors and, for every array on the way, looks for sibling fields that identify
+// the selected element (e.g. type == "ADT"), producing a predicate-based JSONPath expression such as
+// $.travelers[?(@.type=='ADT')].id that selects the first match. Exporters render it for their runtime.
+//
+// It returns the new path, or "" when the existing path has no array indices to replace.
+// needsAI is true when some array element could not be identified, so only the AI step can help.
+func robustPathForReference(ref *ValueReference) (path string, needsAI bool) {
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil || expr.Query || !expr.Definite() {
+		// Already refined, or not a path this algorithm understands.
+		return "", false
+	}
+	// flatten records one ancestor (the containing object or array) per path step.
+	if len(ref.Ancestors) != len(expr.Steps) {
+		return "", true
+	}
+
+	hasIndex := false
+	var steps []pathStep
+	for i, st := range expr.Steps {
+		if st.kind != stepIndex {
+			steps = append(steps, st)
+			continue
+		}
+		hasIndex = true
+
+		arr, ok := ref.Ancestors[i].([]interface{})
+		if !ok || st.index < 0 || st.index >= len(arr) {
+			return "", true
+		}
+
+		// The key read from the element next, if it is the final step, holds the value being extracted
+		// and therefore cannot be used to find the element.
+		excludeKey := ""
+		if i+2 == len(expr.Steps) && expr.Steps[i+1].kind == stepKey {
+			excludeKey = expr.Steps[i+1].key
+		}
+
+		conditions := findDiscriminator(arr, st.index, excludeKey)
+		if conditions == nil {
+			if len(arr) == 1 {
+				// A single element needs no predicate; keep the index.
+				steps = append(steps, st)
+				continue
+			}
+			return "", true
+		}
+		steps = append(steps, pathStep{kind: stepFilter, filter: conditions})
+	}
+	if !hasIndex {
+		return "", false
+	}
+
+	robust := &ReferenceExpr{Steps: steps, QueryIndex: -1}
+	return robust.JSONPath(), false
+}
+
+// findDiscriminator finds sibling fields that are unique to arr[index] among the array's elements.
+// Single fields are preferred; otherwise a pair of fields is tried. Returns nil if nothing identifies the element.
+func findDiscriminator(arr []interface{}, index int, excludeKey string) []filterCondition {
+	element, ok := arr[index].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	type candidate struct {
+		key   string
+		value interface{}
+		score int
+	}
+	var candidates []candidate
+	for _, key := range sortedKeys(element) {
+		if key == excludeKey || !isJSIdentifier(key) {
+			continue
+		}
+		score := discriminatorScore(element[key])
+		if score == discriminatorScoreNone {
+			continue
+		}
+		candidates = append(candidates, candidate{key: key, value: element[key], score: score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	for _, c := range candidates {
+		conditions := []filterCondition{{path: []string{c.key}, op: "==", value: c.value}}
+		if uniqueMatch(arr, index, conditions) {
+			return conditions
+		}
+	}
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			conditions := []filterCondition{
+				{path: []string{candidates[i].key}, op: "==", value: candidates[i].value},
+				{path: []string{candidates[j].key}, op: "==", value: candidates[j].value},
+			}
+			if uniqueMatch(arr, index, conditions) {
+				return conditions
+			}
+		}
+	}
+	return nil
+}
+
+// discriminatorScore rates how suitable a field value is for a predicate. Descriptive strings such as
+// "ADT" are stable across runs; generated identifiers change and are rejected.
+func discriminatorScore(v interface{}) int {
+	switch val := v.(type) {
+	case string:
+		if val == "" || len(val) > 40 || isIdentifierLikeSegment(val) {
+			return discriminatorScoreNone
+		}
+		return discriminatorScoreStrong
+	case bool:
+		return discriminatorScoreWeak
+	case float64:
+		if val >= 100 {
+			// Large numbers are usually identifiers or amounts that vary between runs.
+			return discriminatorScoreNone
+		}
+		return discriminatorScoreWeak
+	}
+	return discriminatorScoreNone
+}
+
+// uniqueMatch reports whether arr[index] is the only element satisfying the conditions.
+func uniqueMatch(arr []interface{}, index int, conditions []filterCondition) bool {
+	for i, el := range arr {
+		if matchesFilter(el, conditions) != (i == index) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyRobustPath replaces the reference path of a response value with a deterministic, order-independent one.
+// It reports whether the AI refinement is still needed for this value.
+func applyRobustPath(ref *ValueReference, value string) (needsAI bool) {
+	path, needsAI := robustPathForReference(ref)
+	if needsAI || path == "" {
+		return needsAI
+	}
+	validated, err := validateRefinedPath(ref.Source.Entry.Response.Content.Text, path, value)
+	if err != nil {
+		// The algorithm should never produce a wrong path, but don't trust it blindly.
+		log.Printf("Discarding generated path %q for %q: %v", path, ref.ReferencePath, err)
+		return true
+	}
+	log.Printf("Updated path from %q to %q using sibling predicates", ref.ReferencePath, validated)
+	ref.ReferencePath = validated
+	return false
+}
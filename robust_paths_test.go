@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRobustPathForReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		path        string
+		want        string
+		wantNeedsAI bool
+	}{
+		{
+			name: "single discriminator",
+			body: `{"travelers": [{"type": "CHD", "id": "t-1"}, {"type": "ADT", "id": "t-2"}]}`,
+			path: "travelers[1].id",
+			want: "$.travelers[?(@.type=='ADT')].id",
+		},
+		{
+			name: "pair of discriminators",
+			body: `{"items": [{"kind": "a", "size": 1, "id": "x"}, {"kind": "a", "size": 2, "id": "y"}, {"kind": "b", "size": 2, "id": "z"}]}`,
+			path: "items[1].id",
+			want: "$.items[?(@.kind=='a' && @.size==2)].id",
+		},
+		{
+			name: "nested arrays",
+			body: `{"orders": [{"status": "open", "lines": [{"sku": "A", "qty": 1}, {"sku": "B", "qty": 2}]}, {"status": "closed", "lines": []}]}`,
+			path: "orders[0].lines[1].qty",
+			want: "$.orders[?(@.status=='open')].lines[?(@.sku=='B')].qty",
+		},
+		{
+			name: "single element keeps its index",
+			body: `{"items": [{"id": "only"}]}`,
+			path: "items[0].id",
+			want: "$.items[0].id",
+		},
+		{
+			name: "no arrays",
+			body: `{"data": {"id": "x"}}`,
+			path: "data.id",
+			want: "",
+		},
+		{
+			name:        "identifier-like siblings only",
+			body:        `{"items": [{"ref": "550e8400-e29b-41d4-a716-446655440000", "id": "a"}, {"ref": "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "id": "b"}]}`,
+			path:        "items[1].id",
+			wantNeedsAI: true,
+		},
+		{
+			name:        "extracted key is not a discriminator",
+			body:        `{"items": [{"id": "a"}, {"id": "b"}]}`,
+			path:        "items[1].id",
+			wantNeedsAI: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := FlattenJSON(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ref *ValueReference
+			for _, r := range refs {
+				if r.ReferencePath == tt.path {
+					ref = r
+				}
+			}
+			if ref == nil {
+				t.Fatalf("no value at %s", tt.path)
+			}
+			got, needsAI := robustPathForReference(ref)
+			if got != tt.want || needsAI != tt.wantNeedsAI {
+				t.Fatalf("robustPathForReference(%s) = %q, %v; want %q, %v", tt.path, got, needsAI, tt.want, tt.wantNeedsAI)
+			}
+			if got != "" {
+				if _, err := validateRefinedPath(tt.body, got, fmt.Sprintf("%v", ref.Value)); err != nil {
+					t.Errorf("generated path does not yield the value: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptDialectExpression(t *testing.T) {
+	ctx := &ChainedValueContext{VariableName: "travelerId", OriginalPath: "travelers[1].id"}
+	tests := []struct {
+		name    string
+		dialect scriptDialect
+		path    string
+		want    string
+	}{
+		{"postman predicate", postmanDialect, "$.travelers[?(@.type=='ADT')].id", `responseJson.travelers.find((e) => e.type === "ADT").id`},
+		{"postman query call", postmanDialect, `jsonpath.query(responseJson, "$.travelers[?(@.type=='ADT')].id")[0]`, `responseJson.travelers.find((e) => e.type === "ADT").id`},
+		{"postman wildcard falls back to the recorded path", postmanDialect, "$..id", "responseJson.travelers[1].id"},
+		{"postman plain path", postmanDialect, "data.items[0].id", "responseJson.data.items[0].id"},
+		{"bruno predicate", brunoDialect, "$.travelers[?(@.type=='ADT')].id", `jsonpath.query(responseJson, "$.travelers[?(@.type=='ADT')].id")[0]`},
+		{"http file predicate", httpFileDialect, "$.travelers[?(@.type=='ADT')].id", `responseJson.travelers.find((e) => e.type === "ADT").id`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := &ValueReference{ReferencePath: tt.path, SourceLocation: SourceLocationBodyJson, Context: ctx}
+			if got := tt.dialect.expression(ref); got != tt.want {
+				t.Errorf("expression(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
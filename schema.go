@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// uuidPattern matches UUIDs in their canonical textual form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// JSONSchema is the subset of JSON Schema 2020-12, as used by OpenAPI 3.1, that is inferred from sample
+// values. Types holds one or more type names; a single type is written as a string.
+type JSONSchema struct {
+	Types      []string               `json:"-"`
+	Format     string                 `json:"format,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *JSONSchema            `json:"items,omitempty"`
+	AnyOf      []*JSONSchema          `json:"anyOf,omitempty"`
+}
+
+// MarshalJSON writes Types as the type keyword: a string for one type, an array for several.
+func (s *JSONSchema) MarshalJSON() ([]byte, error) {
+	type plain JSONSchema
+	out := struct {
+		Type interface{} `json:"type,omitempty"`
+		*plain
+	}{plain: (*plain)(s)}
+	switch len(s.Types) {
+	case 0:
+	case 1:
+		out.Type = s.Types[0]
+	default:
+		out.Type = s.Types
+	}
+	return json.Marshal(out)
+}
+
+// inferSchema returns the schema of a decoded JSON value. Object properties present in the sample are required.
+func inferSchema(v interface{}) *JSONSchema {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		schema := &JSONSchema{Types: []string{"object"}, Properties: make(map[string]*JSONSchema)}
+		for key, child := range val {
+			schema.Properties[key] = inferSchema(child)
+			schema.Required = append(schema.Required, key)
+		}
+		sort.Strings(schema.Required)
+		return schema
+	case []interface{}:
+		var items *JSONSchema
+		for _, child := range val {
+			items = mergeSchemas(items, inferSchema(child))
+		}
+		if items == nil {
+			items = &JSONSchema{}
+		}
+		return &JSONSchema{Types: []string{"array"}, Items: items}
+	case string:
+		return &JSONSchema{Types: []string{"string"}, Format: stringFormat(val)}
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return &JSONSchema{Types: []string{"integer"}}
+		}
+		return &JSONSchema{Types: []string{"number"}}
+	case bool:
+		return &JSONSchema{Types: []string{"boolean"}}
+	}
+	return &JSONSchema{Types: []string{"null"}}
+}
+
+// stringFormat recognizes the formats worth declaring for a sample string: date-time, date and uuid.
+func stringFormat(s string) string {
+	if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return "date-time"
+	}
+	if _, err := time.Parse("2006-01-02", s); err == nil {
+		return "date"
+	}
+	if uuidPattern.MatchString(s) {
+		return "uuid"
+	}
+	return ""
+}
+
+// mergeSchemas combines the schemas of two samples of the same value. Objects keep the union of their
+// properties and require only the properties present in both; integers widen to numbers; null and
+// differing scalar types become a list of types; other differences become anyOf.
+func mergeSchemas(a, b *JSONSchema) *JSONSchema {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if sameSchema(a, b) {
+		return a
+	}
+	if len(a.AnyOf) > 0 || len(b.AnyOf) > 0 {
+		return &JSONSchema{AnyOf: appendDistinctSchemas(appendDistinctSchemas(nil, a), b)}
+	}
+
+	aTypes, bTypes := withoutNull(a.Types), withoutNull(b.Types)
+	nullable := len(aTypes) < len(a.Types) || len(bTypes) < len(b.Types)
+	var merged *JSONSchema
+	switch {
+	case len(aTypes) == 0:
+		merged = copySchema(b)
+	case len(bTypes) == 0:
+		merged = copySchema(a)
+	case len(aTypes) == 1 && len(bTypes) == 1 && aTypes[0] == bTypes[0]:
+		merged = mergeSameType(a, b, aTypes[0])
+	case isNumeric(aTypes) && isNumeric(bTypes):
+		merged = &JSONSchema{Types: []string{"number"}}
+	case isScalar(aTypes) && isScalar(bTypes):
+		merged = &JSONSchema{Types: unionStrings(aTypes, bTypes)}
+		if a.Format == b.Format {
+			merged.Format = a.Format
+		}
+	default:
+		return &JSONSchema{AnyOf: appendDistinctSchemas(appendDistinctSchemas(nil, a), b)}
+	}
+	if nullable {
+		merged.Types = unionStrings(withoutNull(merged.Types), []string{"null"})
+	}
+	return merged
+}
+
+// mergeSameType merges two schemas of the single type typ.
+func mergeSameType(a, b *JSONSchema, typ string) *JSONSchema {
+	merged := &JSONSchema{Types: []string{typ}}
+	switch typ {
+	case "object":
+		merged.Properties = make(map[string]*JSONSchema)
+		for key, schema := range a.Properties {
+			merged.Properties[key] = mergeSchemas(schema, b.Properties[key])
+		}
+		for key, schema := range b.Properties {
+			if _, ok := merged.Properties[key]; !ok {
+				merged.Properties[key] = schema
+			}
+		}
+		inB := make(map[string]bool)
+		for _, key := range b.Required {
+			inB[key] = true
+		}
+		for _, key := range a.Required {
+			if inB[key] {
+				merged.Required = append(merged.Required, key)
+			}
+		}
+	case "array":
+		merged.Items = mergeSchemas(a.Items, b.Items)
+	default:
+		if a.Format == b.Format {
+			merged.Format = a.Format
+		}
+	}
+	return merged
+}
+
+func sameSchema(a, b *JSONSchema) bool {
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	return string(aj) == string(bj)
+}
+
+func copySchema(s *JSONSchema) *JSONSchema {
+	c := *s
+	c.Types = append([]string(nil), s.Types...)
+	return &c
+}
+
+// appendDistinctSchemas appends s, or the alternatives of an anyOf schema, skipping duplicates.
+func appendDistinctSchemas(list []*JSONSchema, s *JSONSchema) []*JSONSchema {
+	alternatives := []*JSONSchema{s}
+	if len(s.AnyOf) > 0 {
+		alternatives = s.AnyOf
+	}
+	for _, alt := range alternatives {
+		duplicate := false
+		for _, existing := range list {
+			if sameSchema(existing, alt) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			list = append(list, alt)
+		}
+	}
+	return list
+}
+
+func withoutNull(types []string) []string {
+	var result []string
+	for _, t := range types {
+		if t != "null" {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func isNumeric(types []string) bool {
+	for _, t := range types {
+		if t != "integer" && t != "number" {
+			return false
+		}
+	}
+	return true
+}
+
+func isScalar(types []string) bool {
+	for _, t := range types {
+		if t == "object" || t == "array" {
+			return false
+		}
+	}
+	return true
+}
+
+// unionStrings returns the elements of a followed by those of b that are not in a.
+func unionStrings(a, b []string) []string {
+	result := append([]string(nil), a...)
+	for _, s := range b {
+		found := false
+		for _, r := range result {
+			if r == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, s)
+		}
+	}
+	return result
+}
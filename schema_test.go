@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   string
+	}{
+		{"string", `"abc"`, `{"type":"string"}`},
+		{"date-time", `"2024-05-01T10:00:00Z"`, `{"type":"string","format":"date-time"}`},
+		{"date", `"2024-05-01"`, `{"type":"string","format":"date"}`},
+		{"uuid", `"550e8400-e29b-41d4-a716-446655440000"`, `{"type":"string","format":"uuid"}`},
+		{"integer", `42`, `{"type":"integer"}`},
+		{"number", `4.2`, `{"type":"number"}`},
+		{"boolean", `true`, `{"type":"boolean"}`},
+		{"null", `null`, `{"type":"null"}`},
+		{"object", `{"b": 1, "a": "x"}`, `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"integer"}},"required":["a","b"]}`},
+		{"empty array", `[]`, `{"type":"array","items":{}}`},
+		{"integers widen to number", `[1, 2.5]`, `{"type":"array","items":{"type":"number"}}`},
+		{"nullable", `["a", null]`, `{"type":"array","items":{"type":["string","null"]}}`},
+		{"differing scalars", `[1, "a"]`, `{"type":"array","items":{"type":["integer","string"]}}`},
+		{"formats must agree", `["2024-05-01", "x"]`, `{"type":"array","items":{"type":"string"}}`},
+		{"object and scalar", `[{"a": 1}, "x"]`, `{"type":"array","items":{"anyOf":[{"type":"object","properties":{"a":{"type":"integer"}},"required":["a"]},{"type":"string"}]}}`},
+		{
+			"objects require common properties",
+			`[{"id": 1, "name": "a"}, {"id": 2, "tag": null}]`,
+			`{"type":"array","items":{"type":"object","properties":{"id":{"type":"integer"},"name":{"type":"string"},"tag":{"type":"null"}},"required":["id"]}}`,
+		},
+		{"nested arrays", `[[1], [null]]`, `{"type":"array","items":{"type":"array","items":{"type":["integer","null"]}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(tt.sample), &v); err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(inferSchema(v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("inferSchema(%s) = %s, want %s", tt.sample, got, tt.want)
+			}
+		})
+	}
+}
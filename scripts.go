@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// scriptDialect describes how the scripts of a JavaScript-based API client read the response and set
+// variables. The extraction logic is shared; only these client APIs differ between Postman, Bruno and
+// the JetBrains HTTP Client.
+type scriptDialect struct {
+	// parseBody is the statement that declares responseJson, the parsed response body.
+	parseBody string
+
+	// header returns the expression reading a response header.
+	header func(name string) string
+
+	// cookie returns the expression reading a cookie set by the response.
+	cookie func(name string) string
+
+	// cookieHelper is emitted once at the top of scripts that read cookies, if the cookie expression needs it.
+	cookieHelper []string
+
+	// setVar returns the statement storing the value of expr in the variable name.
+	setVar func(name string, expr string) string
+
+	// native renders body paths as plain JavaScript, for clients whose scripts cannot load a JSONPath library.
+	native bool
+}
+
+// postmanDialect is the pm.* API of Postman test scripts.
+var postmanDialect = scriptDialect{
+	parseBody: "var responseJson = pm.response.json();",
+	header: func(name string) string {
+		return fmt.Sprintf("pm.response.headers.get(%q)", name)
+	},
+	cookie: func(name string) string {
+		return fmt.Sprintf("pm.cookies.get(%q)", name)
+	},
+	setVar: func(name string, expr string) string {
+		return fmt.Sprintf("pm.collectionVariables.set(%q, %s);", name, expr)
+	},
+	native: true,
+}
+
+// responseScriptLines returns the script lines that extract the response-chained values of a call and
+// store each in a variable, together with its encoded variants.
+func (d scriptDialect) responseScriptLines(chainedValues []*ValueReference) []string {
+	var scriptLines []string
+	scriptLines = append(scriptLines, d.parseBody)
+
+	if needsJWTDecoder(chainedValues) {
+		scriptLines = append(scriptLines, jwtDecodeFunctionJS...)
+	}
+	if d.cookieHelper != nil && needsCookieHelper(chainedValues) {
+		scriptLines = append(scriptLines, d.cookieHelper...)
+	}
+
+	usedVariables := make(map[string]bool)
+
+	for _, chainedValue := range chainedValues {
+		if chainedValue.SourceType != SourceTypeResponse {
+			continue
+		}
+		if chainedValue.Context == nil {
+			continue
+		}
+		variableName := chainedValue.Context.VariableName
+		if usedVariables[variableName] {
+			continue
+		}
+		usedVariables[variableName] = true
+
+		scriptLines = append(scriptLines, d.buildScriptForVariable(chainedValue)...)
+	}
+	return scriptLines
+}
+
+// buildScriptForVariable constructs JavaScript code snippets for extracting a single variable from the response.
+// It includes error handling and stores the extracted value in a variable.
+func (d scriptDialect) buildScriptForVariable(chainedValue *ValueReference) []string {
+	var scriptLines []string
+	collectionVarName := chainedValue.Context.VariableName
+
+	// Build JavaScript code to extract the value with error handling
+	jsPath := d.expression(chainedValue)
+	scriptLines = append(scriptLines, "try {")
+
+	valueExtraction := fmt.Sprintf("  var %s = %s;", collectionVarName, jsPath)
+	scriptLines = append(scriptLines, valueExtraction)
+
+	scriptLines = append(scriptLines, "  "+d.setVar(collectionVarName, collectionVarName))
+	// Later requests may use the value encoded; set a variable for each encoding they need.
+	for _, t := range usedTransforms(chainedValue.Context) {
+		scriptLines = append(scriptLines, "  "+d.setVar(collectionVarName+t.VariableSuffix(), t.JSExpression(collectionVarName)))
+	}
+	printToConsole := fmt.Sprintf("  console.log('Variable: %s, Value:', %s);", collectionVarName, collectionVarName)
+	scriptLines = append(scriptLines, printToConsole)
+	scriptLines = append(scriptLines, "} catch (e) {")
+	logError := fmt.Sprintf("  console.error('Error extracting variable %s:', e);", collectionVarName)
+	scriptLines = append(scriptLines, logError)
+	scriptLines = append(scriptLines, "}")
+
+	return scriptLines
+}
+
+// expression returns the JavaScript expression that reads a response value.
+// Headers and cookies are read with the client's API; body paths are rendered as accessors on responseJson.
+func (d scriptDialect) expression(ref *ValueReference) string {
+	if ref.JWTParent != nil {
+		return d.jwtClaimExpression(ref)
+	}
+	if ref.SourceLocation == SourceLocationCookie {
+		return d.cookie(ref.CookieName)
+	}
+	if ref.SourceLocation == SourceLocationHeader {
+		return d.header(ref.HeaderName)
+	}
+	expr, err := ParseReferencePath(ref.ReferencePath)
+	if err != nil {
+		log.Printf("Unable to parse path %q, using it verbatim: %v", ref.ReferencePath, err)
+		return ref.ReferencePath
+	}
+	if d.native {
+		js, err := expr.NativeJSExpression("responseJson")
+		if err == nil {
+			return js
+		}
+		if ref.Context != nil && ref.Context.OriginalPath != "" {
+			if original, origErr := ParseReferencePath(ref.Context.OriginalPath); origErr == nil && original.Definite() {
+				log.Printf("Unable to render path %q without JSONPath, using the recorded path %q: %v", ref.ReferencePath, ref.Context.OriginalPath, err)
+				return original.JSExpression("responseJson")
+			}
+		}
+		log.Printf("Unable to render path %q without JSONPath, using it verbatim: %v", ref.ReferencePath, err)
+		return ref.ReferencePath
+	}
+	if expr.Query {
+		// Keep AI-provided query expressions exactly as validated.
+		return ref.ReferencePath
+	}
+	return expr.JSExpression("responseJson")
+}
+
+// jwtClaimExpression returns the JavaScript expression that decodes the token holding a JWT claim and reads the claim.
+func (d scriptDialect) jwtClaimExpression(ref *ValueReference) string {
+	decoded := fmt.Sprintf("decodeJwtPart(%s, %d)", d.expression(ref.JWTParent), jwtPartIndex(ref.JWTClaimPath))
+	expr, err := jwtClaimExpr(ref.JWTClaimPath)
+	if err != nil {
+		log.Printf("Unable to parse JWT claim path %q: %v", ref.JWTClaimPath, err)
+		return decoded
+	}
+	return expr.JSExpression(decoded)
+}
+
+// needsJWTDecoder reports whether any of the response values extracted by a script is a JWT claim.
+func needsJWTDecoder(chainedValues []*ValueReference) bool {
+	for _, ref := range chainedValues {
+		if ref.SourceType == SourceTypeResponse && ref.Context != nil && ref.JWTParent != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// needsCookieHelper reports whether any of the response values extracted by a script is read from a cookie.
+func needsCookieHelper(chainedValues []*ValueReference) bool {
+	for _, ref := range chainedValues {
+		if ref.SourceType == SourceTypeResponse && ref.Context != nil && ref.SourceLocation == SourceLocationCookie {
+			return true
+		}
+	}
+	return false
+}
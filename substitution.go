@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// substituteChainedValue returns value with its chained portions replaced by placeholders, considering every
+// reference whose Value equals value. A whole-value match replaces the entire string; otherwise each embedded
+// match replaces only its own MatchOffset/MatchLength span. The boolean reports whether anything was replaced.
+func substituteChainedValue(value string, refs []*ValueReference, placeholder func(*ValueReference) string) (string, bool) {
+	segments := chainedValueSegments(value, refs)
+	if segments == nil {
+		return value, false
+	}
+	return renderSegments(segments, placeholder), true
+}
+
+// chainedValueSegments splits value into literal text and the chained references matching it, as described for
+// substituteChainedValue. It returns nil if no reference matches.
+func chainedValueSegments(value string, refs []*ValueReference) []templateSegment {
+	var spans []*ValueReference
+	for _, ref := range refs {
+		if ref.Context == nil || fmt.Sprintf("%v", ref.Value) != value {
+			continue
+		}
+		if ref.MatchLength == 0 {
+			return []templateSegment{{ref: ref}}
+		}
+		if ref.MatchOffset >= 0 && ref.MatchOffset+ref.MatchLength <= len(value) {
+			spans = append(spans, ref)
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// Take the spans in order of their offsets, skipping any that overlap an earlier one.
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].MatchOffset < spans[j].MatchOffset
+	})
+	var segments []templateSegment
+	pos := 0
+	for _, ref := range spans {
+		if ref.MatchOffset < pos {
+			continue
+		}
+		if ref.MatchOffset > pos {
+			segments = append(segments, templateSegment{literal: value[pos:ref.MatchOffset]})
+		}
+		segments = append(segments, templateSegment{ref: ref})
+		pos = ref.MatchOffset + ref.MatchLength
+	}
+	if pos < len(value) {
+		segments = append(segments, templateSegment{literal: value[pos:]})
+	}
+	return segments
+}
+
+// templateSegment is a piece of a request string: either literal text or a chained value.
+// Code generators escape the literal parts for the target language and render the chained values as expressions.
+type templateSegment struct {
+	literal string
+	ref     *ValueReference
+}
+
+// splitChainedValues splits input into literal text and chained values. Values are processed in the order of
+// refs, each distinct value once, and are only matched within the literal text left by the values before them.
+func splitChainedValues(input string, refs []*ValueReference) []templateSegment {
+	segments := []templateSegment{{literal: input}}
+	done := make(map[string]bool)
+	for _, v := range refs {
+		valueString := fmt.Sprintf("%v", v.Value)
+		if v.Context == nil {
+			// log and continue
+			log.Printf("Value %v has no context", valueString)
+			continue
+		}
+		if done[valueString] || valueString == "" {
+			continue
+		}
+		done[valueString] = true
+		replacement := chainedValueSegments(valueString, refs)
+		if replacement == nil {
+			continue
+		}
+
+		var next []templateSegment
+		for _, segment := range segments {
+			if segment.ref != nil {
+				next = append(next, segment)
+				continue
+			}
+			parts := strings.Split(segment.literal, valueString)
+			for i, part := range parts {
+				if i > 0 {
+					next = append(next, replacement...)
+				}
+				next = append(next, templateSegment{literal: part})
+			}
+		}
+		segments = next
+	}
+	return mergeLiterals(segments)
+}
+
+// mergeLiterals joins adjacent literal segments and drops empty ones.
+func mergeLiterals(segments []templateSegment) []templateSegment {
+	var merged []templateSegment
+	for _, segment := range segments {
+		if segment.ref == nil {
+			if segment.literal == "" {
+				continue
+			}
+			if n := len(merged); n > 0 && merged[n-1].ref == nil {
+				merged[n-1].literal += segment.literal
+				continue
+			}
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// renderSegments joins the segments, rendering each chained value with placeholder.
+func renderSegments(segments []templateSegment, placeholder func(*ValueReference) string) string {
+	var sb strings.Builder
+	for _, segment := range segments {
+		if segment.ref != nil {
+			sb.WriteString(placeholder(segment.ref))
+		} else {
+			sb.WriteString(segment.literal)
+		}
+	}
+	return sb.String()
+}
+
+// replaceChainedValues replaces every occurrence of the chained request values in input with placeholders,
+// matching as splitChainedValues does.
+func replaceChainedValues(input string, refs []*ValueReference, placeholder func(*ValueReference) string) string {
+	return renderSegments(splitChainedValues(input, refs), placeholder)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitChainedValues(t *testing.T) {
+	token := &ChainedValueContext{VariableName: "token"}
+	id := &ChainedValueContext{VariableName: "id"}
+	ref := func(value string, ctx *ChainedValueContext) *ValueReference {
+		return &ValueReference{Value: value, Context: ctx}
+	}
+	embedded := func(value string, offset int, length int, ctx *ChainedValueContext) *ValueReference {
+		return &ValueReference{Value: value, Context: ctx, MatchOffset: offset, MatchLength: length}
+	}
+	render := func(r *ValueReference) string { return "{{" + r.Context.VariableName + "}}" }
+
+	tests := []struct {
+		name  string
+		input string
+		refs  []*ValueReference
+		want  string
+	}{
+		{"no refs", "plain text", nil, "plain text"},
+		{"whole value", "Bearer abc123", []*ValueReference{ref("abc123", token)}, "Bearer {{token}}"},
+		{"every occurrence", "abc123/abc123", []*ValueReference{ref("abc123", token)}, "{{token}}/{{token}}"},
+		{"embedded", "ORD-42-A", []*ValueReference{embedded("ORD-42-A", 4, 2, id)}, "ORD-{{id}}-A"},
+		{"two values", "a=abc123&b=42", []*ValueReference{ref("abc123", token), ref("42", id)}, "a={{token}}&b={{id}}"},
+		{"later value inside earlier one", "x=abc123", []*ValueReference{ref("abc123", token), ref("123", id)}, "x={{token}}"},
+		{"later value inside placeholder-like text", "{{id}}-abc123", []*ValueReference{ref("abc123", token), ref("id", id)}, "{{{{id}}}}-{{token}}"},
+		{"no context", "abc123", []*ValueReference{{Value: "abc123"}}, "abc123"},
+		{"NUL bytes", "a\x00abc123\x00b\x00", []*ValueReference{ref("abc123", token)}, "a\x00{{token}}\x00b\x00"},
+		{"private use runes", "\ue000abc123\ue001\uf8ff", []*ValueReference{ref("abc123", token)}, "\ue000{{token}}\ue001\uf8ff"},
+		{"NUL and private use together", "\x00\ue000\x00 42 \x00\ue005", []*ValueReference{ref("42", id)}, "\x00\ue000\x00 {{id}} \x00\ue005"},
+		{"value with NUL", "k=a\x00b;", []*ValueReference{ref("a\x00b", token)}, "k={{token}};"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := splitChainedValues(tt.input, tt.refs)
+			if got := renderSegments(segments, render); got != tt.want {
+				t.Errorf("splitChainedValues(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if got := replaceChainedValues(tt.input, tt.refs, render); got != tt.want {
+				t.Errorf("replaceChainedValues(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			for i := 1; i < len(segments); i++ {
+				if segments[i].ref == nil && segments[i-1].ref == nil {
+					t.Errorf("adjacent literal segments %q and %q", segments[i-1].literal, segments[i].literal)
+				}
+			}
+		})
+	}
+}
+
+func TestSubstituteChainedValue(t *testing.T) {
+	ctx := &ChainedValueContext{VariableName: "v"}
+	render := func(r *ValueReference) string { return "<" + r.Context.VariableName + ">" }
+	tests := []struct {
+		name   string
+		value  string
+		refs   []*ValueReference
+		want   string
+		wantOK bool
+	}{
+		{"whole", "abc", []*ValueReference{{Value: "abc", Context: ctx}}, "<v>", true},
+		{"other value", "abc", []*ValueReference{{Value: "xyz", Context: ctx}}, "abc", false},
+		{"two spans", "a-1-b-2", []*ValueReference{
+			{Value: "a-1-b-2", Context: ctx, MatchOffset: 6, MatchLength: 1},
+			{Value: "a-1-b-2", Context: ctx, MatchOffset: 2, MatchLength: 1},
+		}, "a-<v>-b-<v>", true},
+		{"overlapping spans", "abcdef", []*ValueReference{
+			{Value: "abcdef", Context: ctx, MatchOffset: 1, MatchLength: 3},
+			{Value: "abcdef", Context: ctx, MatchOffset: 2, MatchLength: 3},
+		}, "a<v>ef", true},
+		{"span out of range", "abc", []*ValueReference{{Value: "abc", Context: ctx, MatchOffset: 2, MatchLength: 5}}, "abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := substituteChainedValue(tt.value, tt.refs, render)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("substituteChainedValue(%q) = %q, %v; want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSplitChainedValuesSegments(t *testing.T) {
+	ctx := &ChainedValueContext{VariableName: "token"}
+	r := &ValueReference{Value: "abc", Context: ctx}
+	got := splitChainedValues("\x00abc\x00", []*ValueReference{r})
+	want := []templateSegment{{literal: "\x00"}, {ref: r}, {literal: "\x00"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitChainedValues = %+v, want %+v", got, want)
+	}
+}
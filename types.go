@@ -29,6 +29,10 @@ const (
 
 	// SourceLocationUrl indicates that the value was extracted from the URL (host, path, or query).
 	SourceLocationUrl
+
+	// SourceLocationCookie indicates that the value is a cookie, from a Cookie or Set-Cookie header
+	// or the HAR cookies array.
+	SourceLocationCookie
 )
 
 // CallDetails aggregates information for a single HTTP call.
@@ -55,6 +59,10 @@ type CallDetails struct {
 	// ResponseChainedValues contains value references in the response that have been
 	// identified as being part of a variable chaining scenario.
 	ResponseChainedValues []*ValueReference `json:"response_chained_values"`
+
+	// JarCookies names the cookies of the recorded request that are left to the client's cookie jar
+	// (-cookies=jar). Exporters omit them from the Cookie header; see requestHeaders.
+	JarCookies []string `json:"jar_cookies,omitempty"`
 }
 
 // ValueReference encapsulates an extracted value along with metadata describing
@@ -76,6 +84,9 @@ type ValueReference struct {
 	// TODO: Remove this field
 	HeaderName string `json:"header_name"`
 
+	// CookieName is the name of the cookie holding the value when SourceLocation is SourceLocationCookie.
+	CookieName string `json:"cookie_name,omitempty"`
+
 	// Source points to the CallDetails from which this value was extracted.
 	Source *CallDetails `json:"source"`
 
@@ -85,6 +96,23 @@ type ValueReference struct {
 	// SourceLocation specifies the exact location within the HTTP transaction (e.g., header, JSON body).
 	SourceLocation SourceLocation `json:"source_location"`
 
+	// MatchOffset is the byte offset within Value where the chained value starts when it is only
+	// embedded in Value (e.g. an id inside a URL or composite key).
+	MatchOffset int `json:"match_offset,omitempty"`
+
+	// MatchLength is the length of the embedded chained value. Zero means the whole Value is the chained value.
+	MatchLength int `json:"match_length,omitempty"`
+
+	// Transform names the ValueTransform (e.g. "base64") under which the chained value appears in this
+	// request value. Empty means the value appears verbatim.
+	Transform string `json:"transform,omitempty"`
+
+	// JWTParent points to the value holding the JWT when this value is a claim decoded from it.
+	JWTParent *ValueReference `json:"-"`
+
+	// JWTClaimPath locates the claim within the decoded token, e.g. "payload.sub" or "header.kid".
+	JWTClaimPath string `json:"jwt_claim_path,omitempty"`
+
 	// Context points to the ChainedValueContext if this value is part of a chained substitution.
 	Context *ChainedValueContext
 
@@ -106,6 +134,9 @@ type ChainedValueContext struct {
 	// ValueSource points to the original ValueReference that is considered the source of this chained value.
 	ValueSource *ValueReference
 
+	// OriginalPath is the reference path of ValueSource as detected, before updateComplexPaths made it robust.
+	OriginalPath string
+
 	// VariableName is an optional name assigned to the value for substitution purposes.
 	VariableName string
 
@@ -1,7 +1,8 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
 )
 
 type VariableGenerator struct {
@@ -19,7 +20,7 @@ type VariableGeneratorResponse struct {
 // assignVariableNames assigns descriptive variable names to each chained value.
 // It prepares input data based on the origin request URL and response path of each value.
 // It calls the OpenAI API to generate meaningful names following best practices and updates each ChainedValueContext.
-func assignVariableNames(chainedValues []*ChainedValueContext) error {
+func assignVariableNames(ctx context.Context, chainedValues []*ChainedValueContext) error {
 
 	var variableNames []VariableGenerator
 	for _, cv := range chainedValues {
@@ -44,7 +45,7 @@ func assignVariableNames(chainedValues []*ChainedValueContext) error {
 		variableNames = append(variableNames, vg)
 	}
 
-	res, err := CallOpenAIArray[VariableGeneratorResponse](`
+	res, err := CallOpenAIArray[VariableGeneratorResponse](ctx, `
 I want you to come up with good variable names and optional initializers for values retrieved from an API.
 Please ensure each variable name is descriptive and follows best practices and is unique, but don't be overly verbose.
 Don't include things like "identifier" or "value" in the name unless it's critical to the naming, just the most descriptive
@@ -79,11 +80,11 @@ If a proposed name is provided, please use that as the variable name (and also r
 Ensure that there are no conflicts with other variable names.
 There should be a 1:1 correspondence between the input and output arrays. Every input *must* have a corresponding output.
 
-Please return a completely undecorated JSON response with just the array of objects.`, variableNames)
+Please return a completely undecorated JSON response with just the array of objects.`, variableNames,
+		expectCount[VariableGeneratorResponse](len(variableNames)))
 
 	if err != nil {
-		log.Fatalf("Error calling OpenAI: %v", err)
-		return err
+		return fmt.Errorf("error calling OpenAI: %w", err)
 	}
 
 	// Assign variable names
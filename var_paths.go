@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,15 +9,22 @@ import (
 	"strings"
 )
 
-func updateComplexPaths(values []*ChainedValueContext) {
+// updateComplexPaths makes the extraction path of every response-sourced value robust against array reordering.
+// Array indices are first replaced deterministically with sibling-key predicates; values the algorithm cannot
+// solve are sent to the AI when useAI is set, and otherwise keep their index-based path.
+func updateComplexPaths(ctx context.Context, values []*ChainedValueContext, useAI bool) {
 	// For each ChainedValueContext, we will:
-	// 1. Parse the JSON from the original response.
-	// 2. Prune the JSON so that it keeps only the relevant branch for this value
+	// 1. Try to build a predicate-based path from the value's ancestors; if that works we are done.
+	// 2. Parse the JSON from the original response.
+	// 3. Prune the JSON so that it keeps only the relevant branch for this value
 	//    (all parent nodes + up to 3 levels under the node).
-	// 3. Call OpenAI with the URL, the original JSON path, and the pruned JSON.
-	// 4. Store the refined/updated path in ValueSource.ReferencePath.
+	// 4. Call OpenAI with the URL, the original JSON path, and the pruned JSON.
+	// 5. Store the refined/updated path in ValueSource.ReferencePath.
 
 	for _, chainedVal := range values {
+		if ctx.Err() != nil {
+			return
+		}
 		// Only operate if we have a valid source from the response
 		if chainedVal.ValueSource == nil || chainedVal.ValueSource.SourceType != SourceTypeResponse {
 			continue
@@ -28,7 +36,17 @@ func updateComplexPaths(values []*ChainedValueContext) {
 		}
 
 		rawJSON := respEntry.Response.Content.Text
-		if rawJSON == "" {
+		// JWT claims are read by decoding the token, so only the token's own path matters.
+		if rawJSON == "" || chainedVal.ValueSource.SourceLocation != SourceLocationBodyJson || chainedVal.ValueSource.JWTParent != nil {
+			continue
+		}
+
+		chainedVal.OriginalPath = chainedVal.ValueSource.ReferencePath
+		if !applyRobustPath(chainedVal.ValueSource, chainedVal.Value) {
+			continue
+		}
+		if !useAI {
+			log.Printf("updateComplexPaths: no order-independent path found for %q, keeping it", chainedVal.ValueSource.ReferencePath)
 			continue
 		}
 
@@ -59,20 +77,30 @@ func updateComplexPaths(values []*ChainedValueContext) {
 		// Craft the final prompt for OpenAI
 		userPrompt := buildComplexPathPrompt()
 
-		// Call OpenAI to get a refined/robust JSON path
-		// We expect a single string in return representing the updated path
-		newPathList, err := CallOpenAIString(userPrompt, input)
+		// Call OpenAI to get a refined/robust JSON path.
+		// We expect a single string in return representing the updated path. The path is evaluated against
+		// the recorded response and only accepted if it yields the original value; rejected answers are
+		// retried and never cached.
+		var refinedPath string
+		_, err = callOpenAIBase(ctx, userPrompt, input, func(content string) error {
+			candidate := cleanModelPath(content)
+			if candidate == "" {
+				return errors.New("no new path returned from OpenAI")
+			}
+			validated, err := validateRefinedPath(rawJSON, candidate, chainedVal.Value)
+			if err != nil {
+				return fmt.Errorf("refined path %q rejected: %w", candidate, err)
+			}
+			refinedPath = validated
+			return nil
+		})
 		if err != nil {
-			log.Printf("updateComplexPaths: error calling OpenAI for path: %v", err)
-			continue
-		}
-		if len(newPathList) == 0 {
-			log.Printf("updateComplexPaths: no new path returned from OpenAI")
+			log.Printf("updateComplexPaths: keeping original path %q: %v", input.CurrentPath, err)
 			continue
 		}
 
 		// Update the reference path with the new stable/complex path
-		chainedVal.ValueSource.ReferencePath = newPathList
+		chainedVal.ValueSource.ReferencePath = refinedPath
 		log.Printf("Updated path from %q to %q", input.CurrentPath, chainedVal.ValueSource.ReferencePath)
 	}
 }